@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"github.com/SomchaiSPB/user-auth/internal/app"
+	"github.com/SomchaiSPB/user-auth/internal/cli"
 	"github.com/SomchaiSPB/user-auth/internal/config"
 	"log"
 	"os"
@@ -21,6 +22,13 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if handled, err := cli.Run(conf, os.Args[1:]); handled {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	a := app.New(conf)
 
 	if err := a.Init(); err != nil {
@@ -0,0 +1,350 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// HandleGetDeletedProducts lists soft deleted products
+// @Summary List deleted products
+// @Description This endpoint lists soft deleted products that were not purged yet
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   perPage  query  int     false  "Items per page, at most 100"
+// @Param   after    query  string  false  "Cursor from the next Link relation"
+// @Param   before   query  string  false  "Cursor from the prev Link relation"
+// @Success 200 {object} []entity.Product
+// @Header  200 {integer} X-Total-Count "Total number of deleted products"
+// @Header  200 {string} Link "Links to the next and prev pages"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/products/trash [get]
+func (a *App) HandleGetDeletedProducts(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.FromQuery(r.URL.Query())
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusBadRequest)
+		return
+	}
+
+	products, meta, err := a.productSvc.GetDeletedProducts(req)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		if errors.Is(err, service.ErrValidation) {
+			code = http.StatusBadRequest
+		}
+
+		respondWithErr(w, err, code)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, meta)
+	w.WriteHeader(http.StatusOK)
+	w.Write(products)
+}
+
+// HandleRestoreProduct restores a soft deleted product
+// @Summary Restore a deleted product
+// @Description This endpoint restores a soft deleted product
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "Product ID"
+// @Success 200 {object} entity.Product
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/products/{id}/restore [post]
+func (a *App) HandleRestoreProduct(w http.ResponseWriter, r *http.Request) {
+	product, err := a.productSvc.Restore(chi.URLParam(r, "id"))
+
+	if err != nil {
+		respondWithErr(w, err, productErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(product)
+}
+
+// HandleGetUsers lists users
+// @Summary List users
+// @Description This endpoint lists users with optional filters and sorting, paginated by cursor
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   perPage         query  int     false  "Items per page, at most 100"
+// @Param   after           query  string  false  "Cursor from the next Link relation"
+// @Param   before          query  string  false  "Cursor from the prev Link relation"
+// @Param   page            query  int     false  "Page number, ignored when a cursor is given"
+// @Param   name_contains   query  string  false  "Case-insensitive username substring"
+// @Param   disabled        query  bool    false  "Only disabled or enabled users"
+// @Param   verified        query  bool    false  "Only users with a verified or unverified email address"
+// @Param   created_after   query  string  false  "RFC 3339 timestamp or date"
+// @Param   created_before  query  string  false  "RFC 3339 timestamp or date"
+// @Param   sort            query  string  false  "Comma separated fields, prefix with - for descending, e.g. -created_at"
+// @Success 200 {object} []entity.User
+// @Header  200 {integer} X-Total-Count "Total number of matching users"
+// @Header  200 {string} Link "Links to the next and prev pages"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users [get]
+func (a *App) HandleGetUsers(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.FromQuery(r.URL.Query())
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusBadRequest)
+		return
+	}
+
+	users, meta, err := a.userSvc.GetUsers(req, r.URL.Query())
+
+	if err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	pagination.WriteHeaders(w, r, meta)
+	w.WriteHeader(http.StatusOK)
+	w.Write(users)
+}
+
+// HandleGetUser returns a user
+// @Summary Get a user
+// @Description This endpoint returns a user with its roles
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id} [get]
+func (a *App) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userSvc.GetUser(chi.URLParam(r, "id"))
+
+	if err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(user)
+}
+
+// HandleDisableUser disables a user
+// @Summary Disable a user
+// @Description This endpoint keeps a user from signing in and revokes every session. Its access tokens are rejected right away
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id}/disable [post]
+func (a *App) HandleDisableUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userSvc.Disable(chi.URLParam(r, "id"))
+
+	if err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(user)
+}
+
+// HandleEnableUser enables a disabled user
+// @Summary Enable a user
+// @Description This endpoint lets a disabled user sign in again
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id}/enable [post]
+func (a *App) HandleEnableUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userSvc.Enable(chi.URLParam(r, "id"))
+
+	if err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(user)
+}
+
+// HandleForcePasswordReset makes a user choose a new password
+// @Summary Force a password reset
+// @Description This endpoint clears the password of a user, revokes every session and emails a password reset link. The user name must be an email address
+// @Tags admin
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 202
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id}/reset-password [post]
+func (a *App) HandleForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	if err := a.resetSvc.ForceReset(chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleDeleteUser deletes a user
+// @Summary Delete a user
+// @Description This endpoint moves a user to the trash and revokes its tokens. It can be restored until purged
+// @Tags admin
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id} [delete]
+func (a *App) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := a.userSvc.DeleteUser(chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetDeletedUsers lists soft deleted users
+// @Summary List deleted users
+// @Description This endpoint lists soft deleted users that were not purged yet
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   perPage  query  int     false  "Items per page, at most 100"
+// @Param   after    query  string  false  "Cursor from the next Link relation"
+// @Param   before   query  string  false  "Cursor from the prev Link relation"
+// @Success 200 {object} []entity.User
+// @Header  200 {integer} X-Total-Count "Total number of deleted users"
+// @Header  200 {string} Link "Links to the next and prev pages"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/trash [get]
+func (a *App) HandleGetDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.FromQuery(r.URL.Query())
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusBadRequest)
+		return
+	}
+
+	users, meta, err := a.userSvc.GetDeletedUsers(req)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		if errors.Is(err, service.ErrValidation) {
+			code = http.StatusBadRequest
+		}
+
+		respondWithErr(w, err, code)
+		return
+	}
+
+	pagination.WriteHeaders(w, r, meta)
+	w.WriteHeader(http.StatusOK)
+	w.Write(users)
+}
+
+// HandleRestoreUser restores a soft deleted user
+// @Summary Restore a deleted user
+// @Description This endpoint restores a soft deleted user
+// @Tags admin
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id}/restore [post]
+func (a *App) HandleRestoreUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userSvc.Restore(chi.URLParam(r, "id"))
+
+	if err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(user)
+}
+
+// HandleUnlockUser lifts a sign-in lockout
+// @Summary Unlock a user
+// @Description This endpoint clears the failed sign-in attempts of a user, lifting a lockout before it expires
+// @Tags admin
+// @Security BearerAuth
+// @Param   id  path  int  true  "User ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/users/{id}/unlock [post]
+func (a *App) HandleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	if err := a.userSvc.Unlock(chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, userErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func userErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidUserID):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrUserNameExists), errors.Is(err, service.ErrNoEmailAddress),
+		errors.Is(err, service.ErrPasswordChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -10,8 +10,14 @@ import (
 	"github.com/SomchaiSPB/user-auth/internal/entity"
 	"github.com/SomchaiSPB/user-auth/internal/hash"
 	"github.com/SomchaiSPB/user-auth/internal/logger"
+	"github.com/SomchaiSPB/user-auth/internal/mailer"
+	"github.com/SomchaiSPB/user-auth/internal/password"
+	"github.com/SomchaiSPB/user-auth/internal/ratelimit"
 	"github.com/SomchaiSPB/user-auth/internal/repository"
 	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"github.com/SomchaiSPB/user-auth/internal/suggest"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jaswdr/faker"
@@ -21,6 +27,7 @@ import (
 	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"sync"
 	"time"
@@ -31,6 +38,37 @@ const (
 	postgresStorage = "postgres"
 )
 
+const hmacJwtAlg = "HS256"
+
+const (
+	argon2idHashAlg = "argon2id"
+	bcryptHashAlg   = "bcrypt"
+)
+
+const (
+	smtpMailDriver = "smtp"
+	fileMailDriver = "file"
+)
+
+const (
+	rateLimitMemoryStore = "memory"
+	rateLimitDBStore     = "db"
+)
+
+// passwordResetPath is the page of the web app reset links point to.
+const passwordResetPath = "/reset-password"
+
+// emailVerificationPath is the page of the web app verification links
+// point to.
+const emailVerificationPath = "/verify-email"
+
+// magicLinkPath is the page of the web app sign-in links point to.
+const magicLinkPath = "/magic-link"
+
+// webAuthnTimeout is how long the browser waits for the authenticator and
+// how long the server keeps the ceremony challenge.
+const webAuthnTimeout = 5 * time.Minute
+
 var (
 	ErrStorageTypeNotFound = errors.New("storage type not found")
 	ErrSqliteConnect       = errors.New("connecting to sqlite error")
@@ -38,15 +76,46 @@ var (
 	ErrPostgresConnect     = errors.New("connecting to postgres error")
 	ErrDBMigration         = errors.New("migrating entities error")
 	ErrAddFixtures         = errors.New("adding fixtures error")
+	ErrLoadSigningKey      = errors.New("loading jwt signing key error")
+	ErrSeedRoles           = errors.New("seeding roles error")
+	ErrBuildSuggestIndex   = errors.New("building product suggest index error")
+	ErrRateLimitConfig     = errors.New("configuring rate limits error")
+	ErrTrustedProxies      = errors.New("parsing trusted proxies error")
+	ErrLoadBreachedList    = errors.New("loading breached passwords list error")
+	ErrPasswordHashAlg     = errors.New("unknown password hash algorithm error")
+	ErrMailDriver          = errors.New("unknown mail driver error")
 )
 
+// defaultRoles are kept in sync with the database on every start.
+var defaultRoles = map[string][]string{
+	entity.RoleAdmin: {
+		entity.PermissionProductsRead,
+		entity.PermissionProductsWrite,
+		entity.PermissionUsersRead,
+		entity.PermissionUsersWrite,
+	},
+	entity.RoleUser: {
+		entity.PermissionProductsRead,
+	},
+}
+
 type App struct {
-	config     *config.Config
-	logger     logger.Logger
-	db         *gorm.DB
-	userSvc    *service.UserService
-	productSvc *service.ProductService
-	hasher     hash.Hasher
+	config        *config.Config
+	logger        logger.Logger
+	db            *gorm.DB
+	userSvc       *service.UserService
+	productSvc    *service.ProductService
+	webAuthnSvc   *service.WebAuthnService
+	resetSvc      *service.PasswordResetService
+	verifySvc     *service.EmailVerificationService
+	magicLinkSvc  *service.MagicLinkService
+	revocationSvc *service.RevocationService
+	rateLimiter   *ratelimit.Limiter
+	proxies       []netip.Prefix
+	mailer        mailer.Sender
+	hasher        hash.Hasher
+	keyRing       *signing.Ring
+	validator     signing.ClaimsValidator
 }
 
 func New(config *config.Config) *App {
@@ -62,40 +131,305 @@ func (a *App) Init() error {
 
 	a.logger = l
 
-	a.hasher = hash.NewHasher()
+	hasher, err := a.passwordHasher()
+
+	if err != nil {
+		return err
+	}
+
+	a.hasher = hasher
+
+	if err := a.initKeyRing(); err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadSigningKey, err)
+	}
+
+	a.validator = signing.ClaimsValidator{
+		Issuer:   a.config.AuthJwtIssuer(),
+		Audience: a.config.AuthJwtAudience(),
+		Leeway:   a.config.AuthJwtLeeway(),
+	}
 
 	if err := a.initDB(); err != nil {
 		return err
 	}
 
 	if a.config.WithTableTruncate() {
-		if err := a.db.Migrator().DropTable(&entity.User{}); err != nil {
-			log.Println("error dropping users table")
+		for _, drop := range []struct {
+			name   string
+			tables []interface{}
+		}{
+			{"users", []interface{}{&entity.User{}}},
+			{"products", []interface{}{&entity.Product{}}},
+			{"refresh tokens", []interface{}{&entity.RefreshToken{}}},
+			{"revoked tokens", []interface{}{&entity.RevokedToken{}}},
+			{"roles", []interface{}{"user_roles", "role_permissions", &entity.Role{}, &entity.Permission{}}},
+			{"mfa", []interface{}{&entity.MFAChallenge{}, &entity.RecoveryCode{}}},
+			{"webauthn", []interface{}{&entity.WebAuthnCredential{}, &entity.WebAuthnChallenge{}}},
+			{"login failures", []interface{}{&entity.LoginFailure{}}},
+			{"rate limit counters", []interface{}{&entity.RateLimitCounter{}}},
+			{"password reset tokens", []interface{}{&entity.PasswordResetToken{}}},
+			{"magic link tokens", []interface{}{&entity.MagicLinkToken{}}},
+			{"email verification tokens", []interface{}{&entity.EmailVerificationToken{}}},
+			{"sessions", []interface{}{&entity.Session{}}},
+		} {
+			if err := a.db.Migrator().DropTable(drop.tables...); err != nil {
+				log.Printf("error dropping %s tables: %v", drop.name, err)
+			}
 		}
-		if err := a.db.Migrator().DropTable(&entity.Product{}); err != nil {
-			log.Println("error dropping products table")
+		if err := repository.DropProductSearch(a.db); err != nil {
+			log.Println("error dropping products search index")
 		}
 	}
 
-	if err := a.db.AutoMigrate(&entity.Product{}, &entity.User{}); err != nil {
+	// accounts created before email verification existed stay usable
+	backfillVerified := a.db.Migrator().HasTable(&entity.User{}) && !a.db.Migrator().HasColumn(&entity.User{}, "EmailVerifiedAt")
+
+	if err := a.db.AutoMigrate(&entity.Product{}, &entity.User{}, &entity.RefreshToken{}, &entity.RevokedToken{}, &entity.Role{}, &entity.Permission{}, &entity.MFAChallenge{}, &entity.RecoveryCode{}, &entity.WebAuthnCredential{}, &entity.WebAuthnChallenge{}, &entity.LoginFailure{}, &entity.RateLimitCounter{}, &entity.PasswordResetToken{}, &entity.MagicLinkToken{}, &entity.EmailVerificationToken{}, &entity.Session{}); err != nil {
 		return fmt.Errorf("%w: %w", ErrDBMigration, err)
 	}
 
+	if err := repository.MigrateProductSearch(a.db); err != nil {
+		if !errors.Is(err, repository.ErrFullTextUnavailable) {
+			return fmt.Errorf("%w: %w", ErrDBMigration, err)
+		}
+
+		a.logger.Warnf("%v: build with -tags sqlite_fts5 for ranked search", err)
+	}
+
+	if backfillVerified {
+		if err := a.db.Model(&entity.User{}).Unscoped().Where("email_verified_at IS NULL").Update("email_verified_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("%w: %w", ErrDBMigration, err)
+		}
+	}
+
+	if err := a.seedRoles(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSeedRoles, err)
+	}
+
 	if a.config.WithFakeData() {
 		if err := a.addFixtures(); err != nil {
 			return fmt.Errorf("%w: %w", ErrAddFixtures, err)
 		}
 	}
 
-	a.userSvc = service.NewUserSvc(repository.NewUserDBRepository(a.db))
-	a.productSvc = service.NewProductSvc(repository.NewProductDBRepository(a.db))
+	passwordPolicy, err := a.passwordPolicy()
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadBreachedList, err)
+	}
+
+	a.userSvc = service.NewUserSvc(
+		repository.NewUserDBRepository(a.db),
+		repository.NewRefreshTokenDBRepository(a.db),
+		repository.NewRoleDBRepository(a.db),
+		repository.NewMFAChallengeDBRepository(a.db),
+		repository.NewRecoveryCodeDBRepository(a.db),
+		repository.NewSessionDBRepository(a.db),
+		service.NewLoginGuard(repository.NewLoginFailureDBRepository(a.db), service.LockoutOptions{
+			MaxFailures:     a.config.LoginMaxFailures(),
+			LockoutDuration: a.config.LoginLockoutDuration(),
+			IPMaxFailures:   a.config.LoginIPMaxFailures(),
+		}),
+		passwordPolicy,
+		a.hasher,
+		service.TokenOptions{
+			Issuer:               a.config.AuthJwtIssuer(),
+			Audience:             a.config.AuthJwtAudience(),
+			RefreshTokenTTL:      a.config.RefreshTokenTTL(),
+			MFAChallengeTTL:      a.config.MFAChallengeTTL(),
+			RequireVerifiedEmail: a.config.RequireVerifiedEmail(),
+		},
+	)
+	a.webAuthnSvc = service.NewWebAuthnSvc(
+		a.userSvc,
+		repository.NewUserDBRepository(a.db),
+		repository.NewWebAuthnCredentialDBRepository(a.db),
+		repository.NewWebAuthnChallengeDBRepository(a.db),
+		webauthn.RelyingParty{
+			ID:      a.config.WebAuthnRPID(),
+			Name:    a.config.WebAuthnRPName(),
+			Origins: a.config.WebAuthnOrigins(),
+			Timeout: webAuthnTimeout,
+		},
+	)
+
+	mailSender, err := a.mailSender()
+
+	if err != nil {
+		return err
+	}
+
+	a.mailer = mailSender
+	a.resetSvc = service.NewPasswordResetSvc(
+		a.userSvc,
+		repository.NewUserDBRepository(a.db),
+		repository.NewPasswordResetTokenDBRepository(a.db),
+		a.mailer,
+		service.PasswordResetOptions{
+			TokenTTL:    a.config.PasswordResetTTL(),
+			ResetURL:    a.config.PublicURL() + passwordResetPath,
+			OnSendError: a.logSendError("password reset"),
+		},
+	)
+	a.verifySvc = service.NewEmailVerificationSvc(
+		a.userSvc,
+		repository.NewUserDBRepository(a.db),
+		repository.NewEmailVerificationTokenDBRepository(a.db),
+		repository.NewPasswordResetTokenDBRepository(a.db),
+		repository.NewMagicLinkTokenDBRepository(a.db),
+		a.mailer,
+		service.EmailVerificationOptions{
+			TokenTTL:       a.config.EmailVerificationTTL(),
+			VerifyURL:      a.config.PublicURL() + emailVerificationPath,
+			ResendInterval: a.config.VerificationResendInterval(),
+			OnSendError:    a.logSendError("verification"),
+		},
+	)
+	a.magicLinkSvc = service.NewMagicLinkSvc(
+		a.userSvc,
+		repository.NewUserDBRepository(a.db),
+		repository.NewMagicLinkTokenDBRepository(a.db),
+		a.mailer,
+		service.MagicLinkOptions{
+			TokenTTL:    a.config.MagicLinkTTL(),
+			LoginURL:    a.config.PublicURL() + magicLinkPath,
+			OnSendError: a.logSendError("sign-in"),
+		},
+	)
+	a.productSvc = service.NewProductSvc(repository.NewProductDBRepository(a.db), suggest.NewIndex())
+
+	if err := a.productSvc.RebuildSuggestIndex(); err != nil {
+		return fmt.Errorf("%w: %w", ErrBuildSuggestIndex, err)
+	}
+
+	a.revocationSvc = service.NewRevocationSvc(repository.NewRevokedTokenDBRepository(a.db))
+
+	if err := a.initRateLimiter(); err != nil {
+		return fmt.Errorf("%w: %w", ErrRateLimitConfig, err)
+	}
+
+	proxies, err := parseTrustedProxies(a.config.TrustedProxies())
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTrustedProxies, err)
+	}
+
+	a.proxies = proxies
+
+	return nil
+}
+
+func (a *App) mailSender() (mailer.Sender, error) {
+	c := a.config.MailConfig
+
+	switch c.MailDriver() {
+	case smtpMailDriver:
+		return mailer.NewSMTPSender(c.SMTPHost(), c.SMTPPort(), c.SMTPUsername(), c.SMTPPassword(), c.MailFrom()), nil
+	case fileMailDriver:
+		return mailer.NewFileSender(c.MailFile(), c.MailFrom()), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrMailDriver, c.MailDriver())
+}
+
+func (a *App) passwordHasher() (hash.Hasher, error) {
+	c := a.config.PasswordHash()
+
+	switch c.Algorithm() {
+	case argon2idHashAlg:
+		params := hash.DefaultArgon2Params
+		params.Memory = c.Argon2Memory()
+		params.Iterations = c.Argon2Iterations()
+		params.Parallelism = c.Argon2Parallelism()
+
+		return hash.NewArgon2Hasher(params), nil
+	case bcryptHashAlg:
+		return hash.NewBcryptHasher(c.BcryptCost()), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrPasswordHashAlg, c.Algorithm())
+}
+
+func (a *App) passwordPolicy() (password.Policy, error) {
+	c := a.config.PasswordPolicy()
+
+	policy := password.Policy{
+		MinLength:  c.MinLength(),
+		MaxLength:  c.MaxLength(),
+		MinClasses: c.MinClasses(),
+		MinEntropy: c.MinEntropy(),
+	}
+
+	if c.BreachedFile() == "" {
+		return policy, nil
+	}
+
+	breached, err := password.LoadBreachedList(c.BreachedFile())
+
+	if err != nil {
+		return policy, err
+	}
+
+	a.logger.Infof("loaded %d breached passwords", breached.Len())
+	policy.Breached = breached
+
+	return policy, nil
+}
+
+func (a *App) initRateLimiter() error {
+	policies, err := ratelimit.ParsePolicies(a.config.RateLimitPolicies())
+
+	if err != nil {
+		return err
+	}
+
+	var store ratelimit.Store
+
+	switch a.config.RateLimitStore() {
+	case rateLimitMemoryStore:
+		store = ratelimit.NewMemoryStore()
+	case rateLimitDBStore:
+		store = repository.NewRateLimitDBRepository(a.db)
+	default:
+		return fmt.Errorf("unknown store %s", a.config.RateLimitStore())
+	}
+
+	a.rateLimiter = ratelimit.NewLimiter(store, policies)
 
 	return nil
 }
 
+// logSendError logs the errors of links of the given kind that are sent
+// after the response.
+func (a *App) logSendError(link string) func(error) {
+	return func(err error) {
+		a.logger.Errorf("sending %s link error: %v", link, err)
+	}
+}
+
 func (a *App) Run(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go a.startServer(ctx, wg)
+
+	purgeInterval := a.config.ExpiredPurgeInterval()
+
+	for _, job := range []func(){
+		a.purge("revoked tokens", a.revocationSvc.PurgeExpired),
+		a.purge("mfa challenges", a.userSvc.PurgeExpiredMFAChallenges),
+		a.purge("webauthn challenges", a.webAuthnSvc.PurgeExpiredChallenges),
+		a.purge("login failures", a.userSvc.PurgeExpiredLoginFailures),
+		a.purge("rate limit counters", a.purgeRateLimitCounters),
+		a.purge("password reset tokens", a.resetSvc.PurgeExpiredTokens),
+		a.purge("magic link tokens", a.magicLinkSvc.PurgeExpiredTokens),
+		a.purge("email verification tokens", a.verifySvc.PurgeExpiredTokens),
+		a.purge("sessions", a.userSvc.PurgeExpiredSessions),
+	} {
+		a.runEvery(ctx, wg, purgeInterval, job)
+	}
+
+	a.runEvery(ctx, wg, keyRingReloadInterval, a.reloadKeyRing)
+	a.runEvery(ctx, wg, trashPurgeInterval, a.purgeTrash)
+	a.runEvery(ctx, wg, suggestIndexRebuildInterval, a.rebuildSuggestIndex)
 }
 
 func (a *App) ShutDown() error {
@@ -115,16 +449,19 @@ func (a *App) router() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(a.RealIPMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(custom_middleware.ContentTypeJson)
+	r.Use(a.RateLimitMiddleware)
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
 
+	r.Get("/.well-known/jwks.json", a.HandleJWKS)
+
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL(fmt.Sprintf("%s:%s/%s", "http://localhost", a.config.HttpPort(), "swagger/doc.json")),
 	))
@@ -132,12 +469,84 @@ func (a *App) router() *chi.Mux {
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/sign-up", a.HandleCreateUser)
 		r.Post("/sign-in", a.HandleAuthUser)
+		r.Post("/sign-in/mfa", a.HandleVerifyMFA)
+		r.Post("/refresh", a.HandleRefreshToken)
+		r.With(a.ApiTokenMiddleware).Post("/sign-out", a.HandleSignOut)
+		r.Post("/password/forgot", a.HandleForgotPassword)
+		r.Post("/password/reset", a.HandleResetPassword)
+		r.Post("/verify-email", a.HandleVerifyEmail)
+		r.Post("/verify-email/resend", a.HandleResendVerification)
+		r.Post("/magic-link", a.HandleRequestMagicLink)
+		r.Post("/magic-link/verify", a.HandleVerifyMagicLink)
+
+		r.Route("/mfa", func(r chi.Router) {
+			r.Use(a.ApiTokenMiddleware)
+			r.Post("/totp", a.HandleEnrollTOTP)
+			r.Post("/totp/confirm", a.HandleConfirmTOTP)
+			r.Delete("/totp", a.HandleDisableTOTP)
+			r.Post("/recovery-codes", a.HandleRegenerateRecoveryCodes)
+		})
+
+		r.Route("/webauthn", func(r chi.Router) {
+			r.Post("/login/begin", a.HandleWebAuthnLoginBegin)
+			r.Post("/login/finish", a.HandleWebAuthnLoginFinish)
+
+			r.Group(func(r chi.Router) {
+				r.Use(a.ApiTokenMiddleware)
+				r.Post("/register/begin", a.HandleWebAuthnRegisterBegin)
+				r.Post("/register/finish", a.HandleWebAuthnRegisterFinish)
+				r.Get("/credentials", a.HandleGetWebAuthnCredentials)
+				r.Delete("/credentials/{id}", a.HandleDeleteWebAuthnCredential)
+			})
+		})
 	})
 
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Use(a.ApiTokenMiddleware)
-		r.Get("/product", a.HandleGetProduct)
-		r.Get("/products", a.HandleGetProducts)
+		r.Get("/me", a.HandleGetMe)
+		r.Patch("/me", a.HandlePatchMe)
+		r.Delete("/me", a.HandleDeleteMe)
+		r.Post("/me/password", a.HandleChangePassword)
+		r.Get("/me/sessions", a.HandleGetSessions)
+		r.Delete("/me/sessions", a.HandleRevokeOtherSessions)
+		r.Delete("/me/sessions/{id}", a.HandleRevokeSession)
+		r.With(a.RequirePermission(entity.PermissionProductsRead)).Get("/product", a.HandleGetProduct)
+		r.With(a.RequirePermission(entity.PermissionProductsRead)).Get("/products", a.HandleGetProducts)
+		r.With(a.RequirePermission(entity.PermissionProductsRead)).Get("/products/search", a.HandleSearchProducts)
+		r.With(a.RequirePermission(entity.PermissionProductsRead)).Get("/products/suggest", a.HandleSuggestProducts)
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.RequirePermission(entity.PermissionProductsWrite))
+			r.Post("/products", a.HandleCreateProduct)
+			r.Put("/products/{id}", a.HandleUpdateProduct)
+			r.Patch("/products/{id}", a.HandlePatchProduct)
+			r.Delete("/products/{id}", a.HandleDeleteProduct)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(a.RequirePermission(entity.PermissionProductsWrite))
+				r.Get("/products/trash", a.HandleGetDeletedProducts)
+				r.Post("/products/{id}/restore", a.HandleRestoreProduct)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(a.RequirePermission(entity.PermissionUsersRead))
+				r.Get("/users", a.HandleGetUsers)
+				r.Get("/users/{id}", a.HandleGetUser)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(a.RequirePermission(entity.PermissionUsersWrite))
+				r.Post("/users/{id}/disable", a.HandleDisableUser)
+				r.Post("/users/{id}/enable", a.HandleEnableUser)
+				r.Post("/users/{id}/reset-password", a.HandleForcePasswordReset)
+				r.Delete("/users/{id}", a.HandleDeleteUser)
+				r.Get("/users/trash", a.HandleGetDeletedUsers)
+				r.Post("/users/{id}/restore", a.HandleRestoreUser)
+				r.Post("/users/{id}/unlock", a.HandleUnlockUser)
+			})
+		})
 	})
 
 	return r
@@ -157,13 +566,13 @@ func (a *App) initDB() error {
 			return fmt.Errorf("%w: %w", ErrCreateSqliteDbFile, err)
 		}
 
-		db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+		db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{TranslateError: true})
 
 		if err != nil {
 			return fmt.Errorf("%w:%w", ErrSqliteConnect, err)
 		}
 	case postgresStorage:
-		db, err = gorm.Open(postgres.Open(a.config.PostgresDBConfig.Dsn()), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(a.config.PostgresDBConfig.Dsn()), &gorm.Config{TranslateError: true})
 		if err != nil {
 			return fmt.Errorf("%w:%w", ErrPostgresConnect, err)
 		}
@@ -176,11 +585,72 @@ func (a *App) initDB() error {
 	return nil
 }
 
+func (a *App) initKeyRing() error {
+	if dir := a.config.AuthJwtKeyringDir(); dir != "" {
+		ring, err := signing.LoadRing(dir)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := ring.Active(); err != nil {
+			return err
+		}
+
+		a.keyRing = ring
+
+		return nil
+	}
+
+	var key *signing.Key
+	var err error
+
+	if a.config.AuthJwtAlg() == hmacJwtAlg {
+		key, err = signing.NewHMACKey(a.config.AuthJwtKeyID(), a.config.AuthJwtSecret())
+	} else {
+		key, err = signing.LoadPEMKey(a.config.AuthJwtKeyID(), a.config.AuthJwtAlg(), a.config.AuthJwtKeyFile())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.keyRing = signing.NewStaticRing(key)
+
+	return nil
+}
+
+func (a *App) seedRoles() error {
+	roleRepo := repository.NewRoleDBRepository(a.db)
+
+	for name, permissions := range defaultRoles {
+		if _, err := roleRepo.Ensure(name, permissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (a *App) addFixtures() error {
 	fake := faker.New()
+	verifiedAt := time.Now()
 
 	userRepo := repository.NewUserDBRepository(a.db)
 	productRepo := repository.NewProductDBRepository(a.db)
+	roleRepo := repository.NewRoleDBRepository(a.db)
+
+	adminRole, err := roleRepo.GetByName(entity.RoleAdmin)
+
+	if err != nil {
+		return err
+	}
+
+	userRole, err := roleRepo.GetByName(entity.RoleUser)
+
+	if err != nil {
+		return err
+	}
 
 	for i := range 10 {
 		var u *entity.User
@@ -192,8 +662,10 @@ func (a *App) addFixtures() error {
 			}
 
 			u = &entity.User{
-				Name:     "admin@admin.com",
-				Password: hashedPass,
+				Name:            "admin@admin.com",
+				Password:        hashedPass,
+				Roles:           []*entity.Role{adminRole},
+				EmailVerifiedAt: &verifiedAt,
 			}
 		} else {
 			hashedPass, err := a.hasher.HashPassword(fake.Internet().Password())
@@ -203,8 +675,10 @@ func (a *App) addFixtures() error {
 			}
 
 			u = &entity.User{
-				Name:     fake.Internet().Email(),
-				Password: hashedPass,
+				Name:            fake.Internet().Email(),
+				Password:        hashedPass,
+				Roles:           []*entity.Role{userRole},
+				EmailVerifiedAt: &verifiedAt,
 			}
 		}
 
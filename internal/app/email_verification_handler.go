@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"io"
+	"net/http"
+)
+
+// HandleVerifyEmail verifies the email address of a user
+// @Summary Verify an email address
+// @Description This endpoint marks the email address of a user as verified with the token of an emailed verification link. Verifying twice succeeds
+// @Tags auth
+// @Accept  json
+// @Param   request  body  dto.VerifyEmailRequestDTO  true  "Verification token"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/verify-email [post]
+func (a *App) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.verifySvc.Verify(data); err != nil {
+		respondWithErr(w, err, emailVerificationErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleResendVerification emails a new verification link
+// @Summary Resend the verification link
+// @Description This endpoint emails a new verification link to an unverified user, at most once per resend interval. It succeeds whether or not the user exists
+// @Tags auth
+// @Accept  json
+// @Param   request  body  dto.ResendVerificationRequestDTO  true  "Username"
+// @Success 202
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/verify-email/resend [post]
+func (a *App) HandleResendVerification(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.verifySvc.Resend(data); err != nil {
+		respondWithErr(w, err, emailVerificationErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func emailVerificationErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidVerificationToken):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -3,25 +3,56 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"github.com/SomchaiSPB/user-auth/internal/password"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
 	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/SomchaiSPB/user-auth/internal/suggest"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 )
 
-const (
-	defaultPage    = 1
-	defaultPerPage = 1000
-)
+var ErrInvalidLimit = errors.New("limit must be a positive integer")
 
 type ErrorResponse struct {
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorDetail describes one invalid field of a request.
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
 	Message string `json:"message"`
 }
 
+// clientIP returns the address of the client, as set by RealIPMiddleware
+// when the service runs behind a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// requestClient describes the client of the request for the session it
+// signs in to.
+func requestClient(r *http.Request) service.Client {
+	return service.Client{IP: clientIP(r), UserAgent: r.UserAgent()}
+}
+
 func respondWithErr(w http.ResponseWriter, err error, code int) {
 	e := ErrorResponse{
 		Message: err.Error(),
+		Details: errorDetails(err),
 	}
 
 	byteResp, mErr := json.Marshal(e)
@@ -35,9 +66,30 @@ func respondWithErr(w http.ResponseWriter, err error, code int) {
 	w.Write(byteResp)
 }
 
+// errorDetails lists the invalid fields of validation errors.
+func errorDetails(err error) []ErrorDetail {
+	var details []ErrorDetail
+	var validationErrs validator.ValidationErrors
+	var policyErr *password.PolicyError
+
+	if errors.As(err, &validationErrs) {
+		for _, fe := range validationErrs {
+			details = append(details, ErrorDetail{Field: fe.Field(), Rule: fe.Tag(), Message: "failed on the " + fe.Tag() + " rule"})
+		}
+	}
+
+	if errors.As(err, &policyErr) {
+		for _, v := range policyErr.Violations {
+			details = append(details, ErrorDetail{Field: "password", Rule: v.Rule, Message: v.Message})
+		}
+	}
+
+	return details
+}
+
 // HandleCreateUser creates a new user
 // @Summary Create a new user
-// @Description This endpoint creates a new user with a username and password
+// @Description This endpoint creates a new user with an email address as username and a password, and emails a link to verify the address
 // @Tags users
 // @Accept  json
 // @Produce  json
@@ -54,7 +106,13 @@ func (a *App) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u, err := a.userSvc.Create(data)
+	u, err := a.verifySvc.SignUp(data)
+
+	// the user is created, the link can be sent again
+	if errors.Is(err, service.ErrSendMail) {
+		a.logger.Errorf("sending verification email error: %v", err)
+		err = nil
+	}
 
 	if err != nil {
 		code := http.StatusInternalServerError
@@ -73,13 +131,17 @@ func (a *App) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 
 // HandleAuthUser authenticates a user
 // @Summary Authenticate a user
-// @Description This endpoint authenticates a user and returns a JWT token
+// @Description This endpoint authenticates a user and returns a JWT token with a refresh token. Users with two-factor authentication enabled get an MFA token instead, to complete the sign-in at /auth/sign-in/mfa
 // @Tags auth
 // @Accept  json
 // @Produce  json
 // @Param   credentials  body  dto.AuthUserRequestDTO  true  "Authentication data"
 // @Success 200 {object} dto.AuthUserResponseDTO
+// @Success 200 {object} dto.MFAChallengeResponseDTO
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 423 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /auth/sign-in [post]
 func (a *App) HandleAuthUser(w http.ResponseWriter, r *http.Request) {
@@ -90,12 +152,136 @@ func (a *App) HandleAuthUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := a.userSvc.Authenticate(data, a.config.AuthJwtSecret())
+	response, err := a.userSvc.Authenticate(data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, service.ErrWrongCredentials), errors.Is(err, service.ErrValidation):
+			code = http.StatusBadRequest
+		case errors.Is(err, service.ErrEmailNotVerified), errors.Is(err, service.ErrAccountDisabled):
+			code = http.StatusForbidden
+		}
+
+		respondWithErr(w, err, signInThrottleErrCode(w, err, code))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// signInThrottleErrCode returns 429, or 423 for locked accounts, and sets
+// Retry-After when a sign-in is throttled, and code otherwise.
+func signInThrottleErrCode(w http.ResponseWriter, err error, code int) int {
+	var throttleErr *service.ThrottleError
+
+	if !errors.As(err, &throttleErr) {
+		return code
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(throttleErr.RetryAfter)))
+
+	if errors.Is(err, service.ErrAccountLocked) {
+		return http.StatusLocked
+	}
+
+	return http.StatusTooManyRequests
+}
+
+// HandleRefreshToken rotates a refresh token
+// @Summary Refresh an access token
+// @Description This endpoint exchanges a refresh token for a new access and refresh token pair. Reusing a rotated refresh token revokes all tokens issued from the same sign-in
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   token  body  dto.RefreshTokenRequestDTO  true  "Refresh token"
+// @Success 200 {object} dto.AuthUserResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/refresh [post]
+func (a *App) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.userSvc.Refresh(data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		if errors.Is(err, service.ErrValidation) {
+			code = http.StatusBadRequest
+		}
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			code = http.StatusUnauthorized
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrAccountDisabled) {
+			code = http.StatusForbidden
+		}
+
+		respondWithErr(w, err, code)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleSignOut revokes the caller's tokens
+// @Summary Sign out
+// @Description This endpoint revokes the access token used to call it. When a refresh token is supplied its whole token family is revoked as well
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   token  body  dto.RefreshTokenRequestDTO  false  "Refresh token"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/sign-out [post]
+func (a *App) HandleSignOut(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
 
 	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	p, ok := principal.FromContext(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	if len(data) > 0 {
+		if err := a.userSvc.RevokeRefreshToken(p.UserID, data); err != nil {
+			code := http.StatusInternalServerError
+
+			if errors.Is(err, service.ErrValidation) {
+				code = http.StatusBadRequest
+			}
+			if errors.Is(err, service.ErrInvalidRefreshToken) {
+				code = http.StatusUnauthorized
+			}
+
+			respondWithErr(w, err, code)
+			return
+		}
+	}
+
+	if err := a.revocationSvc.Revoke(p.Claims.ID, p.Claims.ExpiresAt.Time); err != nil {
 		code := http.StatusInternalServerError
 
-		if errors.Is(err, service.ErrWrongCredentials) || errors.Is(err, service.ErrValidation) {
+		if errors.Is(err, service.ErrEmptyTokenID) {
 			code = http.StatusBadRequest
 		}
 
@@ -103,6 +289,25 @@ func (a *App) HandleAuthUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleJWKS publishes the public signing keys
+// @Summary JSON Web Key Set
+// @Description This endpoint returns the public keys used to verify issued JWT tokens, including keys scheduled for activation. It is empty when tokens are signed with a shared secret
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} signing.JWKSet
+// @Failure 500 {object} ErrorResponse
+// @Router /.well-known/jwks.json [get]
+func (a *App) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	response, err := json.Marshal(a.keyRing.JWKSet())
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write(response)
 }
@@ -144,46 +349,276 @@ func (a *App) HandleGetProduct(w http.ResponseWriter, r *http.Request) {
 
 // HandleGetProducts retrieves a products list
 // @Summary Retrieve a products list
-// @Description This endpoint retrieves a products list
+// @Description This endpoint retrieves a products list, optionally filtered and sorted. The total count is returned in the X-Total-Count header and cursors for adjacent pages in the Link header
 // @Tags productsList
 // @Produce  json
 // @Security BearerAuth
+// @Param   perPage         query  int     false  "Items per page, at most 100"
+// @Param   after           query  string  false  "Cursor from the next Link relation"
+// @Param   before          query  string  false  "Cursor from the prev Link relation"
+// @Param   page            query  int     false  "Page number, ignored when a cursor is given"
+// @Param   price_gte       query  number  false  "Minimum price"
+// @Param   price_lte       query  number  false  "Maximum price"
+// @Param   name_contains   query  string  false  "Case-insensitive name substring"
+// @Param   created_after   query  string  false  "RFC 3339 timestamp or date"
+// @Param   created_before  query  string  false  "RFC 3339 timestamp or date"
+// @Param   sort            query  string  false  "Comma separated fields, prefix with - for descending, e.g. -price,name"
 // @Success 200 {object} []entity.Product
+// @Header  200 {integer} X-Total-Count "Total number of matching products"
+// @Header  200 {string} Link "Links to the next and prev pages"
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/products [get]
 func (a *App) HandleGetProducts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+	req, err := pagination.FromQuery(r.URL.Query())
 
-	pageStr := query.Get("page")
-	perPageStr := query.Get("perPage")
+	if err != nil {
+		respondWithErr(w, err, http.StatusBadRequest)
+		return
+	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = defaultPage
+	product, meta, err := a.productSvc.GetProducts(req, r.URL.Query())
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		if errors.Is(err, service.ErrValidation) {
+			code = http.StatusBadRequest
+		}
+		if errors.Is(err, service.ErrProductNotFound) {
+			code = http.StatusNotFound
+		}
+
+		respondWithErr(w, err, code)
+		return
 	}
 
-	perPage, err := strconv.Atoi(perPageStr)
-	if err != nil || perPage <= 0 {
-		perPage = defaultPerPage
+	pagination.WriteHeaders(w, r, meta)
+	w.WriteHeader(http.StatusOK)
+	w.Write(product)
+}
+
+// HandleSearchProducts searches products
+// @Summary Search products
+// @Description This endpoint returns products matching all words of the query in their name or description, best matches first. The last word also matches as a prefix
+// @Tags products
+// @Produce  json
+// @Security BearerAuth
+// @Param   q        query  string  true   "Search query"
+// @Param   perPage  query  int     false  "Maximum number of results, at most 100"
+// @Success 200 {object} []entity.Product
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products/search [get]
+func (a *App) HandleSearchProducts(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.FromQuery(r.URL.Query())
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusBadRequest)
+		return
 	}
 
-	product, err := a.productSvc.GetProducts(page, perPage)
+	products, err := a.productSvc.Search(r.URL.Query().Get("q"), req.Limit)
 
 	if err != nil {
 		code := http.StatusInternalServerError
 
-		if errors.Is(err, service.ErrEmptyRequestName) {
+		if errors.Is(err, service.ErrEmptySearchQuery) {
 			code = http.StatusBadRequest
 		}
-		if errors.Is(err, service.ErrProductNotFound) {
-			code = http.StatusNotFound
+
+		respondWithErr(w, err, code)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(products)
+}
+
+// HandleSuggestProducts suggests product names
+// @Summary Suggest product names
+// @Description This endpoint returns product names starting with the prefix, or with a word starting with it, for search box autocompletion. Small typos are tolerated
+// @Tags products
+// @Produce  json
+// @Security BearerAuth
+// @Param   prefix  query  string  true   "Typed prefix"
+// @Param   limit   query  int     false  "Maximum number of suggestions, 10 by default and at most 25"
+// @Success 200 {object} []suggest.Suggestion
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products/suggest [get]
+func (a *App) HandleSuggestProducts(w http.ResponseWriter, r *http.Request) {
+	limit := suggest.DefaultLimit
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+
+		if err != nil || n <= 0 {
+			respondWithErr(w, ErrInvalidLimit, http.StatusBadRequest)
+			return
+		}
+
+		limit = min(n, suggest.MaxLimit)
+	}
+
+	suggestions, err := a.productSvc.Suggest(r.URL.Query().Get("prefix"), limit)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		if errors.Is(err, service.ErrEmptySuggestQuery) {
+			code = http.StatusBadRequest
 		}
 
 		respondWithErr(w, err, code)
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	w.Write(suggestions)
+}
+
+// HandleCreateProduct creates a product
+// @Summary Create a product
+// @Description This endpoint creates a new product. Product names are unique
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   product  body  dto.CreateProductDTO  true  "Product Data"
+// @Success 201 {object} entity.Product
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products [post]
+func (a *App) HandleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	product, err := a.productSvc.Create(data)
+
+	if err != nil {
+		respondWithErr(w, err, productErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(product)
+}
+
+// HandleUpdateProduct replaces a product
+// @Summary Replace a product
+// @Description This endpoint replaces all editable fields of a product
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   id       path  int                   true  "Product ID"
+// @Param   product  body  dto.UpdateProductDTO  true  "Product Data"
+// @Success 200 {object} entity.Product
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products/{id} [put]
+func (a *App) HandleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	product, err := a.productSvc.Update(chi.URLParam(r, "id"), data)
+
+	if err != nil {
+		respondWithErr(w, err, productErrCode(err))
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write(product)
 }
+
+// HandlePatchProduct partially updates a product
+// @Summary Update a product
+// @Description This endpoint updates only the product fields present in the request
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   id       path  int                  true  "Product ID"
+// @Param   product  body  dto.PatchProductDTO  true  "Product Data"
+// @Success 200 {object} entity.Product
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products/{id} [patch]
+func (a *App) HandlePatchProduct(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	product, err := a.productSvc.Patch(chi.URLParam(r, "id"), data)
+
+	if err != nil {
+		respondWithErr(w, err, productErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(product)
+}
+
+// HandleDeleteProduct deletes a product
+// @Summary Delete a product
+// @Description This endpoint deletes a product
+// @Tags products
+// @Produce  json
+// @Security BearerAuth
+// @Param   id  path  int  true  "Product ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/products/{id} [delete]
+func (a *App) HandleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	if err := a.productSvc.Delete(chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, productErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func productErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidProductID):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrProductNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrProductNameExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
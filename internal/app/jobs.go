@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	keyRingReloadInterval = 30 * time.Second
+	trashPurgeInterval    = time.Hour
+	// suggestIndexRebuildInterval bounds how long product writes made by
+	// other instances take to show up in suggestions.
+	suggestIndexRebuildInterval = 5 * time.Minute
+)
+
+// runEvery runs job on every tick of interval until ctx is done.
+func (a *App) runEvery(ctx context.Context, wg *sync.WaitGroup, interval time.Duration, job func()) {
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				job()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// purge returns a job that deletes expired records of one kind with fn and
+// logs how many were removed under name.
+func (a *App) purge(name string, fn func() (int64, error)) func() {
+	return func() {
+		purged, err := fn()
+
+		if err != nil {
+			a.logger.Errorf("purging %s error: %v", name, err)
+			return
+		}
+
+		if purged > 0 {
+			a.logger.Infof("purged %d expired %s", purged, name)
+		}
+	}
+}
+
+// purgeRateLimitCounters adapts the rate limiter, which needs the current time.
+func (a *App) purgeRateLimitCounters() (int64, error) {
+	return a.rateLimiter.DeleteExpired(time.Now())
+}
+
+// reloadKeyRing picks up keys added by the keys rotate command
+// without restarting the server.
+func (a *App) reloadKeyRing() {
+	reloaded, err := a.keyRing.Reload()
+
+	if err != nil {
+		a.logger.Errorf("reloading key ring error: %v", err)
+		return
+	}
+
+	if reloaded {
+		a.logger.Info("key ring reloaded")
+	}
+}
+
+// purgeTrash hard deletes soft deleted records once the retention period passed.
+func (a *App) purgeTrash() {
+	before := time.Now().Add(-a.config.SoftDeleteRetention())
+
+	products, err := a.productSvc.PurgeDeleted(before)
+
+	if err != nil {
+		a.logger.Errorf("purging deleted products error: %v", err)
+	}
+
+	users, err := a.userSvc.PurgeDeleted(before)
+
+	if err != nil {
+		a.logger.Errorf("purging deleted users error: %v", err)
+	}
+
+	if products > 0 || users > 0 {
+		a.logger.Infof("purged %d deleted products and %d deleted users", products, users)
+	}
+}
+
+func (a *App) rebuildSuggestIndex() {
+	if err := a.productSvc.RebuildSuggestIndex(); err != nil {
+		a.logger.Errorf("rebuilding product suggest index error: %v", err)
+	}
+}
@@ -3,43 +3,117 @@ package app
 import (
 	"errors"
 	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
+	"time"
 )
 
 var (
 	ErrInvalidHeaderFormat = errors.New("invalid authorization header format")
 	ErrInvalidToken        = errors.New("invalid token")
 	ErrMissingAuthHeader   = errors.New("authorization header required")
+	ErrTokenRevoked        = errors.New("token has been revoked")
 )
 
+// ApiTokenMiddleware authenticates the request by its bearer token and
+// stores the caller in the request context, see principal.FromContext.
 func (a *App) ApiTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondWithErr(w, ErrMissingAuthHeader, http.StatusUnauthorized)
+		tokenString, err := bearerToken(r)
+
+		if err != nil {
+			respondWithErr(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.parseToken(tokenString)
+
+		if err != nil {
+			respondWithErr(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		p, err := principal.FromClaims(claims)
+
+		if err != nil {
+			respondWithErr(w, fmt.Errorf("%w: %w", ErrInvalidToken, err), http.StatusUnauthorized)
 			return
 		}
 
-		tokenString := strings.Split(authHeader, " ")
-		if len(tokenString) != 2 || tokenString[0] != "Bearer" {
-			respondWithErr(w, ErrInvalidHeaderFormat, http.StatusUnauthorized)
+		revoked, err := a.revocationSvc.IsRevoked(claims.ID)
+
+		if err != nil {
+			respondWithErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		token, err := jwt.Parse(tokenString[1], func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		if revoked {
+			respondWithErr(w, ErrTokenRevoked, http.StatusUnauthorized)
+			return
+		}
+
+		// disabled, deleted and no longer verified users lose access before
+		// their tokens expire
+		if err := a.userSvc.CheckActive(p.UserID); err != nil {
+			switch {
+			case errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrEmailNotVerified):
+				respondWithErr(w, err, http.StatusForbidden)
+			case errors.Is(err, service.ErrUserNotFound):
+				respondWithErr(w, fmt.Errorf("%w: %w", ErrInvalidToken, err), http.StatusUnauthorized)
+			default:
+				respondWithErr(w, err, http.StatusInternalServerError)
 			}
-			return a.config.AuthJwtSecret(), nil
-		})
+			return
+		}
 
-		if err != nil || !token.Valid {
-			respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		// signed out sessions lose access before their tokens expire
+		if err := a.userSvc.CheckSession(p.UserID, claims.SessionID); err != nil {
+			if errors.Is(err, service.ErrSessionRevoked) {
+				respondWithErr(w, err, http.StatusUnauthorized)
+			} else {
+				respondWithErr(w, err, http.StatusInternalServerError)
+			}
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(principal.WithPrincipal(r.Context(), p)))
 	})
 }
+
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", ErrMissingAuthHeader
+	}
+
+	tokenString := strings.Split(authHeader, " ")
+	if len(tokenString) != 2 || tokenString[0] != "Bearer" {
+		return "", ErrInvalidHeaderFormat
+	}
+
+	return tokenString[1], nil
+}
+
+// parseToken verifies the token signature against the key ring and checks
+// the registered claims with the configured leeway.
+func (a *App) parseToken(tokenString string) (*signing.Claims, error) {
+	claims := &signing.Claims{}
+
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+
+	token, err := parser.ParseWithClaims(tokenString, claims, a.keyRing.Keyfunc)
+
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if err := a.validator.Validate(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"io"
+	"net/http"
+)
+
+// HandleRequestMagicLink emails a sign-in link
+// @Summary Request a sign-in link
+// @Description This endpoint emails a single-use link to sign in without a password. It succeeds whether or not the user exists
+// @Tags auth
+// @Accept  json
+// @Param   request  body  dto.MagicLinkRequestDTO  true  "Username"
+// @Success 202
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/magic-link [post]
+func (a *App) HandleRequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.magicLinkSvc.Request(data); err != nil {
+		respondWithErr(w, err, magicLinkErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleVerifyMagicLink signs in with a sign-in link
+// @Summary Sign in with a sign-in link
+// @Description This endpoint exchanges the token of an emailed sign-in link for a token pair. Users with two-factor authentication enabled get an MFA token instead, to complete the sign-in at /auth/sign-in/mfa
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   request  body  dto.MagicLinkVerifyDTO  true  "Sign-in token"
+// @Success 200 {object} dto.AuthUserResponseDTO
+// @Success 200 {object} dto.MFAChallengeResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 423 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/magic-link/verify [post]
+func (a *App) HandleVerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.magicLinkSvc.Verify(data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		respondWithErr(w, err, signInThrottleErrCode(w, err, magicLinkErrCode(err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+func magicLinkErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidMagicLink):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrAccountDisabled):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
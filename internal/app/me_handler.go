@@ -0,0 +1,198 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// HandleGetMe returns the account of the caller
+// @Summary Get the own account
+// @Description This endpoint returns the account of the authenticated user
+// @Tags me
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} entity.User
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me [get]
+func (a *App) HandleGetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	response, err := a.userSvc.Me(userID)
+
+	if err != nil {
+		respondWithErr(w, err, meErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandlePatchMe updates the account of the caller
+// @Summary Update the own account
+// @Description This endpoint updates only the account fields present in the request. Changing the username requires the current password; the old address is notified, its pending reset and sign-in links stop working, and a verification link is emailed to the new one
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   request  body  dto.UpdateProfileDTO  true  "Account data"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me [patch]
+func (a *App) HandlePatchMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.verifySvc.UpdateProfile(userID, data, clientIP(r))
+
+	if err != nil {
+		// the account is updated, the verification link can be sent again
+		if !errors.Is(err, service.ErrSendMail) {
+			respondWithErr(w, err, a.meThrottleErrCode(w, err))
+			return
+		}
+
+		a.logger.Errorf("sending email change emails error: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleChangePassword changes the password of the caller
+// @Summary Change the own password
+// @Description This endpoint replaces the password, requiring the current one. Every session is signed out right away and a new one is started for the caller, whose token pair is returned
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   request  body  dto.ChangePasswordDTO  true  "Current and new password"
+// @Success 200 {object} dto.AuthUserResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me/password [post]
+func (a *App) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.userSvc.ChangePassword(userID, data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		respondWithErr(w, err, a.meThrottleErrCode(w, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleDeleteMe deletes the account of the caller
+// @Summary Delete the own account
+// @Description This endpoint deletes the account of the authenticated user, requiring the password, and signs it out. The account is purged after the trash retention period
+// @Tags me
+// @Accept  json
+// @Security BearerAuth
+// @Param   request  body  dto.DeleteAccountDTO  true  "Password"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me [delete]
+func (a *App) HandleDeleteMe(w http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.userSvc.DeleteAccount(p.UserID, data, clientIP(r)); err != nil {
+		respondWithErr(w, err, a.meThrottleErrCode(w, err))
+		return
+	}
+
+	if err := a.revocationSvc.Revoke(p.Claims.ID, p.Claims.ExpiresAt.Time); err != nil && !errors.Is(err, service.ErrEmptyTokenID) {
+		a.logger.Errorf("revoking token of deleted account error: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// meThrottleErrCode maps the errors of changes confirmed with the password,
+// setting Retry-After when they are throttled.
+func (a *App) meThrottleErrCode(w http.ResponseWriter, err error) int {
+	var throttleErr *service.ThrottleError
+
+	if errors.As(err, &throttleErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(throttleErr.RetryAfter)))
+
+		return http.StatusTooManyRequests
+	}
+
+	return meErrCode(err)
+}
+
+func meErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrWrongCredentials):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrPasswordChanged):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrUserNameExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
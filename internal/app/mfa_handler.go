@@ -0,0 +1,203 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"io"
+	"net/http"
+)
+
+// HandleVerifyMFA completes a sign-in with a second factor
+// @Summary Complete sign-in with a second factor
+// @Description This endpoint exchanges the MFA token returned by sign-in and a TOTP or recovery code for an access and refresh token pair. An MFA token is rejected after 5 wrong codes
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   request  body  dto.MFAVerifyRequestDTO  true  "MFA token and code"
+// @Success 200 {object} dto.AuthUserResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/sign-in/mfa [post]
+func (a *App) HandleVerifyMFA(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.userSvc.VerifyMFA(data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		respondWithErr(w, err, mfaErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleEnrollTOTP starts TOTP enrollment
+// @Summary Start TOTP enrollment
+// @Description This endpoint generates a TOTP secret and its otpauth URI for authenticator apps. Two-factor authentication is enabled only after confirming a code. Enrolling again replaces a pending secret
+// @Tags mfa
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} dto.TOTPEnrollResponseDTO
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/mfa/totp [post]
+func (a *App) HandleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	response, err := a.userSvc.EnrollTOTP(userID)
+
+	if err != nil {
+		respondWithErr(w, err, mfaErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleConfirmTOTP enables two-factor authentication
+// @Summary Confirm TOTP enrollment
+// @Description This endpoint enables two-factor authentication once a code from the authenticator app is confirmed, and returns single-use recovery codes. They are shown only once
+// @Tags mfa
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   request  body  dto.TOTPCodeRequestDTO  true  "TOTP code"
+// @Success 200 {object} dto.RecoveryCodesResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/mfa/totp/confirm [post]
+func (a *App) HandleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.userSvc.ConfirmTOTP(userID, data)
+
+	if err != nil {
+		respondWithErr(w, err, mfaErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleDisableTOTP disables two-factor authentication
+// @Summary Disable two-factor authentication
+// @Description This endpoint disables two-factor authentication with a TOTP or recovery code and deletes the recovery codes
+// @Tags mfa
+// @Accept  json
+// @Security BearerAuth
+// @Param   request  body  dto.TOTPCodeRequestDTO  true  "TOTP or recovery code"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/mfa/totp [delete]
+func (a *App) HandleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.userSvc.DisableTOTP(userID, data); err != nil {
+		respondWithErr(w, err, mfaErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRegenerateRecoveryCodes replaces the recovery codes
+// @Summary Regenerate recovery codes
+// @Description This endpoint invalidates the remaining recovery codes and returns new ones, confirmed with a TOTP code
+// @Tags mfa
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   request  body  dto.TOTPCodeRequestDTO  true  "TOTP code"
+// @Success 200 {object} dto.RecoveryCodesResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/mfa/recovery-codes [post]
+func (a *App) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.userSvc.RegenerateRecoveryCodes(userID, data)
+
+	if err != nil {
+		respondWithErr(w, err, mfaErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+func mfaErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrMFANotEnrolled):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrInvalidMFAToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrEmailNotVerified), errors.Is(err, service.ErrAccountDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"io"
+	"net/http"
+)
+
+// HandleForgotPassword emails a password reset link
+// @Summary Request a password reset link
+// @Description This endpoint emails a single-use password reset link to the user. It succeeds whether or not the user exists
+// @Tags auth
+// @Accept  json
+// @Param   request  body  dto.ForgotPasswordRequestDTO  true  "Username"
+// @Success 202
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/password/forgot [post]
+func (a *App) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.resetSvc.Forgot(data); err != nil {
+		respondWithErr(w, err, passwordResetErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleResetPassword sets a new password with a reset token
+// @Summary Reset the password
+// @Description This endpoint sets a new password with the token of an emailed reset link. The token works once; the refresh tokens of the user are revoked
+// @Tags auth
+// @Accept  json
+// @Param   request  body  dto.ResetPasswordRequestDTO  true  "Reset token and new password"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/password/reset [post]
+func (a *App) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.resetSvc.Reset(data); err != nil {
+		respondWithErr(w, err, passwordResetErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func passwordResetErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidResetToken):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
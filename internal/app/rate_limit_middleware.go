@@ -0,0 +1,70 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRateLimited     = errors.New("rate limit exceeded")
+	ErrRateLimitFailed = errors.New("rate limit is unavailable")
+)
+
+// RateLimitMiddleware applies the rate limit policy of the route and sets
+// the RateLimit-* headers. Policies keyed by user verify the bearer token
+// themselves, as they run before ApiTokenMiddleware; requests without a
+// valid token are keyed by client address instead. Requests are rejected
+// when the store fails, so that load on the store cannot lift the limit.
+func (a *App) RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy, ok := a.rateLimiter.Policy(r.Method, r.URL.Path)
+
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := a.rateLimiter.Allow(policy, a.rateLimitKey(policy, r), time.Now())
+
+		if err != nil {
+			a.logger.Errorf("rate limiting %s error: %v", policy.ID(), err)
+			w.Header().Set("Retry-After", "1")
+			respondWithErr(w, ErrRateLimitFailed, http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", policy.Header())
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			respondWithErr(w, ErrRateLimited, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey identifies the client of r for policy.
+func (a *App) rateLimitKey(policy ratelimit.Policy, r *http.Request) string {
+	if policy.Key == ratelimit.KeyUser {
+		if tokenString, err := bearerToken(r); err == nil {
+			if claims, err := a.parseToken(tokenString); err == nil {
+				return "user:" + claims.Subject
+			}
+		}
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"net/http"
+)
+
+var ErrForbidden = errors.New("permission denied")
+
+// RequirePermission allows the request only when the caller authenticated by
+// ApiTokenMiddleware was granted the permission through one of its roles.
+func (a *App) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := principal.FromContext(r.Context())
+
+			if !ok {
+				respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+				return
+			}
+
+			if !p.HasPermission(permission) {
+				respondWithErr(w, fmt.Errorf("%s: %w", permission, ErrForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
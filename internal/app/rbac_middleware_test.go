@@ -0,0 +1,61 @@
+package app
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *principal.Principal
+		wantCode  int
+	}{
+		{name: "unauthenticated", principal: nil, wantCode: http.StatusUnauthorized},
+		{name: "no permissions", principal: &principal.Principal{UserID: 1}, wantCode: http.StatusForbidden},
+		{
+			name:      "other permission",
+			principal: &principal.Principal{UserID: 1, Permissions: []string{entity.PermissionProductsRead}},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "role name is not a permission",
+			principal: &principal.Principal{UserID: 1, Roles: []string{entity.PermissionProductsWrite}},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name: "granted permission",
+			principal: &principal.Principal{
+				UserID:      1,
+				Permissions: []string{entity.PermissionProductsRead, entity.PermissionProductsWrite},
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	a := &App{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/products", nil)
+
+			if tt.principal != nil {
+				r = r.WithContext(principal.WithPrincipal(r.Context(), tt.principal))
+			}
+
+			w := httptest.NewRecorder()
+
+			a.RequirePermission(entity.PermissionProductsWrite)(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
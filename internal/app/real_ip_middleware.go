@@ -0,0 +1,112 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIPMiddleware replaces the remote address of requests sent by a trusted
+// proxy with the client address the proxy forwarded. Headers of other
+// requests are ignored, as any client can set them.
+func (a *App) RealIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := forwardedFor(r, a.proxies); ok {
+			r.RemoteAddr = ip.String()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedFor returns the client address forwarded by a trusted proxy.
+// X-Forwarded-For is read from the right, skipping the trusted proxies it
+// passed: the entries left of the first untrusted one come from the client.
+func forwardedFor(r *http.Request, proxies []netip.Prefix) (netip.Addr, bool) {
+	remote, ok := remoteAddr(r)
+
+	if !ok || !trusted(remote, proxies) {
+		return netip.Addr{}, false
+	}
+
+	if header := r.Header.Values("X-Forwarded-For"); len(header) > 0 {
+		hops := strings.Split(strings.Join(header, ","), ",")
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+
+			if err != nil {
+				return netip.Addr{}, false
+			}
+
+			if ip = ip.Unmap(); !trusted(ip, proxies) {
+				return ip, true
+			}
+		}
+
+		return netip.Addr{}, false
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ip.Unmap(), true
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip, err := netip.ParseAddr(host)
+
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ip.Unmap(), true
+}
+
+func trusted(ip netip.Addr, proxies []netip.Prefix) bool {
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTrustedProxies parses addresses and CIDR ranges of trusted proxies.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip, err := netip.ParseAddr(proxy)
+
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "198.51.100.7:1234", want: "198.51.100.7"},
+		{
+			name:       "headers of untrusted clients are ignored",
+			remoteAddr: "198.51.100.7:1234",
+			forwarded:  []string{"203.0.113.9"},
+			realIP:     "203.0.113.9",
+			want:       "198.51.100.7",
+		},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "trusted address", remoteAddr: "192.0.2.1:1234", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{
+			name:       "spoofed entries left of the client",
+			remoteAddr: "10.1.2.3:1234",
+			forwarded:  []string{"127.0.0.1, 203.0.113.9"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.1.2.3:1234",
+			forwarded:  []string{"203.0.113.9, 10.4.5.6", "192.0.2.1"},
+			want:       "203.0.113.9",
+		},
+		{name: "only trusted proxies", remoteAddr: "10.1.2.3:1234", forwarded: []string{"10.4.5.6"}, want: "10.1.2.3"},
+		{name: "invalid entry", remoteAddr: "10.1.2.3:1234", forwarded: []string{"unknown"}, want: "10.1.2.3"},
+		{name: "real ip", remoteAddr: "10.1.2.3:1234", realIP: "203.0.113.9", want: "203.0.113.9"},
+		{
+			name:       "forwarded for wins over real ip",
+			remoteAddr: "10.1.2.3:1234",
+			forwarded:  []string{"203.0.113.9"},
+			realIP:     "198.51.100.7",
+			want:       "203.0.113.9",
+		},
+		{name: "ipv6 client", remoteAddr: "10.1.2.3:1234", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+
+			a := &App{proxies: proxies}
+			h := a.RealIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		wantErr bool
+	}{
+		{proxies: nil},
+		{proxies: []string{"10.0.0.0/8", "127.0.0.1", "::1", "fd00::/8"}},
+		{proxies: []string{"localhost"}, wantErr: true},
+		{proxies: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := parseTrustedProxies(tt.proxies); (err != nil) != tt.wantErr {
+			t.Errorf("parseTrustedProxies(%q) error = %v, want error %v", tt.proxies, err, tt.wantErr)
+		}
+	}
+}
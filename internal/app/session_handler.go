@@ -0,0 +1,99 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// HandleGetSessions lists the sessions of the caller
+// @Summary List the own sessions
+// @Description This endpoint lists the clients the authenticated user is signed in on, most recently seen first. The session of the request is flagged as current
+// @Tags me
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {array} dto.SessionResponseDTO
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me/sessions [get]
+func (a *App) HandleGetSessions(w http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	response, err := a.userSvc.GetSessions(p.UserID, p.Claims.SessionID)
+
+	if err != nil {
+		respondWithErr(w, err, sessionErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleRevokeSession signs the caller out of one of its sessions
+// @Summary Revoke a session
+// @Description This endpoint revokes the refresh tokens of a session of the authenticated user. Its access tokens are rejected right away
+// @Tags me
+// @Security BearerAuth
+// @Param   id  path  int  true  "Session ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me/sessions/{id} [delete]
+func (a *App) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.userSvc.RevokeSession(userID, chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, sessionErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRevokeOtherSessions signs the caller out everywhere else
+// @Summary Revoke all other sessions
+// @Description This endpoint revokes every session of the authenticated user but the one of the request. Tokens issued before sessions were tracked belong to none, in which case every session is revoked
+// @Tags me
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/me/sessions [delete]
+func (a *App) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.userSvc.RevokeOtherSessions(p.UserID, p.Claims.SessionID); err != nil {
+		respondWithErr(w, err, sessionErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sessionErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
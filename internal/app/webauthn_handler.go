@@ -0,0 +1,225 @@
+package app
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/principal"
+	"github.com/SomchaiSPB/user-auth/internal/service"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+)
+
+// HandleWebAuthnRegisterBegin starts a passkey registration
+// @Summary Start passkey registration
+// @Description This endpoint returns the options to pass to navigator.credentials.create(), binary fields base64url encoded. Already registered credentials are excluded
+// @Tags webauthn
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} webauthn.CreationOptions
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/register/begin [post]
+func (a *App) HandleWebAuthnRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	options, err := a.webAuthnSvc.BeginRegistration(userID)
+
+	if err != nil {
+		respondWithErr(w, err, webAuthnErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(options)
+}
+
+// HandleWebAuthnRegisterFinish stores a new passkey
+// @Summary Finish passkey registration
+// @Description This endpoint verifies the credential returned by navigator.credentials.create() and stores it for the signed in user
+// @Tags webauthn
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param   request  body  dto.WebAuthnRegistrationDTO  true  "Created credential"
+// @Success 201 {object} entity.WebAuthnCredential
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/register/finish [post]
+func (a *App) HandleWebAuthnRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := a.webAuthnSvc.FinishRegistration(userID, data)
+
+	if err != nil {
+		respondWithErr(w, err, webAuthnErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(credential)
+}
+
+// HandleWebAuthnLoginBegin starts a passkey sign-in
+// @Summary Start passkey sign-in
+// @Description This endpoint returns the options to pass to navigator.credentials.get(). Without a username the authenticator offers its passkeys for this site
+// @Tags webauthn
+// @Accept  json
+// @Produce  json
+// @Param   request  body  dto.WebAuthnLoginBeginDTO  false  "Account to sign in to"
+// @Success 200 {object} webauthn.RequestOptions
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/login/begin [post]
+func (a *App) HandleWebAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	options, err := a.webAuthnSvc.BeginLogin(data)
+
+	if err != nil {
+		respondWithErr(w, err, webAuthnErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(options)
+}
+
+// HandleWebAuthnLoginFinish signs in with a passkey
+// @Summary Finish passkey sign-in
+// @Description This endpoint verifies the assertion returned by navigator.credentials.get() and returns a token pair. Users with two-factor authentication enabled get an MFA token instead when the authenticator did not verify the user
+// @Tags webauthn
+// @Accept  json
+// @Produce  json
+// @Param   request  body  dto.WebAuthnLoginDTO  true  "Assertion"
+// @Success 200 {object} dto.AuthUserResponseDTO
+// @Success 200 {object} dto.MFAChallengeResponseDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 423 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/login/finish [post]
+func (a *App) HandleWebAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		respondWithErr(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response, err := a.webAuthnSvc.FinishLogin(data, requestClient(r), a.keyRing)
+
+	if err != nil {
+		code := webAuthnErrCode(err)
+
+		if errors.Is(err, service.ErrWebAuthnChallenge) {
+			code = http.StatusUnauthorized
+		}
+
+		respondWithErr(w, err, signInThrottleErrCode(w, err, code))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// HandleGetWebAuthnCredentials lists the caller's passkeys
+// @Summary List passkeys
+// @Description This endpoint lists the passkeys and security keys registered by the signed in user
+// @Tags webauthn
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} []entity.WebAuthnCredential
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/credentials [get]
+func (a *App) HandleGetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	credentials, err := a.webAuthnSvc.GetCredentials(userID)
+
+	if err != nil {
+		respondWithErr(w, err, webAuthnErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(credentials)
+}
+
+// HandleDeleteWebAuthnCredential removes a passkey
+// @Summary Delete a passkey
+// @Description This endpoint removes a passkey of the signed in user
+// @Tags webauthn
+// @Security BearerAuth
+// @Param   id  path  int  true  "Credential ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (a *App) HandleDeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := principal.UserID(r.Context())
+
+	if !ok {
+		respondWithErr(w, ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.webAuthnSvc.DeleteCredential(userID, chi.URLParam(r, "id")); err != nil {
+		respondWithErr(w, err, webAuthnErrCode(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func webAuthnErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidCredentialID),
+		errors.Is(err, service.ErrWebAuthnRegistration), errors.Is(err, service.ErrWebAuthnChallenge):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrWebAuthnLogin):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrEmailNotVerified), errors.Is(err, service.ErrAccountDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrCredentialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrCredentialExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/config"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultRotateAlg     = "ES256"
+	defaultActivateAfter = time.Minute
+	defaultRetireAfter   = time.Hour
+)
+
+var (
+	ErrUnknownCommand  = errors.New("unknown command")
+	ErrKeyringDirEmpty = errors.New("key ring directory is not set, use -dir or AUTH_JWT_KEYRING_DIR")
+)
+
+// Keys runs the "keys" subcommand managing the JWT signing key ring.
+func Keys(conf *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: expected keys rotate|import|list", ErrUnknownCommand)
+	}
+
+	switch args[0] {
+	case "rotate":
+		return keysRotate(conf, args[1:], out)
+	case "import":
+		return keysImport(conf, args[1:], out)
+	case "list":
+		return keysList(conf, args[1:], out)
+	default:
+		return fmt.Errorf("%s: %w", args[0], ErrUnknownCommand)
+	}
+}
+
+func keysRotate(conf *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	dir := fs.String("dir", conf.AuthJwtKeyringDir(), "key ring directory")
+	alg := fs.String("alg", defaultRotateAlg, "signing algorithm of the new key")
+	activateAfter := fs.Duration("activate-after", defaultActivateAfter, "delay before the new key starts signing, must exceed the ring reload interval")
+	retireAfter := fs.Duration("retire-after", defaultRetireAfter, "how long previous keys keep verifying tokens after the new key is active")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return ErrKeyringDirEmpty
+	}
+
+	mk, err := signing.Rotate(*dir, *alg, *activateAfter, *retireAfter)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "generated %s key %s, active from %s\n", mk.Alg, mk.ID, mk.ActivateAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// keysImport moves a deployment signing with AUTH_JWT_SECRET onto a key
+// ring without invalidating the tokens it issued.
+func keysImport(conf *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keys import", flag.ContinueOnError)
+	dir := fs.String("dir", conf.AuthJwtKeyringDir(), "key ring directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return ErrKeyringDirEmpty
+	}
+
+	mk, err := signing.Import(*dir, conf.AuthJwtKeyID(), conf.AuthJwtAlg(), conf.AuthJwtSecret())
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "imported %s secret as key %q, active from %s\n", mk.Alg, mk.ID, mk.ActivateAt.Format(time.RFC3339))
+
+	return nil
+}
+
+func keysList(conf *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keys list", flag.ContinueOnError)
+	dir := fs.String("dir", conf.AuthJwtKeyringDir(), "key ring directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return ErrKeyringDirEmpty
+	}
+
+	m, err := signing.ReadManifest(*dir)
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tACTIVATE AT\tRETIRE AT")
+
+	for _, k := range m.Keys {
+		retireAt := "-"
+		if k.RetireAt != nil {
+			retireAt = k.RetireAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.ID, k.Alg, k.ActivateAt.Format(time.RFC3339), retireAt)
+	}
+
+	return w.Flush()
+}
+
+// Run dispatches command line subcommands. It reports false when args do
+// not name a subcommand and the server should be started instead.
+func Run(conf *config.Config, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "keys":
+		return true, Keys(conf, args[1:], os.Stdout)
+	default:
+		return true, fmt.Errorf("%s: %w", args[0], ErrUnknownCommand)
+	}
+}
@@ -5,6 +5,45 @@ import (
 	"github.com/joho/godotenv"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	defaultJwtAlg           = "HS256"
+	defaultJwtIssuer        = "user-auth"
+	defaultJwtAudience      = "user-auth-api"
+	defaultJwtLeeway        = 30 * time.Second
+	defaultTrashRetention   = 30 * 24 * time.Hour
+	defaultPurgeInterval    = 10 * time.Minute
+	defaultMFAChallengeTTL  = 5 * time.Minute
+	defaultWebAuthnRPID     = "localhost"
+	defaultWebAuthnRPName   = "user-auth"
+	defaultLoginMaxFail     = 10
+	defaultLoginIPMaxFail   = 20
+	defaultLockoutDuration  = 15 * time.Minute
+	defaultRateLimitStore   = "memory"
+	defaultPasswordMinLen   = 10
+	defaultPasswordMaxLen   = 64
+	defaultPasswordClasses  = 2
+	defaultPasswordEntropy  = 40
+	defaultPasswordHashAlg  = "argon2id"
+	defaultPasswordResetTTL = 30 * time.Minute
+	defaultVerificationTTL  = 24 * time.Hour
+	defaultResendInterval   = time.Minute
+	defaultMagicLinkTTL     = 15 * time.Minute
+	defaultMailDriver       = "file"
+	defaultMailFrom         = "user-auth <no-reply@localhost>"
+	defaultSMTPPort         = "587"
+	defaultBcryptCost       = 10
+	// defaultArgon2* follow the OWASP recommendation for Argon2id.
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+	// defaultRateLimitPolicies throttle the unauthenticated endpoints per
+	// client address and the API per user.
+	defaultRateLimitPolicies = "/auth=sliding_window 60/1m ip;/api/v1=token_bucket 600/1m user"
 )
 
 type Config struct {
@@ -13,9 +52,38 @@ type Config struct {
 	httpPort          string
 	environment       string
 	authJwtSecret     string
+	authJwtAlg        string
+	authJwtKeyFile    string
+	authJwtKeyID      string
+	authJwtKeyringDir string
+	authJwtIssuer     string
+	authJwtAudience   string
+	authJwtLeeway     time.Duration
+	refreshTokenTTL   time.Duration
+	mfaChallengeTTL   time.Duration
+	webAuthnRPID      string
+	webAuthnRPName    string
+	webAuthnOrigins   []string
+	loginMaxFailures  int
+	loginIPFailures   int
+	lockoutDuration   time.Duration
+	rateLimitStore    string
+	rateLimitPolicies string
+	trustedProxies    []string
+	passwordPolicy    PasswordPolicyConfig
+	passwordHash      PasswordHashConfig
+	passwordResetTTL  time.Duration
+	verificationTTL   time.Duration
+	resendInterval    time.Duration
+	requireVerified   bool
+	magicLinkTTL      time.Duration
+	publicURL         string
+	MailConfig
 	storage           string
 	withFakeData      bool
 	withTableTruncate bool
+	trashRetention    time.Duration
+	purgeInterval     time.Duration
 }
 
 func (c Config) WithTableTruncate() bool {
@@ -26,6 +94,164 @@ func (c Config) WithFakeData() bool {
 	return c.withFakeData
 }
 
+// SoftDeleteRetention is how long soft deleted records are kept before purging.
+func (c Config) SoftDeleteRetention() time.Duration {
+	return c.trashRetention
+}
+
+// ExpiredPurgeInterval is how often expired tokens, challenges, counters
+// and sessions are deleted.
+func (c Config) ExpiredPurgeInterval() time.Duration {
+	return c.purgeInterval
+}
+
+// PasswordPolicyConfig are the rules new passwords have to follow.
+type PasswordPolicyConfig struct {
+	minLength    int
+	maxLength    int
+	minClasses   int
+	minEntropy   float64
+	breachedFile string
+}
+
+func (p PasswordPolicyConfig) MinLength() int {
+	return p.minLength
+}
+
+func (p PasswordPolicyConfig) MaxLength() int {
+	return p.maxLength
+}
+
+// MinClasses is how many of lower case letters, upper case letters, digits
+// and symbols a password has to mix.
+func (p PasswordPolicyConfig) MinClasses() int {
+	return p.minClasses
+}
+
+// MinEntropy is the estimated strength in bits a password needs.
+func (p PasswordPolicyConfig) MinEntropy() float64 {
+	return p.minEntropy
+}
+
+// BreachedFile lists passwords known from data breaches, screening is off
+// when empty.
+func (p PasswordPolicyConfig) BreachedFile() string {
+	return p.breachedFile
+}
+
+func (c Config) PasswordPolicy() PasswordPolicyConfig {
+	return c.passwordPolicy
+}
+
+// PasswordHashConfig selects the algorithm new password hashes are made
+// with. Hashes of the other algorithm are still accepted and upgraded.
+type PasswordHashConfig struct {
+	algorithm         string
+	bcryptCost        int
+	argon2Memory      uint32
+	argon2Iterations  uint32
+	argon2Parallelism uint8
+}
+
+// Algorithm is either argon2id or bcrypt.
+func (p PasswordHashConfig) Algorithm() string {
+	return p.algorithm
+}
+
+func (p PasswordHashConfig) BcryptCost() int {
+	return p.bcryptCost
+}
+
+// Argon2Memory is in KiB.
+func (p PasswordHashConfig) Argon2Memory() uint32 {
+	return p.argon2Memory
+}
+
+func (p PasswordHashConfig) Argon2Iterations() uint32 {
+	return p.argon2Iterations
+}
+
+func (p PasswordHashConfig) Argon2Parallelism() uint8 {
+	return p.argon2Parallelism
+}
+
+func (c Config) PasswordHash() PasswordHashConfig {
+	return c.passwordHash
+}
+
+// MailConfig selects how emails are delivered.
+type MailConfig struct {
+	driver       string
+	from         string
+	file         string
+	smtpHost     string
+	smtpPort     string
+	smtpUsername string
+	smtpPassword string
+}
+
+// MailDriver is either smtp or file.
+func (m MailConfig) MailDriver() string {
+	return m.driver
+}
+
+func (m MailConfig) MailFrom() string {
+	return m.from
+}
+
+// MailFile is where the file driver writes emails, stdout when empty.
+func (m MailConfig) MailFile() string {
+	return m.file
+}
+
+func (m MailConfig) SMTPHost() string {
+	return m.smtpHost
+}
+
+func (m MailConfig) SMTPPort() string {
+	return m.smtpPort
+}
+
+func (m MailConfig) SMTPUsername() string {
+	return m.smtpUsername
+}
+
+func (m MailConfig) SMTPPassword() string {
+	return m.smtpPassword
+}
+
+// PasswordResetTTL is how long an emailed password reset link stays valid.
+func (c Config) PasswordResetTTL() time.Duration {
+	return c.passwordResetTTL
+}
+
+// EmailVerificationTTL is how long an emailed verification link stays valid.
+func (c Config) EmailVerificationTTL() time.Duration {
+	return c.verificationTTL
+}
+
+// VerificationResendInterval is the least time between two verification
+// emails to the same user.
+func (c Config) VerificationResendInterval() time.Duration {
+	return c.resendInterval
+}
+
+// RequireVerifiedEmail tells whether users must verify their email address
+// before signing in.
+func (c Config) RequireVerifiedEmail() bool {
+	return c.requireVerified
+}
+
+// MagicLinkTTL is how long an emailed sign-in link stays valid.
+func (c Config) MagicLinkTTL() time.Duration {
+	return c.magicLinkTTL
+}
+
+// PublicURL is the base URL of the web app links in emails point to.
+func (c Config) PublicURL() string {
+	return c.publicURL
+}
+
 type SqliteDBConfig struct {
 	dbFile string
 }
@@ -62,6 +288,94 @@ func (c Config) AuthJwtSecret() []byte {
 	return []byte(c.authJwtSecret)
 }
 
+// AuthJwtAlg is the JWT signing algorithm, HS256 signs with AuthJwtSecret,
+// any other algorithm signs with the private key from AuthJwtKeyFile.
+func (c Config) AuthJwtAlg() string {
+	return c.authJwtAlg
+}
+
+func (c Config) AuthJwtKeyFile() string {
+	return c.authJwtKeyFile
+}
+
+func (c Config) AuthJwtKeyID() string {
+	return c.authJwtKeyID
+}
+
+// AuthJwtKeyringDir is the key ring directory managed by the keys command.
+// When set it takes precedence over the single key settings.
+func (c Config) AuthJwtKeyringDir() string {
+	return c.authJwtKeyringDir
+}
+
+func (c Config) AuthJwtIssuer() string {
+	return c.authJwtIssuer
+}
+
+func (c Config) AuthJwtAudience() string {
+	return c.authJwtAudience
+}
+
+// AuthJwtLeeway is the tolerated clock skew when checking exp, nbf and iat.
+func (c Config) AuthJwtLeeway() time.Duration {
+	return c.authJwtLeeway
+}
+
+func (c Config) RefreshTokenTTL() time.Duration {
+	return c.refreshTokenTTL
+}
+
+// MFAChallengeTTL is how long a second sign-in step may take after the password was accepted.
+func (c Config) MFAChallengeTTL() time.Duration {
+	return c.mfaChallengeTTL
+}
+
+// WebAuthnRPID is the domain passkeys are bound to.
+func (c Config) WebAuthnRPID() string {
+	return c.webAuthnRPID
+}
+
+func (c Config) WebAuthnRPName() string {
+	return c.webAuthnRPName
+}
+
+// WebAuthnOrigins are the web origins allowed to run WebAuthn ceremonies.
+func (c Config) WebAuthnOrigins() []string {
+	return c.webAuthnOrigins
+}
+
+// LoginMaxFailures is the number of failed sign-ins locking an account.
+func (c Config) LoginMaxFailures() int {
+	return c.loginMaxFailures
+}
+
+// LoginIPMaxFailures is the number of failed sign-ins from one address
+// before further attempts from it are slowed down.
+func (c Config) LoginIPMaxFailures() int {
+	return c.loginIPFailures
+}
+
+func (c Config) LoginLockoutDuration() time.Duration {
+	return c.lockoutDuration
+}
+
+// RateLimitStore is where rate limit counters are kept, "memory" for each
+// instance on its own or "db" to share them between instances.
+func (c Config) RateLimitStore() string {
+	return c.rateLimitStore
+}
+
+// RateLimitPolicies are the per-route rate limits, see ratelimit.ParsePolicies.
+func (c Config) RateLimitPolicies() string {
+	return c.rateLimitPolicies
+}
+
+// TrustedProxies are the addresses and CIDR ranges of the proxies whose
+// X-Forwarded-For and X-Real-IP headers are trusted.
+func (c Config) TrustedProxies() []string {
+	return c.trustedProxies
+}
+
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		if os.Getenv("APP_ENV") == "" {
@@ -93,14 +407,263 @@ func Load() (*Config, error) {
 		withTruncate = false
 	}
 
+	refreshTokenTTL, err := time.ParseDuration(os.Getenv("AUTH_REFRESH_TOKEN_TTL"))
+
+	if err != nil || refreshTokenTTL <= 0 {
+		refreshTokenTTL = defaultRefreshTokenTTL
+	}
+
+	mfaChallengeTTL, err := time.ParseDuration(os.Getenv("AUTH_MFA_CHALLENGE_TTL"))
+
+	if err != nil || mfaChallengeTTL <= 0 {
+		mfaChallengeTTL = defaultMFAChallengeTTL
+	}
+
+	webAuthnRPID := os.Getenv("AUTH_WEBAUTHN_RP_ID")
+
+	if webAuthnRPID == "" {
+		webAuthnRPID = defaultWebAuthnRPID
+	}
+
+	webAuthnRPName := os.Getenv("AUTH_WEBAUTHN_RP_NAME")
+
+	if webAuthnRPName == "" {
+		webAuthnRPName = defaultWebAuthnRPName
+	}
+
+	var webAuthnOrigins []string
+
+	for _, origin := range strings.Split(os.Getenv("AUTH_WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			webAuthnOrigins = append(webAuthnOrigins, origin)
+		}
+	}
+
+	if len(webAuthnOrigins) == 0 {
+		webAuthnOrigins = []string{"https://" + webAuthnRPID}
+	}
+
+	loginMaxFailures, err := strconv.Atoi(os.Getenv("AUTH_LOGIN_MAX_FAILURES"))
+
+	if err != nil || loginMaxFailures <= 0 {
+		loginMaxFailures = defaultLoginMaxFail
+	}
+
+	loginIPFailures, err := strconv.Atoi(os.Getenv("AUTH_LOGIN_IP_MAX_FAILURES"))
+
+	if err != nil || loginIPFailures <= 0 {
+		loginIPFailures = defaultLoginIPMaxFail
+	}
+
+	lockoutDuration, err := time.ParseDuration(os.Getenv("AUTH_LOGIN_LOCKOUT_DURATION"))
+
+	if err != nil || lockoutDuration <= 0 {
+		lockoutDuration = defaultLockoutDuration
+	}
+
+	rateLimitStore := os.Getenv("RATE_LIMIT_STORE")
+
+	if rateLimitStore == "" {
+		rateLimitStore = defaultRateLimitStore
+	}
+
+	rateLimitPolicies := os.Getenv("RATE_LIMIT_POLICIES")
+
+	if strings.TrimSpace(rateLimitPolicies) == "" {
+		rateLimitPolicies = defaultRateLimitPolicies
+	}
+
+	var trustedProxies []string
+
+	for _, proxy := range strings.Split(os.Getenv("APP_TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
+	passwordPolicy := PasswordPolicyConfig{
+		minLength:    defaultPasswordMinLen,
+		maxLength:    defaultPasswordMaxLen,
+		minClasses:   defaultPasswordClasses,
+		minEntropy:   defaultPasswordEntropy,
+		breachedFile: os.Getenv("PASSWORD_BREACHED_FILE"),
+	}
+
+	if minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && minLength > 0 {
+		passwordPolicy.minLength = minLength
+	}
+
+	if maxLength, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil && maxLength > 0 {
+		passwordPolicy.maxLength = maxLength
+	}
+
+	if minClasses, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_CLASSES")); err == nil && minClasses >= 0 {
+		passwordPolicy.minClasses = minClasses
+	}
+
+	if minEntropy, err := strconv.ParseFloat(os.Getenv("PASSWORD_MIN_ENTROPY"), 64); err == nil && minEntropy >= 0 {
+		passwordPolicy.minEntropy = minEntropy
+	}
+
+	passwordHash := PasswordHashConfig{
+		algorithm:         os.Getenv("PASSWORD_HASH_ALG"),
+		bcryptCost:        defaultBcryptCost,
+		argon2Memory:      defaultArgon2Memory,
+		argon2Iterations:  defaultArgon2Iterations,
+		argon2Parallelism: defaultArgon2Parallelism,
+	}
+
+	if passwordHash.algorithm == "" {
+		passwordHash.algorithm = defaultPasswordHashAlg
+	}
+
+	if cost, err := strconv.Atoi(os.Getenv("PASSWORD_BCRYPT_COST")); err == nil && cost > 0 {
+		passwordHash.bcryptCost = cost
+	}
+
+	if memory, err := strconv.ParseUint(os.Getenv("PASSWORD_ARGON2_MEMORY"), 10, 32); err == nil && memory > 0 {
+		passwordHash.argon2Memory = uint32(memory)
+	}
+
+	if iterations, err := strconv.ParseUint(os.Getenv("PASSWORD_ARGON2_ITERATIONS"), 10, 32); err == nil && iterations > 0 {
+		passwordHash.argon2Iterations = uint32(iterations)
+	}
+
+	if parallelism, err := strconv.ParseUint(os.Getenv("PASSWORD_ARGON2_PARALLELISM"), 10, 8); err == nil && parallelism > 0 {
+		passwordHash.argon2Parallelism = uint8(parallelism)
+	}
+
+	passwordResetTTL, err := time.ParseDuration(os.Getenv("AUTH_PASSWORD_RESET_TTL"))
+
+	if err != nil || passwordResetTTL <= 0 {
+		passwordResetTTL = defaultPasswordResetTTL
+	}
+
+	verificationTTL, err := time.ParseDuration(os.Getenv("AUTH_EMAIL_VERIFICATION_TTL"))
+
+	if err != nil || verificationTTL <= 0 {
+		verificationTTL = defaultVerificationTTL
+	}
+
+	resendInterval, err := time.ParseDuration(os.Getenv("AUTH_VERIFICATION_RESEND_INTERVAL"))
+
+	if err != nil || resendInterval < 0 {
+		resendInterval = defaultResendInterval
+	}
+
+	requireVerified, err := strconv.ParseBool(os.Getenv("AUTH_REQUIRE_VERIFIED_EMAIL"))
+
+	if err != nil {
+		requireVerified = false
+	}
+
+	magicLinkTTL, err := time.ParseDuration(os.Getenv("AUTH_MAGIC_LINK_TTL"))
+
+	if err != nil || magicLinkTTL <= 0 {
+		magicLinkTTL = defaultMagicLinkTTL
+	}
+
+	publicURL := strings.TrimSuffix(os.Getenv("APP_PUBLIC_URL"), "/")
+
+	if publicURL == "" {
+		publicURL = "http://localhost:" + os.Getenv("APP_HTTP_PORT")
+	}
+
+	mailConfig := MailConfig{
+		driver:       os.Getenv("MAIL_DRIVER"),
+		from:         os.Getenv("MAIL_FROM"),
+		file:         os.Getenv("MAIL_FILE"),
+		smtpHost:     os.Getenv("SMTP_HOST"),
+		smtpPort:     os.Getenv("SMTP_PORT"),
+		smtpUsername: os.Getenv("SMTP_USERNAME"),
+		smtpPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+
+	if mailConfig.driver == "" {
+		mailConfig.driver = defaultMailDriver
+	}
+
+	if mailConfig.from == "" {
+		mailConfig.from = defaultMailFrom
+	}
+
+	if mailConfig.smtpPort == "" {
+		mailConfig.smtpPort = defaultSMTPPort
+	}
+
+	jwtAlg := os.Getenv("AUTH_JWT_ALG")
+
+	if jwtAlg == "" {
+		jwtAlg = defaultJwtAlg
+	}
+
+	jwtIssuer := os.Getenv("AUTH_JWT_ISSUER")
+
+	if jwtIssuer == "" {
+		jwtIssuer = defaultJwtIssuer
+	}
+
+	jwtAudience := os.Getenv("AUTH_JWT_AUDIENCE")
+
+	if jwtAudience == "" {
+		jwtAudience = defaultJwtAudience
+	}
+
+	jwtLeeway, err := time.ParseDuration(os.Getenv("AUTH_JWT_LEEWAY"))
+
+	if err != nil || jwtLeeway < 0 {
+		jwtLeeway = defaultJwtLeeway
+	}
+
+	trashRetention, err := time.ParseDuration(os.Getenv("APP_SOFT_DELETE_RETENTION"))
+
+	if err != nil || trashRetention <= 0 {
+		trashRetention = defaultTrashRetention
+	}
+
+	purgeInterval, err := time.ParseDuration(os.Getenv("APP_EXPIRED_PURGE_INTERVAL"))
+
+	if err != nil || purgeInterval <= 0 {
+		purgeInterval = defaultPurgeInterval
+	}
+
 	return &Config{
 		PostgresDBConfig:  postgresDb,
 		SqliteDBConfig:    sqliteDb,
 		httpPort:          os.Getenv("APP_HTTP_PORT"),
 		environment:       os.Getenv("APP_ENV"),
 		authJwtSecret:     os.Getenv("AUTH_JWT_SECRET"),
+		authJwtAlg:        jwtAlg,
+		authJwtKeyFile:    os.Getenv("AUTH_JWT_KEY_FILE"),
+		authJwtKeyID:      os.Getenv("AUTH_JWT_KEY_ID"),
+		authJwtKeyringDir: os.Getenv("AUTH_JWT_KEYRING_DIR"),
+		authJwtIssuer:     jwtIssuer,
+		authJwtAudience:   jwtAudience,
+		authJwtLeeway:     jwtLeeway,
+		refreshTokenTTL:   refreshTokenTTL,
+		mfaChallengeTTL:   mfaChallengeTTL,
+		webAuthnRPID:      webAuthnRPID,
+		webAuthnRPName:    webAuthnRPName,
+		webAuthnOrigins:   webAuthnOrigins,
+		loginMaxFailures:  loginMaxFailures,
+		loginIPFailures:   loginIPFailures,
+		lockoutDuration:   lockoutDuration,
+		rateLimitStore:    rateLimitStore,
+		rateLimitPolicies: rateLimitPolicies,
+		trustedProxies:    trustedProxies,
+		passwordPolicy:    passwordPolicy,
+		passwordHash:      passwordHash,
+		passwordResetTTL:  passwordResetTTL,
+		verificationTTL:   verificationTTL,
+		resendInterval:    resendInterval,
+		requireVerified:   requireVerified,
+		magicLinkTTL:      magicLinkTTL,
+		publicURL:         publicURL,
+		MailConfig:        mailConfig,
 		storage:           os.Getenv("APP_STORAGE"),
 		withFakeData:      withFakeData,
 		withTableTruncate: withTruncate,
+		trashRetention:    trashRetention,
+		purgeInterval:     purgeInterval,
 	}, nil
 }
@@ -0,0 +1,32 @@
+package dto
+
+// MFAChallengeResponseDTO represents the sign-in response of users with two-factor authentication enabled
+type MFAChallengeResponseDTO struct {
+	MFARequired  bool   `json:"mfaRequired"`
+	MFAToken     string `json:"mfaToken"`
+	MFAExpiresAt int64  `json:"mfaExpiresAt"`
+}
+
+// MFAVerifyRequestDTO represents the second sign-in step, completed with either a TOTP or a recovery code
+type MFAVerifyRequestDTO struct {
+	MFAToken     string `json:"mfaToken" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+// TOTPEnrollResponseDTO represents the secret to add to an authenticator app
+type TOTPEnrollResponseDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPCodeRequestDTO represents a code proving possession of the authenticator
+type TOTPCodeRequestDTO struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+// RecoveryCodesResponseDTO represents freshly generated recovery codes, shown only once
+type RecoveryCodesResponseDTO struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+// CreateProductDTO represents the data required to create a product
+type CreateProductDTO struct {
+	Name        string  `json:"name" validate:"required,max=255"`
+	Description string  `json:"description" validate:"max=4096"`
+	Price       float64 `json:"price" validate:"gte=0"`
+}
+
+// UpdateProductDTO represents the data replacing a product
+type UpdateProductDTO struct {
+	Name        string  `json:"name" validate:"required,max=255"`
+	Description string  `json:"description" validate:"max=4096"`
+	Price       float64 `json:"price" validate:"gte=0"`
+}
+
+// PatchProductDTO represents a partial product update, absent fields are left unchanged
+type PatchProductDTO struct {
+	Name        *string  `json:"name" validate:"omitempty,min=1,max=255"`
+	Description *string  `json:"description" validate:"omitempty,max=4096"`
+	Price       *float64 `json:"price" validate:"omitempty,gte=0"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+// SessionResponseDTO represents a client the user is signed in on
+type SessionResponseDTO struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
@@ -2,7 +2,25 @@ package dto
 
 // CreateUserDTO represents the data required to create a user
 type CreateUserDTO struct {
-	Username string `json:"username" validate:"required"`
+	Username string `json:"username" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateProfileDTO represents a partial update of the own account, absent fields are left unchanged.
+// Changing the username requires the current password.
+type UpdateProfileDTO struct {
+	Username        *string `json:"username" validate:"omitempty,email"`
+	CurrentPassword string  `json:"currentPassword" validate:"required_with=Username"`
+}
+
+// ChangePasswordDTO represents a password change of the own account
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+// DeleteAccountDTO represents the confirmation of deleting the own account
+type DeleteAccountDTO struct {
 	Password string `json:"password" validate:"required"`
 }
 
@@ -14,6 +32,44 @@ type AuthUserRequestDTO struct {
 
 // AuthUserResponseDTO represents the response after successful authentication
 type AuthUserResponseDTO struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expiresAt"`
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expiresAt"`
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresAt int64  `json:"refreshExpiresAt"`
+}
+
+// RefreshTokenRequestDTO represents the token refresh request data
+type RefreshTokenRequestDTO struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// ForgotPasswordRequestDTO represents a request for a password reset link
+type ForgotPasswordRequestDTO struct {
+	Username string `json:"username" validate:"required"`
+}
+
+// ResetPasswordRequestDTO represents a new password set with an emailed reset token
+type ResetPasswordRequestDTO struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// VerifyEmailRequestDTO represents the token of an emailed verification link
+type VerifyEmailRequestDTO struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequestDTO represents a request for a new verification link
+type ResendVerificationRequestDTO struct {
+	Username string `json:"username" validate:"required"`
+}
+
+// MagicLinkRequestDTO represents a request for a sign-in link
+type MagicLinkRequestDTO struct {
+	Username string `json:"username" validate:"required"`
+}
+
+// MagicLinkVerifyDTO represents the token of an emailed sign-in link
+type MagicLinkVerifyDTO struct {
+	Token string `json:"token" validate:"required"`
 }
@@ -0,0 +1,19 @@
+package dto
+
+import "github.com/SomchaiSPB/user-auth/internal/webauthn"
+
+// WebAuthnRegistrationDTO represents the credential created by the browser and an optional label for it
+type WebAuthnRegistrationDTO struct {
+	Name       string                          `json:"name" validate:"max=64"`
+	Credential webauthn.RegistrationCredential `json:"credential"`
+}
+
+// WebAuthnLoginBeginDTO represents the optional account to sign in to. Without it the authenticator offers its passkeys
+type WebAuthnLoginBeginDTO struct {
+	Username string `json:"username"`
+}
+
+// WebAuthnLoginDTO represents the assertion created by the browser
+type WebAuthnLoginDTO struct {
+	Credential webauthn.AssertionCredential `json:"credential"`
+}
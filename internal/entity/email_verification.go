@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+)
+
+// EmailVerificationToken is a hashed single-use token emailed to a user to
+// confirm the address. Email is the address it was sent to, as the user
+// may have changed it since.
+type EmailVerificationToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Email     string     `json:"email"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import (
+	"time"
+)
+
+// LoginFailure counts consecutive failed sign-ins of one subject, either an
+// account ("user:<name>") or a client address ("ip:<addr>").
+type LoginFailure struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	Subject       string    `json:"subject" gorm:"uniqueIndex"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at" gorm:"index"`
+}
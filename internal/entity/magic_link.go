@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+)
+
+// MagicLinkToken is a hashed single-use token emailed to a user signing in
+// without a password.
+type MagicLinkToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
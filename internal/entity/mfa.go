@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+)
+
+// MFAChallenge represents a pending second sign-in step. It is issued once
+// the password was verified and exchanged for tokens with a TOTP or
+// recovery code.
+type MFAChallenge struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	Attempts  int        `json:"attempts"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RecoveryCode represents a hashed single-use code replacing a TOTP code
+// when the authenticator is lost.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
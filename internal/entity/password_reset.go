@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a hashed single-use token emailed to a user who
+// forgot the password.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+)
+
+// RateLimitCounter is the state of one rate limiter key shared by all
+// instances, see ratelimit.State. Version is bumped on every update, which
+// locks the row for it.
+type RateLimitCounter struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Key       string    `json:"key" gorm:"uniqueIndex"`
+	Value     float64   `json:"value"`
+	Previous  float64   `json:"previous"`
+	Stamp     int64     `json:"stamp"`
+	Version   int64     `json:"version"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
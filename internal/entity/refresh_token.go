@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+)
+
+// RefreshToken represents a persisted opaque refresh token.
+// Tokens issued by rotating each other share the same FamilyID.
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	FamilyID  string     `json:"family_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
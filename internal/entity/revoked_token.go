@@ -0,0 +1,14 @@
+package entity
+
+import (
+	"time"
+)
+
+// RevokedToken represents a denylisted access token identified by its jti claim.
+// Rows are kept only until the token would have expired on its own.
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	JTI       string    `json:"jti" gorm:"uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const (
+	PermissionProductsRead  = "products:read"
+	PermissionProductsWrite = "products:write"
+	PermissionUsersRead     = "users:read"
+	PermissionUsersWrite    = "users:write"
+)
+
+// Role represents a named set of permissions granted to users
+type Role struct {
+	ID          uint          `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Name        string        `json:"name" gorm:"uniqueIndex"`
+	Permissions []*Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions"`
+}
+
+// Permission represents a single action a role allows, e.g. products:write
+type Permission struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name" gorm:"uniqueIndex"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"time"
+)
+
+// Session is a sign-in of a user on one client. It lives as long as the
+// refresh token family started by the sign-in, and access tokens carry its
+// ID so that revoking it signs the client out right away.
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	FamilyID   string     `json:"-" gorm:"uniqueIndex"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the session was neither revoked nor expired at now
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
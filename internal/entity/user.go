@@ -1,15 +1,71 @@
 package entity
 
 import (
+	"gorm.io/gorm"
 	"time"
 )
 
 // User represents a user entity
 type User struct {
-	ID        uint       `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
-	Name      string     `json:"name" gorm:"uniqueIndex"`
-	Password  string     `json:"password,omitempty"`
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index" swaggertype:"string"`
+	Name      string         `json:"name" gorm:"uniqueIndex"`
+	Password  string         `json:"password,omitempty"`
+	Roles     []*Role        `json:"roles,omitempty" gorm:"many2many:user_roles"`
+	// TOTPSecret is set on enrollment and only enforced once TOTPEnabledAt is set.
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at,omitempty"`
+	TOTPLastCounter int64      `json:"-"`
+	// EmailVerifiedAt is set once the user opened the link sent to the
+	// email address used as name.
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`
+	VerificationSentAt *time.Time `json:"-"`
+	// DisabledAt is set while an admin keeps the user from signing in.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Disabled reports whether an admin disabled the account
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// EmailVerified reports whether the user proved to own its email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MFAEnabled reports whether sign-in requires a second factor
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// RoleNames returns the names of the user roles
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+
+	for _, r := range u.Roles {
+		names = append(names, r.Name)
+	}
+
+	return names
+}
+
+// PermissionNames returns the distinct permissions granted by all user roles
+func (u *User) PermissionNames() []string {
+	seen := make(map[string]struct{})
+	names := make([]string, 0)
+
+	for _, r := range u.Roles {
+		for _, p := range r.Permissions {
+			if _, ok := seen[p.Name]; ok {
+				continue
+			}
+			seen[p.Name] = struct{}{}
+			names = append(names, p.Name)
+		}
+	}
+
+	return names
 }
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUserPermissionNames(t *testing.T) {
+	read := &Permission{Name: PermissionProductsRead}
+	write := &Permission{Name: PermissionProductsWrite}
+
+	tests := []struct {
+		name  string
+		roles []*Role
+		want  []string
+	}{
+		{name: "no roles", roles: nil, want: []string{}},
+		{name: "role without permissions", roles: []*Role{{Name: "guest"}}, want: []string{}},
+		{name: "one role", roles: []*Role{{Name: RoleUser, Permissions: []*Permission{read}}}, want: []string{PermissionProductsRead}},
+		{
+			name: "union of roles without duplicates",
+			roles: []*Role{
+				{Name: RoleUser, Permissions: []*Permission{read}},
+				{Name: "editor", Permissions: []*Permission{read, write}},
+			},
+			want: []string{PermissionProductsRead, PermissionProductsWrite},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &User{Roles: tt.roles}
+
+			if got := u.PermissionNames(); !slices.Equal(got, tt.want) {
+				t.Errorf("PermissionNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"time"
+)
+
+// WebAuthnCredential represents a passkey or security key registered by a user.
+// CredentialID is base64url encoded, PublicKey is the COSE encoded key.
+type WebAuthnCredential struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	UserID         uint       `json:"user_id" gorm:"index"`
+	Name           string     `json:"name"`
+	CredentialID   string     `json:"credential_id" gorm:"uniqueIndex"`
+	PublicKey      []byte     `json:"-"`
+	Algorithm      int        `json:"algorithm"`
+	SignCount      uint32     `json:"-"`
+	Transports     string     `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge represents a pending registration or login ceremony.
+// UserID is zero for logins that let the authenticator pick the account.
+type WebAuthnChallenge struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Challenge string    `json:"-" gorm:"uniqueIndex"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Ceremony  string    `json:"ceremony"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
@@ -0,0 +1,139 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+var ErrInvalidHash = errors.New("invalid argon2id hash")
+
+// Argon2Params tune Argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for Argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2Hasher hashes with Argon2id into PHC strings such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, recording the parameters
+// next to the hash.
+type Argon2Hasher struct {
+	params Argon2Params
+}
+
+func NewArgon2Hasher(params Argon2Params) Argon2Hasher {
+	return Argon2Hasher{params: params}
+}
+
+func (h Argon2Hasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return encodeArgon2(h.params, salt, key), nil
+}
+
+func (h Argon2Hasher) CheckPasswordHash(password, hashedPassword string) bool {
+	return checkAny(password, hashedPassword)
+}
+
+func (h Argon2Hasher) NeedsRehash(hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2(hashedPassword)
+
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func checkArgon2(password, hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2(hashedPassword)
+
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+func encodeArgon2(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		p.Memory,
+		p.Iterations,
+		p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(hashedPassword string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	parts := strings.Split(strings.TrimPrefix(hashedPassword, argon2idPrefix), "$")
+
+	if !strings.HasPrefix(hashedPassword, argon2idPrefix) || len(parts) != 4 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: unsupported version %s", ErrInvalidHash, parts[0])
+	}
+
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("%w: invalid key", ErrInvalidHash)
+	}
+
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, fmt.Errorf("%w: invalid parameters", ErrInvalidHash)
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,193 @@
+package hash
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keep the tests fast.
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestCheckArgon2ReferenceVector(t *testing.T) {
+	// from the test suite of the reference implementation
+	const hashed = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+	if !checkArgon2("password", hashed) {
+		t.Errorf("checkArgon2() rejects the reference hash")
+	}
+
+	if checkArgon2("Password", hashed) {
+		t.Errorf("checkArgon2() accepts a wrong password")
+	}
+}
+
+func TestArgon2HashPassword(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params)
+
+	hashed, err := h.HashPassword("correct horse")
+
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("HashPassword() = %s, want a PHC string with the parameters", hashed)
+	}
+
+	again, err := h.HashPassword("correct horse")
+
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if again == hashed {
+		t.Errorf("HashPassword() returned the same hash twice, salts must differ")
+	}
+
+	if !h.CheckPasswordHash("correct horse", hashed) || h.CheckPasswordHash("correct horse ", hashed) {
+		t.Errorf("CheckPasswordHash() does not tell the password apart")
+	}
+
+	if h.NeedsRehash(hashed) {
+		t.Errorf("NeedsRehash() of a current hash = true")
+	}
+}
+
+func TestArgon2EncodeDecode(t *testing.T) {
+	params := Argon2Params{Memory: 19456, Iterations: 2, Parallelism: 4, SaltLength: 3, KeyLength: 5}
+	salt := []byte{1, 2, 3}
+	key := []byte{4, 5, 6, 7, 8}
+
+	encoded := encodeArgon2(params, salt, key)
+
+	if want := "$argon2id$v=19$m=19456,t=2,p=4$AQID$BAUGBwg"; encoded != want {
+		t.Errorf("encodeArgon2() = %s, want %s", encoded, want)
+	}
+
+	gotParams, gotSalt, gotKey, err := decodeArgon2(encoded)
+
+	if err != nil {
+		t.Fatalf("decodeArgon2() error = %v", err)
+	}
+
+	if gotParams != params || string(gotSalt) != string(salt) || string(gotKey) != string(key) {
+		t.Errorf("decodeArgon2() = %+v, %v, %v, want %+v, %v, %v", gotParams, gotSalt, gotKey, params, salt, key)
+	}
+}
+
+func TestDecodeArgon2Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		hashed string
+	}{
+		{name: "empty", hashed: ""},
+		{name: "bcrypt", hashed: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{name: "argon2i", hashed: "$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{name: "missing version", hashed: "$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXP"},
+		{name: "old version", hashed: "$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXP"},
+		{name: "missing parameter", hashed: "$argon2id$v=19$m=65536,t=2$c29tZXNhbHQ$CTFhFdXP"},
+		{name: "zero iterations", hashed: "$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$CTFhFdXP"},
+		{name: "zero parallelism", hashed: "$argon2id$v=19$m=65536,t=2,p=0$c29tZXNhbHQ$CTFhFdXP"},
+		{name: "padded salt", hashed: "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ=$CTFhFdXP"},
+		{name: "empty key", hashed: "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$"},
+		{name: "extra field", hashed: "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXP$x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2(tt.hashed); !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("decodeArgon2() error = %v, want %v", err, ErrInvalidHash)
+			}
+
+			if checkArgon2("password", tt.hashed) {
+				t.Errorf("checkArgon2() accepts an invalid hash")
+			}
+		})
+	}
+}
+
+func TestArgon2NeedsRehash(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params)
+
+	hashWith := func(p Argon2Params) string {
+		hashed, err := NewArgon2Hasher(p).HashPassword("password")
+
+		if err != nil {
+			t.Fatalf("HashPassword() error = %v", err)
+		}
+
+		return hashed
+	}
+
+	bcryptHash, err := NewBcryptHasher(bcrypt.MinCost).HashPassword("password")
+
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	with := func(change func(p *Argon2Params)) Argon2Params {
+		p := testArgon2Params
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		hashed string
+		want   bool
+	}{
+		{name: "current parameters", hashed: hashWith(testArgon2Params), want: false},
+		{name: "less memory", hashed: hashWith(with(func(p *Argon2Params) { p.Memory = 32 })), want: true},
+		{name: "more iterations", hashed: hashWith(with(func(p *Argon2Params) { p.Iterations = 2 })), want: true},
+		{name: "other parallelism", hashed: hashWith(with(func(p *Argon2Params) { p.Parallelism = 2 })), want: true},
+		{name: "shorter salt", hashed: hashWith(with(func(p *Argon2Params) { p.SaltLength = 8 })), want: true},
+		{name: "longer key", hashed: hashWith(with(func(p *Argon2Params) { p.KeyLength = 64 })), want: true},
+		{name: "bcrypt", hashed: bcryptHash, want: true},
+		{name: "not a hash", hashed: "password", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.NeedsRehash(tt.hashed); got != tt.want {
+				t.Errorf("NeedsRehash() = %t, want %t", got, tt.want)
+			}
+
+			// whatever needs rehashing still verifies until it is replaced
+			if tt.hashed != "password" && !h.CheckPasswordHash("password", tt.hashed) {
+				t.Errorf("CheckPasswordHash() rejects the password")
+			}
+		})
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	h := NewBcryptHasher(bcrypt.MinCost)
+
+	argon2Hash, err := NewArgon2Hasher(testArgon2Params).HashPassword("password")
+
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	costlier, err := NewBcryptHasher(bcrypt.MinCost + 1).HashPassword("password")
+
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if !h.NeedsRehash(argon2Hash) || !h.NeedsRehash(costlier) {
+		t.Errorf("NeedsRehash() = false for a hash of another algorithm or cost")
+	}
+
+	if !h.CheckPasswordHash("password", argon2Hash) {
+		t.Errorf("CheckPasswordHash() of the bcrypt hasher rejects an argon2id hash")
+	}
+}
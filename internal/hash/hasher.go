@@ -1,6 +1,22 @@
 package hash
 
+import "strings"
+
+// Hasher hashes passwords and checks them against hashes of every
+// supported algorithm, so that stored hashes keep working after the
+// algorithm or its parameters changed. NeedsRehash reports the hashes that
+// were not made with the current ones.
 type Hasher interface {
 	HashPassword(password string) (string, error)
 	CheckPasswordHash(password, hashedPassword string) bool
+	NeedsRehash(hashedPassword string) bool
+}
+
+// checkAny verifies hashedPassword with the algorithm recorded in it.
+func checkAny(password, hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return checkArgon2(password, hashedPassword)
+	}
+
+	return checkBcrypt(password, hashedPassword)
 }
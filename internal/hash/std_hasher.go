@@ -2,14 +2,21 @@ package hash
 
 import "golang.org/x/crypto/bcrypt"
 
-type StdHasher struct{}
+// StdHasher hashes with bcrypt.
+type StdHasher struct {
+	cost int
+}
 
 func NewHasher() StdHasher {
-	return StdHasher{}
+	return NewBcryptHasher(bcrypt.DefaultCost)
+}
+
+func NewBcryptHasher(cost int) StdHasher {
+	return StdHasher{cost: cost}
 }
 
 func (h StdHasher) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
 
 	if err != nil {
 		return "", err
@@ -19,6 +26,16 @@ func (h StdHasher) HashPassword(password string) (string, error) {
 }
 
 func (h StdHasher) CheckPasswordHash(password, hashedPassword string) bool {
+	return checkAny(password, hashedPassword)
+}
+
+func (h StdHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+
+	return err != nil || cost != h.cost
+}
+
+func checkBcrypt(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 
 	return err == nil
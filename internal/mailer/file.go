@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSender appends messages to a file, or writes them to stdout, for
+// local development and tests.
+type FileSender struct {
+	mu   *sync.Mutex
+	path string
+	from string
+}
+
+// NewFileSender writes to path, to stdout when path is empty or "-".
+func NewFileSender(path, from string) FileSender {
+	return FileSender{mu: &sync.Mutex{}, path: path, from: from}
+}
+
+func (s FileSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var w io.Writer = os.Stdout
+
+	if s.path != "" && s.path != "-" {
+		f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	_, err := w.Write(append(format(s.from, msg), "\r\n"...))
+
+	return err
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPSender delivers messages through an SMTP server. smtp.SendMail
+// upgrades the connection with STARTTLS when the server offers it, and
+// credentials are only sent over TLS or to localhost.
+type SMTPSender struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPSender(host, port, username, password, from string) SMTPSender {
+	return SMTPSender{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (s SMTPSender) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.from)
+
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", s.from, err)
+	}
+
+	to, err := mail.ParseAddress(msg.To)
+
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+
+	var auth smtp.Auth
+
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	return smtp.SendMail(s.addr, auth, from.Address, []string{to.Address}, format(s.from, msg))
+}
@@ -0,0 +1,150 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+const (
+	pageParam    = "page"
+	perPageParam = "perPage"
+	afterParam   = "after"
+	beforeParam  = "before"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrBothCursors   = errors.New("after and before cursors are mutually exclusive")
+)
+
+// Cursor points at the row a page starts after or ends before. It is bound
+// to the sort order it was issued for.
+type Cursor struct {
+	ID   uint   `json:"id"`
+	Sort string `json:"s"`
+}
+
+// Encode returns the opaque form handed out to clients.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Request describes the requested page. Offset is only honoured when
+// no cursor is given and exists for clients still using page numbers.
+type Request struct {
+	Limit  int
+	Offset int
+	After  *Cursor
+	Before *Cursor
+}
+
+// FromQuery reads the perPage, after, before and legacy page parameters.
+// The page size falls back to DefaultLimit and is capped at MaxLimit.
+func FromQuery(query url.Values) (Request, error) {
+	req := Request{Limit: DefaultLimit}
+
+	if perPage, err := strconv.Atoi(query.Get(perPageParam)); err == nil && perPage > 0 {
+		req.Limit = min(perPage, MaxLimit)
+	}
+
+	if after := query.Get(afterParam); after != "" {
+		c, err := DecodeCursor(after)
+		if err != nil {
+			return req, err
+		}
+		req.After = c
+	}
+
+	if before := query.Get(beforeParam); before != "" {
+		if req.After != nil {
+			return req, ErrBothCursors
+		}
+		c, err := DecodeCursor(before)
+		if err != nil {
+			return req, err
+		}
+		req.Before = c
+	}
+
+	if page, err := strconv.Atoi(query.Get(pageParam)); err == nil && page > 1 && req.After == nil && req.Before == nil {
+		req.Offset = (page - 1) * req.Limit
+	}
+
+	return req, nil
+}
+
+// Meta describes a fetched page.
+type Meta struct {
+	Total int64
+	Limit int
+	Next  *Cursor
+	Prev  *Cursor
+}
+
+// Page is a fetched page of items.
+type Page[T any] struct {
+	Items []T
+	Meta
+}
+
+// WriteHeaders sets X-Total-Count and a Link header with next and prev
+// relations pointing at the current URL with the cursor swapped.
+func WriteHeaders(w http.ResponseWriter, r *http.Request, meta *Meta) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(meta.Total, 10))
+
+	var links []string
+
+	if meta.Next != nil {
+		links = append(links, link(r, meta.Limit, afterParam, meta.Next, "next"))
+	}
+
+	if meta.Prev != nil {
+		links = append(links, link(r, meta.Limit, beforeParam, meta.Prev, "prev"))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func link(r *http.Request, limit int, param string, c *Cursor, rel string) string {
+	query := r.URL.Query()
+	query.Del(pageParam)
+	query.Del(afterParam)
+	query.Del(beforeParam)
+	query.Set(perPageParam, strconv.Itoa(limit))
+	query.Set(param, c.Encode())
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
@@ -0,0 +1,144 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{ID: 1, Sort: "id"},
+		{ID: 4294967295, Sort: "-price,name,id"},
+		{ID: 7, Sort: ""},
+	}
+
+	for _, c := range tests {
+		t.Run(c.Sort, func(t *testing.T) {
+			got, err := DecodeCursor(c.Encode())
+
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+
+			if *got != c {
+				t.Errorf("DecodeCursor() = %+v, want %+v", *got, c)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"id":1}`))},
+		{name: "not json", cursor: encode("id=1")},
+		{name: "zero id", cursor: encode(`{"id":0,"s":"id"}`)},
+		{name: "missing id", cursor: encode(`{"s":"id"}`)},
+		{name: "negative id", cursor: encode(`{"id":-1,"s":"id"}`)},
+		{name: "string id", cursor: encode(`{"id":"1","s":"id"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestFromQuery(t *testing.T) {
+	after := Cursor{ID: 3, Sort: "id"}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Request
+		wantErr error
+	}{
+		{name: "defaults", query: "", want: Request{Limit: DefaultLimit}},
+		{name: "page size", query: "perPage=10", want: Request{Limit: 10}},
+		{name: "page size is capped", query: "perPage=1000", want: Request{Limit: MaxLimit}},
+		{name: "invalid page size", query: "perPage=-5", want: Request{Limit: DefaultLimit}},
+		{name: "legacy page", query: "perPage=10&page=3", want: Request{Limit: 10, Offset: 20}},
+		{name: "first page", query: "page=1", want: Request{Limit: DefaultLimit}},
+		{name: "after cursor", query: "after=" + after.Encode(), want: Request{Limit: DefaultLimit, After: &after}},
+		{name: "before cursor", query: "before=" + after.Encode(), want: Request{Limit: DefaultLimit, Before: &after}},
+		{name: "cursor wins over page", query: "page=3&after=" + after.Encode(), want: Request{Limit: DefaultLimit, After: &after}},
+		{name: "both cursors", query: "after=" + after.Encode() + "&before=" + after.Encode(), wantErr: ErrBothCursors},
+		{name: "invalid cursor", query: "after=x", wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatalf("parsing query: %v", err)
+			}
+
+			got, err := FromQuery(query)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromQuery() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	next := &Cursor{ID: 9, Sort: "id"}
+	prev := &Cursor{ID: 5, Sort: "id"}
+
+	tests := []struct {
+		name     string
+		meta     Meta
+		wantLink string
+	}{
+		{name: "single page", meta: Meta{Total: 3, Limit: 10}},
+		{
+			name:     "first page",
+			meta:     Meta{Total: 30, Limit: 10, Next: next},
+			wantLink: `</products?after=` + next.Encode() + `&name_contains=tea&perPage=10>; rel="next"`,
+		},
+		{
+			name: "middle page",
+			meta: Meta{Total: 30, Limit: 10, Next: next, Prev: prev},
+			wantLink: `</products?after=` + next.Encode() + `&name_contains=tea&perPage=10>; rel="next", ` +
+				`</products?before=` + prev.Encode() + `&name_contains=tea&perPage=10>; rel="prev"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/products?page=2&perPage=5&name_contains=tea", nil)
+
+			WriteHeaders(w, r, &tt.meta)
+
+			if got := w.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %s, want %s", got, tt.wantLink)
+			}
+
+			if got, want := w.Header().Get("X-Total-Count"), strconv.FormatInt(tt.meta.Total, 10); got != want {
+				t.Errorf("X-Total-Count = %s, want %s", got, want)
+			}
+		})
+	}
+}
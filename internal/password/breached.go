@@ -0,0 +1,106 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BreachedList holds the SHA-1 hashes of passwords known from data
+// breaches. Hashes are bucketed by their first two bytes and sorted, so a
+// lookup is a binary search in a small bucket.
+type BreachedList struct {
+	buckets [1 << 16][][sha1.Size]byte
+	size    int
+}
+
+// LoadBreachedList reads a file with one entry per line: either the hex
+// SHA-1 of a password, optionally followed by ":count" as in the Have I
+// Been Pwned downloads, or a password in plain text. Empty lines and lines
+// starting with # are skipped.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	l := &BreachedList{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		l.add(parseEntry(entry))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+	}
+
+	for i := range l.buckets {
+		slices.SortFunc(l.buckets[i], func(a, b [sha1.Size]byte) int {
+			return bytes.Compare(a[:], b[:])
+		})
+		l.buckets[i] = slices.CompactFunc(l.buckets[i], func(a, b [sha1.Size]byte) bool {
+			return a == b
+		})
+	}
+
+	for _, b := range l.buckets {
+		l.size += len(b)
+	}
+
+	return l, nil
+}
+
+// Len is the number of distinct hashes in the list.
+func (l *BreachedList) Len() int {
+	return l.size
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	bucket := l.buckets[bucketOf(sum)]
+
+	_, found := slices.BinarySearchFunc(bucket, sum, func(a, b [sha1.Size]byte) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	return found
+}
+
+func (l *BreachedList) add(sum [sha1.Size]byte) {
+	b := bucketOf(sum)
+	l.buckets[b] = append(l.buckets[b], sum)
+}
+
+func parseEntry(entry string) [sha1.Size]byte {
+	hexHash, _, _ := strings.Cut(entry, ":")
+	var sum [sha1.Size]byte
+
+	if len(hexHash) == 2*sha1.Size {
+		if _, err := hex.Decode(sum[:], []byte(hexHash)); err == nil {
+			return sum
+		}
+	}
+
+	return sha1.Sum([]byte(entry))
+}
+
+func bucketOf(sum [sha1.Size]byte) int {
+	return int(sum[0])<<8 | int(sum[1])
+}
@@ -0,0 +1,72 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestBreachedList(t *testing.T, content string) *BreachedList {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing list: %v", err)
+	}
+
+	l, err := LoadBreachedList(path)
+
+	if err != nil {
+		t.Fatalf("LoadBreachedList() error = %v", err)
+	}
+
+	return l
+}
+
+func TestBreachedList(t *testing.T) {
+	l := newTestBreachedList(t, `# sha1 of "password" as in the Have I Been Pwned downloads
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+
+# lower case hex without a count, sha1 of "123456"
+7c4a8d09ca3762af61e59520943dc26494f8941b
+  letmein  
+qwerty
+qwerty
+5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8
+not-hex-but-40-characters-long-0123456789
+`)
+
+	if got, want := l.Len(), 5; got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "password", want: true},
+		{password: "123456", want: true},
+		{password: "letmein", want: true},
+		{password: "qwerty", want: true},
+		{password: "not-hex-but-40-characters-long-0123456789", want: true},
+		{password: "Password", want: false},
+		{password: "  letmein  ", want: false},
+		{password: "# sha1 of \"password\" as in the Have I Been Pwned downloads", want: false},
+		{password: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := l.Contains(tt.password); got != tt.want {
+				t.Errorf("Contains() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedListMissingFile(t *testing.T) {
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("LoadBreachedList() error = %v, want a missing file error", err)
+	}
+}
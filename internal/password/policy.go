@@ -0,0 +1,233 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules reported in violations.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleClasses   = "character_classes"
+	RuleEntropy   = "entropy"
+	RuleUsername  = "username"
+	RuleBreached  = "breached"
+)
+
+// minUserPartLen keeps short name parts from rejecting unrelated passwords.
+const minUserPartLen = 4
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// Violation is one rule a password breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks, so that users can fix
+// them all at once.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(messages, "; "))
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Policy tells which passwords are accepted. Lengths count characters,
+// MinClasses out of lower case letters, upper case letters, digits and
+// symbols, and MinEntropy is in bits as estimated by Entropy. Zero values
+// disable a rule; a nil Breached skips the screening.
+type Policy struct {
+	MinLength  int
+	MaxLength  int
+	MinClasses int
+	MinEntropy float64
+	Breached   *BreachedList
+}
+
+// Check returns a *PolicyError when password breaks a rule. Passwords
+// must not contain the username, or the parts of an email username.
+func (p Policy) Check(password, username string) error {
+	var violations []Violation
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters long", p.MaxLength),
+		})
+	}
+
+	if classes := len(characterClasses(password)); classes < p.MinClasses {
+		violations = append(violations, Violation{
+			Rule:    RuleClasses,
+			Message: fmt.Sprintf("must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses),
+		})
+	}
+
+	if p.MinEntropy > 0 && Entropy(password) < p.MinEntropy {
+		violations = append(violations, Violation{
+			Rule:    RuleEntropy,
+			Message: "is too easy to guess, use a longer or less predictable password",
+		})
+	}
+
+	if containsUsername(password, username) {
+		violations = append(violations, Violation{
+			Rule:    RuleUsername,
+			Message: "must not contain the username",
+		})
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, Violation{
+			Rule:    RuleBreached,
+			Message: "appeared in a data breach, choose another one",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+type characterClass int
+
+const (
+	classLower characterClass = iota
+	classUpper
+	classDigit
+	classSymbol
+	classOther
+)
+
+// classPoolSizes are the number of characters an attacker has to try for
+// each class.
+var classPoolSizes = map[characterClass]float64{
+	classLower:  26,
+	classUpper:  26,
+	classDigit:  10,
+	classSymbol: 33,
+	classOther:  100,
+}
+
+func classOf(r rune) characterClass {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return classLower
+	case r >= 'A' && r <= 'Z':
+		return classUpper
+	case r >= '0' && r <= '9':
+		return classDigit
+	case r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' '):
+		return classSymbol
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	}
+
+	return classOther
+}
+
+func characterClasses(password string) map[characterClass]struct{} {
+	classes := map[characterClass]struct{}{}
+
+	for _, r := range password {
+		if c := classOf(r); c != classOther {
+			classes[c] = struct{}{}
+		}
+	}
+
+	return classes
+}
+
+// Entropy estimates the bits of a password from the characters it draws
+// from. Characters repeating or continuing a sequence of the one before,
+// as in "aaa" or "1234", count for half. It is a rough upper bound meant
+// to reject short and patterned passwords, the breached list catches
+// common ones.
+func Entropy(password string) float64 {
+	var pool float64
+	seen := map[characterClass]struct{}{}
+
+	for _, r := range password {
+		c := classOf(r)
+
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			pool += classPoolSizes[c]
+		}
+	}
+
+	if pool == 0 {
+		return 0
+	}
+
+	var length float64
+	prev := rune(-1)
+
+	for _, r := range password {
+		if d := r - prev; d >= -1 && d <= 1 {
+			length += 0.5
+		} else {
+			length++
+		}
+
+		prev = r
+	}
+
+	return length * math.Log2(pool)
+}
+
+func containsUsername(password, username string) bool {
+	password = strings.ToLower(password)
+	username = strings.ToLower(strings.TrimSpace(username))
+
+	if username == "" {
+		return false
+	}
+
+	parts := []string{username}
+
+	if local, _, ok := strings.Cut(username, "@"); ok {
+		parts = append(parts, local)
+		parts = append(parts, strings.FieldsFunc(local, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minUserPartLen && strings.Contains(password, part) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package password
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	breached := newTestBreachedList(t, "Tr0ub4dor&3\n")
+
+	p := Policy{MinLength: 8, MaxLength: 64, MinClasses: 3, MinEntropy: 40, Breached: breached}
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     []string
+	}{
+		{name: "strong", password: "Vq8#mW2!zLp", username: "jane@example.com"},
+		{name: "too short", password: "aB3$", want: []string{RuleMinLength, RuleEntropy}},
+		{name: "length counts characters", password: "Vq8#" + strings.Repeat("ü", 60)},
+		{name: "too long", password: "aB3$" + string(make([]byte, 61)), want: []string{RuleMaxLength}},
+		{name: "one class", password: "qwzmxnvbtr", want: []string{RuleClasses}},
+		{name: "sequences", password: "Abcdefg123", want: []string{RuleEntropy}},
+		{name: "contains username", password: "xX-Jane.Doe-Xx9", username: "jane.doe@example.com", want: []string{RuleUsername}},
+		{name: "contains email local part", password: "Tr1ck-doe4ever", username: "jane_doe4@example.com", want: []string{RuleUsername}},
+		{name: "short username parts are allowed", password: "Vq8#mW2!zLp-jo", username: "jo@example.com"},
+		{name: "breached", password: "Tr0ub4dor&3", want: []string{RuleBreached}},
+		{
+			name:     "every violation at once",
+			password: "jane",
+			username: "jane",
+			want:     []string{RuleMinLength, RuleClasses, RuleEntropy, RuleUsername},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.password, tt.username)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Check() error = %v", err)
+				}
+				return
+			}
+
+			var policyErr *PolicyError
+
+			if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("Check() error = %v, want a *PolicyError", err)
+			}
+
+			var got []string
+
+			for _, v := range policyErr.Violations {
+				got = append(got, v.Rule)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violated rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZeroPolicyAcceptsAnything(t *testing.T) {
+	for _, password := range []string{"", "a", "password"} {
+		if err := (Policy{}).Check(password, ""); err != nil {
+			t.Errorf("Check(%q) error = %v", password, err)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{password: "", want: 0},
+		{password: "a", want: math.Log2(26)},
+		// repeats and sequences count for half
+		{password: "aaaa", want: 2.5 * math.Log2(26)},
+		{password: "abcd", want: 2.5 * math.Log2(26)},
+		{password: "dcba", want: 2.5 * math.Log2(26)},
+		{password: "azaz", want: 4 * math.Log2(26)},
+		{password: "aZ", want: 2 * math.Log2(52)},
+		{password: "a1!", want: 3 * math.Log2(26+10+33)},
+		{password: "aZ1!", want: 4 * math.Log2(26+26+10+33)},
+		{password: "ж", want: math.Log2(26)},
+		{password: "中", want: math.Log2(100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := Entropy(tt.password); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Entropy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package principal
+
+import (
+	"context"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"slices"
+	"strconv"
+)
+
+var ErrInvalidSubject = errors.New("token subject is not a user id")
+
+type ctxKey struct{}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID      uint
+	Username    string
+	Roles       []string
+	Permissions []string
+	Claims      *signing.Claims
+}
+
+// FromClaims builds the principal described by verified access token claims.
+func FromClaims(c *signing.Claims) (*Principal, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+
+	if err != nil {
+		return nil, ErrInvalidSubject
+	}
+
+	return &Principal{
+		UserID:      uint(id),
+		Username:    c.Username,
+		Roles:       c.Roles,
+		Permissions: c.Permissions,
+		Claims:      c,
+	}, nil
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal stored by the token middleware.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+
+	return p, ok
+}
+
+// UserID returns the id of the authenticated user.
+func UserID(ctx context.Context) (uint, bool) {
+	p, ok := FromContext(ctx)
+
+	if !ok {
+		return 0, false
+	}
+
+	return p.UserID, true
+}
@@ -0,0 +1,49 @@
+package principal
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+)
+
+func TestFromClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		wantID  uint
+		wantErr error
+	}{
+		{name: "user id", subject: "42", wantID: 42},
+		{name: "empty subject", subject: "", wantErr: ErrInvalidSubject},
+		{name: "not a number", subject: "admin", wantErr: ErrInvalidSubject},
+		{name: "negative", subject: "-1", wantErr: ErrInvalidSubject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &signing.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: tt.subject},
+				Permissions:      []string{"products:read"},
+			}
+
+			p, err := FromClaims(claims)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromClaims() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if p.UserID != tt.wantID {
+				t.Errorf("UserID = %d, want %d", p.UserID, tt.wantID)
+			}
+
+			if !p.HasPermission("products:read") || p.HasPermission("products:write") {
+				t.Errorf("permissions of %v are not the ones of the claims", p.Permissions)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	// TokenBucket allows bursts of Limit requests and refills Limit tokens
+	// per Window at a steady rate.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Limit requests in any Window, weighting the
+	// count of the previous fixed window by how much of it still overlaps.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// KeyKind tells what requests of one client have in common.
+type KeyKind string
+
+const (
+	KeyIP   KeyKind = "ip"
+	KeyUser KeyKind = "user"
+)
+
+var ErrInvalidPolicy = errors.New("invalid rate limit policy")
+
+// Policy limits the requests to the routes starting with Path, and to one
+// method only when Method is set.
+type Policy struct {
+	Method    string
+	Path      string
+	Algorithm Algorithm
+	Limit     int
+	Window    time.Duration
+	Key       KeyKind
+}
+
+// ID identifies the policy in store keys.
+func (p Policy) ID() string {
+	return strings.TrimSpace(p.Method + " " + p.Path)
+}
+
+// Header is the value of the RateLimit-Policy response header.
+func (p Policy) Header() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(math.Ceil(p.Window.Seconds())))
+}
+
+func (p Policy) matches(method, path string) bool {
+	if p.Method != "" && p.Method != method {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(p.Path, "/")
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// ParsePolicies reads policies separated by semicolons, each written as
+// "[METHOD] PATH=ALGORITHM LIMIT/WINDOW [KEY]", for example
+// "POST /auth/sign-in=sliding_window 10/1m ip". KEY defaults to ip and
+// "none" disables rate limiting.
+func ParsePolicies(s string) ([]Policy, error) {
+	if strings.TrimSpace(s) == "none" {
+		return nil, nil
+	}
+
+	var policies []Policy
+
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		p, err := parsePolicy(entry)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPolicy, strings.TrimSpace(entry), err)
+		}
+
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+func parsePolicy(entry string) (Policy, error) {
+	route, rule, ok := strings.Cut(entry, "=")
+
+	if !ok {
+		return Policy{}, errors.New("missing =")
+	}
+
+	var p Policy
+	routeFields := strings.Fields(route)
+
+	switch len(routeFields) {
+	case 1:
+		p.Path = routeFields[0]
+	case 2:
+		p.Method, p.Path = strings.ToUpper(routeFields[0]), routeFields[1]
+	default:
+		return Policy{}, errors.New("route must be a path with an optional method")
+	}
+
+	if !strings.HasPrefix(p.Path, "/") {
+		return Policy{}, errors.New("path must start with /")
+	}
+
+	ruleFields := strings.Fields(rule)
+
+	if len(ruleFields) < 2 || len(ruleFields) > 3 {
+		return Policy{}, errors.New("rule must be ALGORITHM LIMIT/WINDOW [KEY]")
+	}
+
+	p.Algorithm = Algorithm(ruleFields[0])
+
+	if p.Algorithm != TokenBucket && p.Algorithm != SlidingWindow {
+		return Policy{}, fmt.Errorf("unknown algorithm %s", p.Algorithm)
+	}
+
+	limit, window, ok := strings.Cut(ruleFields[1], "/")
+
+	if !ok {
+		return Policy{}, errors.New("rate must be LIMIT/WINDOW")
+	}
+
+	var err error
+
+	if p.Limit, err = strconv.Atoi(limit); err != nil || p.Limit <= 0 {
+		return Policy{}, fmt.Errorf("limit %s must be a positive integer", limit)
+	}
+
+	if p.Window, err = time.ParseDuration(window); err != nil || p.Window <= 0 {
+		return Policy{}, fmt.Errorf("window %s must be a positive duration", window)
+	}
+
+	p.Key = KeyIP
+
+	if len(ruleFields) == 3 {
+		p.Key = KeyKind(ruleFields[2])
+	}
+
+	if p.Key != KeyIP && p.Key != KeyUser {
+		return Policy{}, fmt.Errorf("unknown key %s", p.Key)
+	}
+
+	return p, nil
+}
+
+// Result is the outcome of a request against a policy.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the quota is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until a denied request would be allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter applies the most specific of its policies to each request.
+type Limiter struct {
+	store    Store
+	policies []Policy
+}
+
+func NewLimiter(store Store, policies []Policy) *Limiter {
+	return &Limiter{store: store, policies: policies}
+}
+
+// Policy returns the policy with the longest path matching the request;
+// on equal paths a policy for the method wins over one for any method.
+func (l *Limiter) Policy(method, path string) (Policy, bool) {
+	var best Policy
+	found := false
+
+	for _, p := range l.policies {
+		if !p.matches(method, path) {
+			continue
+		}
+
+		if !found || len(p.Path) > len(best.Path) || len(p.Path) == len(best.Path) && p.Method != "" && best.Method == "" {
+			best = p
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// Allow counts a request of the client identified by key against p.
+func (l *Limiter) Allow(p Policy, key string, now time.Time) (Result, error) {
+	storeKey := p.ID() + "|" + key
+
+	switch p.Algorithm {
+	case TokenBucket:
+		return l.takeToken(p, storeKey, now)
+	case SlidingWindow:
+		return l.countInWindow(p, storeKey, now)
+	}
+
+	return Result{}, fmt.Errorf("%w: unknown algorithm %s", ErrInvalidPolicy, p.Algorithm)
+}
+
+func (l *Limiter) DeleteExpired(now time.Time) (int64, error) {
+	return l.store.DeleteExpired(now)
+}
+
+func (l *Limiter) takeToken(p Policy, key string, now time.Time) (Result, error) {
+	limit := float64(p.Limit)
+	// tokens refilled per nanosecond
+	rate := limit / float64(p.Window)
+	var res Result
+
+	err := l.store.Update(key, now.Add(p.Window), func(s *State) {
+		if s.Stamp == 0 {
+			s.Value = limit
+		} else {
+			elapsed := max(0, now.UnixNano()-s.Stamp)
+			s.Value = min(limit, s.Value+float64(elapsed)*rate)
+		}
+
+		s.Stamp = now.UnixNano()
+
+		res = Result{Limit: p.Limit}
+
+		if s.Value >= 1 {
+			s.Value--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration((1 - s.Value) / rate)
+		}
+
+		res.Remaining = int(s.Value)
+		res.Reset = time.Duration((limit - s.Value) / rate)
+	})
+
+	return res, err
+}
+
+func (l *Limiter) countInWindow(p Policy, key string, now time.Time) (Result, error) {
+	limit := float64(p.Limit)
+	window := p.Window.Nanoseconds()
+	start := now.UnixNano() - now.UnixNano()%window
+	// fraction of the current window that has passed
+	elapsed := float64(now.UnixNano()-start) / float64(window)
+	var res Result
+
+	err := l.store.Update(key, time.Unix(0, start).Add(2*p.Window), func(s *State) {
+		if s.Stamp != start {
+			if s.Stamp == start-window {
+				s.Previous = s.Value
+			} else {
+				s.Previous = 0
+			}
+
+			s.Value = 0
+			s.Stamp = start
+		}
+
+		count := s.Previous*(1-elapsed) + s.Value
+
+		res = Result{Limit: p.Limit}
+
+		if count+1 <= limit {
+			s.Value++
+			count++
+			res.Allowed = true
+		} else {
+			res.RetryAfter = slidingRetryAfter(s, limit, elapsed, p.Window)
+		}
+
+		res.Remaining = max(0, int(limit-count))
+
+		// requests stop counting once the window after theirs has passed
+		switch {
+		case s.Value > 0:
+			res.Reset = time.Duration(float64(window) * (2 - elapsed))
+		case s.Previous > 0:
+			res.Reset = time.Duration(float64(window) * (1 - elapsed))
+		}
+	})
+
+	return res, err
+}
+
+// slidingRetryAfter is the time until the weighted count drops enough to
+// allow one more request.
+func slidingRetryAfter(s *State, limit, elapsed float64, window time.Duration) time.Duration {
+	var wait float64
+
+	switch {
+	case s.Value <= limit-1 && s.Previous > 0:
+		// the previous window fades out enough before this one ends
+		wait = 1 - elapsed - (limit-1-s.Value)/s.Previous
+	default:
+		// this window alone is over the limit, wait for it to fade out
+		wait = 1 - elapsed + 1 - (limit-1)/s.Value
+	}
+
+	return time.Duration(max(0, wait) * float64(window))
+}
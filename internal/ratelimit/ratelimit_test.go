@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Policy
+		wantErr bool
+	}{
+		{name: "none", input: "none"},
+		{name: "empty", input: " ; "},
+		{
+			name:  "default key",
+			input: "/auth=sliding_window 60/1m",
+			want:  []Policy{{Path: "/auth", Algorithm: SlidingWindow, Limit: 60, Window: time.Minute, Key: KeyIP}},
+		},
+		{
+			name:  "method and key",
+			input: "post /auth/sign-in=token_bucket 10/30s user; /api=sliding_window 5/1h ip",
+			want: []Policy{
+				{Method: "POST", Path: "/auth/sign-in", Algorithm: TokenBucket, Limit: 10, Window: 30 * time.Second, Key: KeyUser},
+				{Path: "/api", Algorithm: SlidingWindow, Limit: 5, Window: time.Hour, Key: KeyIP},
+			},
+		},
+		{name: "missing =", input: "/auth sliding_window 60/1m", wantErr: true},
+		{name: "relative path", input: "auth=sliding_window 60/1m", wantErr: true},
+		{name: "too many route fields", input: "GET /a /b=sliding_window 60/1m", wantErr: true},
+		{name: "unknown algorithm", input: "/auth=leaky_bucket 60/1m", wantErr: true},
+		{name: "missing window", input: "/auth=sliding_window 60", wantErr: true},
+		{name: "zero limit", input: "/auth=sliding_window 0/1m", wantErr: true},
+		{name: "negative window", input: "/auth=sliding_window 1/-1m", wantErr: true},
+		{name: "unknown key", input: "/auth=sliding_window 60/1m session", wantErr: true},
+		{name: "api key", input: "/api=sliding_window 5/1h api_key", wantErr: true},
+		{name: "one invalid entry", input: "/a=token_bucket 1/1s;/b=token_bucket x/1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicies(tt.input)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPolicy) {
+					t.Errorf("ParsePolicies() error = %v, want %v", err, ErrInvalidPolicy)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParsePolicies() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePolicies() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterPolicy(t *testing.T) {
+	policies, err := ParsePolicies("/auth=sliding_window 60/1m;POST /auth=sliding_window 30/1m;" +
+		"/auth/sign-in=sliding_window 10/1m;/api/v1/=token_bucket 600/1m user")
+
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	l := NewLimiter(NewMemoryStore(), policies)
+
+	tests := []struct {
+		method, path string
+		wantLimit    int
+		wantFound    bool
+	}{
+		{method: "GET", path: "/auth", wantLimit: 60, wantFound: true},
+		{method: "POST", path: "/auth/refresh", wantLimit: 30, wantFound: true},
+		{method: "POST", path: "/auth/sign-in", wantLimit: 10, wantFound: true},
+		{method: "GET", path: "/auth/sign-in/mfa", wantLimit: 10, wantFound: true},
+		{method: "GET", path: "/api/v1/products", wantLimit: 600, wantFound: true},
+		{method: "GET", path: "/api/v1", wantLimit: 600, wantFound: true},
+		{method: "GET", path: "/authors", wantFound: false},
+		{method: "GET", path: "/", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			p, found := l.Policy(tt.method, tt.path)
+
+			if found != tt.wantFound || found && p.Limit != tt.wantLimit {
+				t.Errorf("Policy() = %+v, %t, want limit %d, %t", p, found, tt.wantLimit, tt.wantFound)
+			}
+		})
+	}
+}
+
+// request is a request at offset from the start of a window, expected to
+// produce the given result.
+type request struct {
+	at            time.Duration
+	wantAllowed   bool
+	wantRemaining int
+	wantRetry     time.Duration
+	wantReset     time.Duration
+}
+
+func runRequests(t *testing.T, p Policy, requests []request) {
+	t.Helper()
+
+	l := NewLimiter(NewMemoryStore(), []Policy{p})
+	// in the future and on a window boundary, as the memory store expires
+	// states by the wall clock
+	base := time.Now().Truncate(p.Window).Add(p.Window)
+
+	for i, r := range requests {
+		got, err := l.Allow(p, "ip:192.0.2.1", base.Add(r.at))
+
+		if err != nil {
+			t.Fatalf("request %d: Allow() error = %v", i, err)
+		}
+
+		if got.Allowed != r.wantAllowed || got.Remaining != r.wantRemaining || got.Limit != p.Limit {
+			t.Errorf("request %d at %v: Allow() = %+v, want allowed %t with %d remaining",
+				i, r.at, got, r.wantAllowed, r.wantRemaining)
+		}
+
+		if !near(got.RetryAfter, r.wantRetry) || !near(got.Reset, r.wantReset) {
+			t.Errorf("request %d at %v: RetryAfter = %v, Reset = %v, want %v and %v",
+				i, r.at, got.RetryAfter, got.Reset, r.wantRetry, r.wantReset)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// one token every 20 seconds, bursts of three
+	p := Policy{Path: "/", Algorithm: TokenBucket, Limit: 3, Window: time.Minute}
+
+	runRequests(t, p, []request{
+		{at: 0, wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+		{at: 0, wantAllowed: true, wantRemaining: 1, wantReset: 40 * time.Second},
+		{at: 0, wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+		{at: 0, wantAllowed: false, wantRemaining: 0, wantRetry: 20 * time.Second, wantReset: time.Minute},
+		{at: 10 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 10 * time.Second, wantReset: 50 * time.Second},
+		{at: 20 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+		{at: 50 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 50 * time.Second},
+		// refills stop at the burst size
+		{at: 10 * time.Minute, wantAllowed: true, wantRemaining: 2, wantReset: 20 * time.Second},
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	p := Policy{Path: "/", Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+
+	runRequests(t, p, []request{
+		{at: 0, wantAllowed: true, wantRemaining: 3, wantReset: 2 * time.Minute},
+		{at: 15 * time.Second, wantAllowed: true, wantRemaining: 2, wantReset: 105 * time.Second},
+		{at: 15 * time.Second, wantAllowed: true, wantRemaining: 1, wantReset: 105 * time.Second},
+		{at: 30 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 90 * time.Second},
+		// this window alone is full: 4 * (1 - 0.25) + 0 only drops to 3 at 75s
+		{at: 30 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 45 * time.Second, wantReset: 90 * time.Second},
+		// the previous window weighs 4 * 0.75 = 3
+		{at: 75 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 105 * time.Second},
+		// 4 * 0.5 + 1 = 3
+		{at: 90 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 90 * time.Second},
+		// 4 * 0.5 + 2 = 4, 3 is reached once the previous window weighs 1 at 105s
+		{at: 90 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 15 * time.Second, wantReset: 90 * time.Second},
+		{at: 105 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 75 * time.Second},
+		// two windows later nothing counts any more
+		{at: 4 * time.Minute, wantAllowed: true, wantRemaining: 3, wantReset: 2 * time.Minute},
+	})
+}
+
+func TestAllowKeepsClientsApart(t *testing.T) {
+	p := Policy{Path: "/", Algorithm: SlidingWindow, Limit: 1, Window: time.Minute}
+	l := NewLimiter(NewMemoryStore(), []Policy{p})
+	now := time.Now()
+
+	for _, key := range []string{"ip:192.0.2.1", "ip:192.0.2.2", "user:1"} {
+		if res, err := l.Allow(p, key, now); err != nil || !res.Allowed {
+			t.Errorf("Allow(%s) = %+v, %v, want allowed", key, res, err)
+		}
+	}
+
+	if res, err := l.Allow(p, "ip:192.0.2.1", now); err != nil || res.Allowed {
+		t.Errorf("second Allow() = %+v, %v, want denied", res, err)
+	}
+}
+
+func near(got, want time.Duration) bool {
+	d := got - want
+
+	return d > -time.Millisecond && d < time.Millisecond
+}
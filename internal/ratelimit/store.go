@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// State is what a limiter keeps per key. Token buckets store the tokens
+// left in Value and the last refill in Stamp; sliding windows store the
+// count of the current window in Value, the one of the window before in
+// Previous and the start of the current window in Stamp. Stamps are Unix
+// nanoseconds, zero for a key seen for the first time.
+type State struct {
+	Value    float64
+	Previous float64
+	Stamp    int64
+}
+
+// Store keeps the limiter states. Update applies fn to the state of key
+// atomically and keeps the result until expiresAt; expired states are
+// passed to fn as zero values. Implementations may call fn more than once
+// when they lose a race against another instance.
+type Store interface {
+	Update(key string, expiresAt time.Time, fn func(s *State)) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the states of a single instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*memoryEntry{}}
+}
+
+func (s *MemoryStore) Update(key string, expiresAt time.Time, fn func(s *State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+
+	if !ok {
+		e = &memoryEntry{}
+		s.entries[key] = e
+	} else if time.Now().After(e.expiresAt) {
+		e.state = State{}
+	}
+
+	fn(&e.state)
+	e.expiresAt = expiresAt
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+
+	for key, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type EmailVerificationTokenDBRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailVerificationTokenDBRepository(db *gorm.DB) EmailVerificationTokenDBRepository {
+	return EmailVerificationTokenDBRepository{db: db}
+}
+
+func (r EmailVerificationTokenDBRepository) Create(t *entity.EmailVerificationToken) (*entity.EmailVerificationToken, error) {
+	return t, r.db.Create(&t).Error
+}
+
+func (r EmailVerificationTokenDBRepository) GetByHash(tokenHash string) (*entity.EmailVerificationToken, error) {
+	var t *entity.EmailVerificationToken
+
+	return t, r.db.Where("token_hash = ?", tokenHash).First(&t).Error
+}
+
+// Consume marks the token as used. It reports false when the token had
+// already been used.
+func (r EmailVerificationTokenDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r EmailVerificationTokenDBRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&entity.EmailVerificationToken{}).Error
+}
+
+func (r EmailVerificationTokenDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.EmailVerificationToken{})
+
+	return res.RowsAffected, res.Error
+}
@@ -1,6 +1,73 @@
 package repository
 
+import (
+	"strings"
+	"time"
+)
+
 type Filter interface {
 	Query() interface{}
 	Args() interface{}
 }
+
+// Sort orders a list by a column. Columns must come from a whitelist,
+// never from user input directly.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// columnFilter compares a fixed column with a bound value. Column and
+// operator are only set by the constructors below.
+type columnFilter struct {
+	query string
+	arg   interface{}
+}
+
+func (f columnFilter) Query() interface{} {
+	return f.query
+}
+
+func (f columnFilter) Args() interface{} {
+	return f.arg
+}
+
+func PriceGte(price float64) Filter {
+	return columnFilter{query: "price >= ?", arg: price}
+}
+
+func PriceLte(price float64) Filter {
+	return columnFilter{query: "price <= ?", arg: price}
+}
+
+func CreatedAfter(t time.Time) Filter {
+	return columnFilter{query: "created_at > ?", arg: t}
+}
+
+func CreatedBefore(t time.Time) Filter {
+	return columnFilter{query: "created_at < ?", arg: t}
+}
+
+// Disabled matches users whose account is disabled, or enabled when
+// disabled is false.
+func Disabled(disabled bool) Filter {
+	return columnFilter{query: "(disabled_at IS NOT NULL) = ?", arg: disabled}
+}
+
+// EmailVerified matches users who verified their email address, or did
+// not when verified is false.
+func EmailVerified(verified bool) Filter {
+	return columnFilter{query: "(email_verified_at IS NOT NULL) = ?", arg: verified}
+}
+
+// NameContains matches names containing s case-insensitively, LIKE
+// wildcards in s are matched literally.
+func NameContains(s string) Filter {
+	return columnFilter{query: `LOWER(name) LIKE ? ESCAPE '\'`, arg: "%" + escapeLike(strings.ToLower(s)) + "%"}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
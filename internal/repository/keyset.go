@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"strings"
+)
+
+const idColumn = "id"
+
+// findPage fetches one page of T ordered by sorts using keyset pagination.
+// scope returns a fresh query with the list filters applied; the id column
+// is appended as the final sort key so every row has a unique position.
+func findPage[T any](db *gorm.DB, scope func() *gorm.DB, sorts []Sort, req pagination.Request, idOf func(*T) uint) (*pagination.Page[*T], error) {
+	if !slices.ContainsFunc(sorts, func(s Sort) bool { return s.Column == idColumn }) {
+		sorts = append(sorts, Sort{Column: idColumn})
+	}
+
+	signature := sortSignature(sorts)
+
+	page := &pagination.Page[*T]{Meta: pagination.Meta{Limit: req.Limit}}
+
+	if err := scope().Model(new(T)).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := scope()
+	backward := req.Before != nil
+
+	if cursor := cmpOr(req.After, req.Before); cursor != nil {
+		if cursor.Sort != signature {
+			return nil, pagination.ErrInvalidCursor
+		}
+
+		cond, args, err := keysetCondition(db, new(T), sorts, cursor.ID, backward)
+
+		if err != nil {
+			return nil, err
+		}
+
+		query = query.Where(cond, args...)
+	} else if req.Offset > 0 {
+		query = query.Offset(req.Offset)
+	}
+
+	for _, s := range sorts {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc != backward})
+	}
+
+	var items []*T
+
+	if err := query.Limit(req.Limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(items) > req.Limit
+
+	if hasMore {
+		items = items[:req.Limit]
+	}
+
+	if backward {
+		slices.Reverse(items)
+	}
+
+	page.Items = items
+
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	first := &pagination.Cursor{ID: idOf(items[0]), Sort: signature}
+	last := &pagination.Cursor{ID: idOf(items[len(items)-1]), Sort: signature}
+
+	switch {
+	case backward:
+		page.Next = last
+		if hasMore {
+			page.Prev = first
+		}
+	default:
+		if hasMore {
+			page.Next = last
+		}
+		if req.After != nil || req.Offset > 0 {
+			page.Prev = first
+		}
+	}
+
+	return page, nil
+}
+
+// keysetCondition builds the condition selecting the rows after (or before)
+// the cursor row: (a > ?) OR (a = ? AND b > ?) OR ... with the comparison
+// flipped for descending keys and backward paging.
+func keysetCondition(db *gorm.DB, model interface{}, sorts []Sort, id uint, backward bool) (string, []interface{}, error) {
+	columns := make([]string, len(sorts))
+
+	for i, s := range sorts {
+		columns[i] = s.Column
+	}
+
+	boundary := map[string]interface{}{}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(model).
+		Select(columns).Where("id = ?", id).Take(&boundary).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, pagination.ErrInvalidCursor
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	var ors []string
+	var args []interface{}
+
+	for i, s := range sorts {
+		var ands []string
+
+		for _, prev := range sorts[:i] {
+			ands = append(ands, fmt.Sprintf("%s = ?", prev.Column))
+			args = append(args, boundary[prev.Column])
+		}
+
+		op := ">"
+		if s.Desc != backward {
+			op = "<"
+		}
+
+		ands = append(ands, fmt.Sprintf("%s %s ?", s.Column, op))
+		args = append(args, boundary[s.Column])
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+func sortSignature(sorts []Sort) string {
+	fields := make([]string, len(sorts))
+
+	for i, s := range sorts {
+		if s.Desc {
+			fields[i] = "-" + s.Column
+		} else {
+			fields[i] = s.Column
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+func cmpOr(cursors ...*pagination.Cursor) *pagination.Cursor {
+	for _, c := range cursors {
+		if c != nil {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"slices"
+	"testing"
+)
+
+// newPagedProducts stores products whose prices repeat, so that sorting by
+// price relies on the id tie-breaker.
+func newPagedProducts(t *testing.T) ProductDBRepository {
+	t.Helper()
+
+	r := NewProductDBRepository(newTestDB(t, &entity.Product{}))
+
+	for i, price := range []float64{30, 10, 20, 10, 30, 20, 10} {
+		if _, err := r.Create(&entity.Product{Name: string(rune('a' + i)), Price: price}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	return r
+}
+
+func TestKeysetPaging(t *testing.T) {
+	tests := []struct {
+		name  string
+		sorts []Sort
+		want  []uint
+	}{
+		{name: "default order", sorts: nil, want: []uint{1, 2, 3, 4, 5, 6, 7}},
+		{name: "ties broken by id", sorts: []Sort{{Column: "price"}}, want: []uint{2, 4, 7, 3, 6, 1, 5}},
+		{name: "descending", sorts: []Sort{{Column: "price", Desc: true}}, want: []uint{1, 5, 3, 6, 2, 4, 7}},
+		{name: "descending id", sorts: []Sort{{Column: "id", Desc: true}}, want: []uint{7, 6, 5, 4, 3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newPagedProducts(t)
+			req := pagination.Request{Limit: 3}
+
+			var forward []uint
+			var last *pagination.Page[*entity.Product]
+
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("paging forward does not end")
+				}
+
+				page, err := r.GetWithFilters(req, tt.sorts)
+
+				if err != nil {
+					t.Fatalf("GetWithFilters() error = %v", err)
+				}
+
+				if page.Total != int64(len(tt.want)) {
+					t.Errorf("Total = %d, want %d", page.Total, len(tt.want))
+				}
+
+				forward = append(forward, ids(page.Items)...)
+				last = page
+
+				if page.Next == nil {
+					break
+				}
+
+				// cursors go through their encoded form like in a request
+				if req.After, err = pagination.DecodeCursor(page.Next.Encode()); err != nil {
+					t.Fatalf("DecodeCursor() error = %v", err)
+				}
+			}
+
+			if !slices.Equal(forward, tt.want) {
+				t.Fatalf("paging forward = %v, want %v", forward, tt.want)
+			}
+
+			var backward []uint
+			req = pagination.Request{Limit: 3, Before: last.Prev}
+
+			for req.Before != nil {
+				page, err := r.GetWithFilters(req, tt.sorts)
+
+				if err != nil {
+					t.Fatalf("GetWithFilters() error = %v", err)
+				}
+
+				backward = append(ids(page.Items), backward...)
+				req.Before = page.Prev
+			}
+
+			backward = append(backward, ids(last.Items)...)
+
+			if !slices.Equal(backward, tt.want) {
+				t.Errorf("paging backward = %v, want %v", backward, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeysetPagingRejectsCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor pagination.Cursor
+	}{
+		{name: "issued for another order", cursor: pagination.Cursor{ID: 1, Sort: "-price,id"}},
+		{name: "unknown row", cursor: pagination.Cursor{ID: 99, Sort: "price,id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newPagedProducts(t)
+
+			_, err := r.GetWithFilters(pagination.Request{Limit: 3, After: &tt.cursor}, []Sort{{Column: "price"}})
+
+			if !errors.Is(err, pagination.ErrInvalidCursor) {
+				t.Errorf("GetWithFilters() error = %v, want %v", err, pagination.ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestKeysetPagingOffset(t *testing.T) {
+	r := newPagedProducts(t)
+
+	page, err := r.GetWithFilters(pagination.Request{Limit: 3, Offset: 3}, []Sort{{Column: "price"}})
+
+	if err != nil {
+		t.Fatalf("GetWithFilters() error = %v", err)
+	}
+
+	if got, want := ids(page.Items), []uint{3, 6, 1}; !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	if page.Prev == nil || page.Next == nil {
+		t.Errorf("Prev = %v, Next = %v, want both set on a middle page", page.Prev, page.Next)
+	}
+}
+
+func TestSortSignature(t *testing.T) {
+	tests := []struct {
+		sorts []Sort
+		want  string
+	}{
+		{sorts: []Sort{{Column: "id"}}, want: "id"},
+		{sorts: []Sort{{Column: "price", Desc: true}, {Column: "id"}}, want: "-price,id"},
+		{sorts: []Sort{{Column: "name"}, {Column: "created_at", Desc: true}, {Column: "id", Desc: true}}, want: "name,-created_at,-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := sortSignature(tt.sorts); got != tt.want {
+				t.Errorf("sortSignature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func ids(products []*entity.Product) []uint {
+	ids := make([]uint, len(products))
+
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	return ids
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type LoginFailureDBRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginFailureDBRepository(db *gorm.DB) LoginFailureDBRepository {
+	return LoginFailureDBRepository{db: db}
+}
+
+func (r LoginFailureDBRepository) GetBySubjects(subjects ...string) ([]*entity.LoginFailure, error) {
+	var failures []*entity.LoginFailure
+
+	return failures, r.db.Where("subject IN ?", subjects).Find(&failures).Error
+}
+
+// Increment counts a failure of subject in a single statement, starting
+// over when the previous failure happened before windowStart, and returns
+// the new count.
+func (r LoginFailureDBRepository) Increment(subject string, now, windowStart time.Time) (int, error) {
+	f := &entity.LoginFailure{Subject: subject, Failures: 1, LastFailureAt: now}
+
+	err := r.db.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "subject"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_failures.last_failure_at < ? THEN 1 ELSE login_failures.failures + 1 END", windowStart),
+				"last_failure_at": now,
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "failures"}}},
+	).Create(f).Error
+
+	return f.Failures, err
+}
+
+// Decrement takes back one failure of subject.
+func (r LoginFailureDBRepository) Decrement(subject string) error {
+	return r.db.Model(&entity.LoginFailure{}).
+		Where("subject = ? AND failures > 0", subject).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+func (r LoginFailureDBRepository) Delete(subject string) error {
+	return r.db.Where("subject = ?", subject).Delete(&entity.LoginFailure{}).Error
+}
+
+func (r LoginFailureDBRepository) DeleteBefore(before time.Time) (int64, error) {
+	res := r.db.Where("last_failure_at < ?", before).Delete(&entity.LoginFailure{})
+
+	return res.RowsAffected, res.Error
+}
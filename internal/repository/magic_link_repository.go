@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type MagicLinkTokenDBRepository struct {
+	db *gorm.DB
+}
+
+func NewMagicLinkTokenDBRepository(db *gorm.DB) MagicLinkTokenDBRepository {
+	return MagicLinkTokenDBRepository{db: db}
+}
+
+func (r MagicLinkTokenDBRepository) Create(t *entity.MagicLinkToken) (*entity.MagicLinkToken, error) {
+	return t, r.db.Create(&t).Error
+}
+
+func (r MagicLinkTokenDBRepository) GetByHash(tokenHash string) (*entity.MagicLinkToken, error) {
+	var t *entity.MagicLinkToken
+
+	return t, r.db.Where("token_hash = ?", tokenHash).First(&t).Error
+}
+
+// Consume marks the token as used. It reports false when the token had
+// already been used.
+func (r MagicLinkTokenDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r MagicLinkTokenDBRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&entity.MagicLinkToken{}).Error
+}
+
+func (r MagicLinkTokenDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.MagicLinkToken{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type MFAChallengeDBRepository struct {
+	db *gorm.DB
+}
+
+func NewMFAChallengeDBRepository(db *gorm.DB) MFAChallengeDBRepository {
+	return MFAChallengeDBRepository{db: db}
+}
+
+func (r MFAChallengeDBRepository) Create(c *entity.MFAChallenge) (*entity.MFAChallenge, error) {
+	return c, r.db.Create(&c).Error
+}
+
+func (r MFAChallengeDBRepository) GetByHash(tokenHash string) (*entity.MFAChallenge, error) {
+	var c *entity.MFAChallenge
+
+	return c, r.db.Where("token_hash = ?", tokenHash).First(&c).Error
+}
+
+// RecordFailure counts a wrong code. It reports false once the challenge
+// ran out of attempts, so concurrent guesses cannot exceed maxAttempts.
+func (r MFAChallengeDBRepository) RecordFailure(id uint, maxAttempts int) (bool, error) {
+	res := r.db.Model(&entity.MFAChallenge{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+
+	return res.RowsAffected == 1, res.Error
+}
+
+// Consume marks the challenge as used. It reports false when the challenge
+// had already been used.
+func (r MFAChallengeDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r MFAChallengeDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.MFAChallenge{})
+
+	return res.RowsAffected, res.Error
+}
+
+type RecoveryCodeDBRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeDBRepository(db *gorm.DB) RecoveryCodeDBRepository {
+	return RecoveryCodeDBRepository{db: db}
+}
+
+// Replace swaps every recovery code of the user for the given hashes.
+func (r RecoveryCodeDBRepository) Replace(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]*entity.RecoveryCode, len(codeHashes))
+
+		for i, h := range codeHashes {
+			codes[i] = &entity.RecoveryCode{UserID: userID, CodeHash: h}
+		}
+
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r RecoveryCodeDBRepository) GetUnused(userID uint) ([]*entity.RecoveryCode, error) {
+	var codes []*entity.RecoveryCode
+
+	return codes, r.db.Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error
+}
+
+// Consume marks the code as used. It reports false when the code had
+// already been used.
+func (r RecoveryCodeDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r RecoveryCodeDBRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error
+}
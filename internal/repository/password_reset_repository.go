@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type PasswordResetTokenDBRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetTokenDBRepository(db *gorm.DB) PasswordResetTokenDBRepository {
+	return PasswordResetTokenDBRepository{db: db}
+}
+
+func (r PasswordResetTokenDBRepository) Create(t *entity.PasswordResetToken) (*entity.PasswordResetToken, error) {
+	return t, r.db.Create(&t).Error
+}
+
+func (r PasswordResetTokenDBRepository) GetByHash(tokenHash string) (*entity.PasswordResetToken, error) {
+	var t *entity.PasswordResetToken
+
+	return t, r.db.Where("token_hash = ?", tokenHash).First(&t).Error
+}
+
+// Consume marks the token as used. It reports false when the token had
+// already been used.
+func (r PasswordResetTokenDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r PasswordResetTokenDBRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&entity.PasswordResetToken{}).Error
+}
+
+func (r PasswordResetTokenDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.PasswordResetToken{})
+
+	return res.RowsAffected, res.Error
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
 	"gorm.io/gorm"
+	"time"
 )
 
 type ProductDBRepository struct {
@@ -20,7 +22,25 @@ func (r ProductDBRepository) Create(p *entity.Product) (*entity.Product, error)
 func (r ProductDBRepository) GetByID(id uint) (*entity.Product, error) {
 	var p *entity.Product
 
-	return p, r.db.Find(&p, id).Error
+	return p, r.db.First(&p, id).Error
+}
+
+func (r ProductDBRepository) Update(p *entity.Product) (*entity.Product, error) {
+	return p, r.db.Save(p).Error
+}
+
+func (r ProductDBRepository) Delete(id uint) error {
+	res := r.db.Delete(&entity.Product{}, id)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
 }
 
 func (r ProductDBRepository) Get(limit, offset int) ([]*entity.Product, error) {
@@ -29,20 +49,75 @@ func (r ProductDBRepository) Get(limit, offset int) ([]*entity.Product, error) {
 	return products, r.db.Limit(limit).Offset(offset).Find(&products).Error
 }
 
+// GetNames returns the names of all products keyed by their ID.
+func (r ProductDBRepository) GetNames() (map[uint]string, error) {
+	var products []*entity.Product
+
+	if err := r.db.Select("id", "name").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(products))
+
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+
+	return names, nil
+}
+
 func (r ProductDBRepository) GetByName(name string) (*entity.Product, error) {
 	var p *entity.Product
 
 	return p, r.db.Where("LOWER(name) = LOWER(?)", name).First(&p).Error
 }
 
-func (r ProductDBRepository) GetWithFilters(filters ...Filter) ([]*entity.Product, error) {
-	var products []*entity.Product
+func (r ProductDBRepository) GetWithFilters(req pagination.Request, sorts []Sort, filters ...Filter) (*pagination.Page[*entity.Product], error) {
+	scope := func() *gorm.DB {
+		db := r.db.Session(&gorm.Session{NewDB: true})
+
+		for _, filter := range filters {
+			db = db.Where(filter.Query(), filter.Args())
+		}
 
-	db := r.db
+		return db
+	}
+
+	return findPage(r.db, scope, sorts, req, productID)
+}
 
-	for _, filter := range filters {
-		db = db.Where(filter.Query(), filter.Args())
+// GetDeleted lists soft deleted products, most recently deleted first.
+func (r ProductDBRepository) GetDeleted(req pagination.Request) (*pagination.Page[*entity.Product], error) {
+	scope := func() *gorm.DB {
+		return r.db.Session(&gorm.Session{NewDB: true}).Unscoped().Where("deleted_at IS NOT NULL")
 	}
 
-	return products, db.Find(products).Error
+	return findPage(r.db, scope, []Sort{{Column: "deleted_at", Desc: true}}, req, productID)
+}
+
+func (r ProductDBRepository) Restore(id uint) (*entity.Product, error) {
+	res := r.db.Unscoped().Model(&entity.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetByID(id)
+}
+
+// PurgeDeleted permanently removes products soft deleted before the given time.
+func (r ProductDBRepository) PurgeDeleted(before time.Time) (int64, error) {
+	res := r.db.Unscoped().Where("deleted_at < ?", before).Delete(&entity.Product{})
+
+	return res.RowsAffected, res.Error
+}
+
+func productID(p *entity.Product) uint {
+	return p.ID
 }
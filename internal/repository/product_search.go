@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	sqliteDialect   = "sqlite"
+	postgresDialect = "postgres"
+)
+
+var (
+	ErrSearchNotSupported  = errors.New("full-text search is not supported by the storage")
+	ErrFullTextUnavailable = errors.New("sqlite is built without FTS5, product search matches substrings")
+)
+
+// sqliteFTS5 tells whether the linked sqlite has FTS5. It is a property of
+// the binary, which has to be built with the sqlite_fts5 tag, so it is
+// looked up once.
+var sqliteFTS5 struct {
+	once      sync.Once
+	available bool
+}
+
+// sqliteSearchSchema keeps an FTS5 index over name and description in sync
+// with the products table through triggers.
+var sqliteSearchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+		name, description,
+		content='products', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS products_fts_ai AFTER INSERT ON products BEGIN
+		INSERT INTO products_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS products_fts_ad AFTER DELETE ON products BEGIN
+		INSERT INTO products_fts(products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS products_fts_au AFTER UPDATE ON products BEGIN
+		INSERT INTO products_fts(products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+		INSERT INTO products_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	`INSERT INTO products_fts(products_fts) VALUES ('rebuild')`,
+}
+
+// postgresSearchSchema adds a generated tsvector column, so postgres keeps
+// it up to date on every write, weighting names above descriptions.
+var postgresSearchSchema = []string{
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+		) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+}
+
+// sqliteSearchTriggers are dropped when FTS5 is missing, as writes to the
+// products table fail on triggers using it.
+var sqliteSearchTriggers = []string{
+	`DROP TRIGGER IF EXISTS products_fts_ai`,
+	`DROP TRIGGER IF EXISTS products_fts_ad`,
+	`DROP TRIGGER IF EXISTS products_fts_au`,
+}
+
+// MigrateProductSearch creates the storage specific full-text index of
+// products. Without FTS5, sqlite storage falls back to substring matching
+// and ErrFullTextUnavailable is returned as a warning.
+func MigrateProductSearch(db *gorm.DB) error {
+	var schema []string
+
+	switch db.Dialector.Name() {
+	case sqliteDialect:
+		if !hasSQLiteFTS5(db) {
+			for _, stmt := range sqliteSearchTriggers {
+				if err := db.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+
+			return ErrFullTextUnavailable
+		}
+
+		schema = sqliteSearchSchema
+	case postgresDialect:
+		schema = postgresSearchSchema
+	default:
+		return fmt.Errorf("%s: %w", db.Dialector.Name(), ErrSearchNotSupported)
+	}
+
+	for _, stmt := range schema {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropProductSearch removes the full-text index tables that do not go away
+// together with the products table.
+func DropProductSearch(db *gorm.DB) error {
+	if db.Dialector.Name() == sqliteDialect && hasSQLiteFTS5(db) {
+		return db.Exec(`DROP TABLE IF EXISTS products_fts`).Error
+	}
+
+	return nil
+}
+
+// Search returns the products best matching every term of query in their
+// name or description, names weighing more. The last term also matches
+// as a prefix so results show up while the user is still typing. Without
+// FTS5, sqlite storage returns unranked substring matches instead.
+func (r ProductDBRepository) Search(query string, limit int) ([]*entity.Product, error) {
+	var products []*entity.Product
+
+	terms := searchTerms(query)
+
+	if len(terms) == 0 {
+		return products, nil
+	}
+
+	switch r.db.Dialector.Name() {
+	case sqliteDialect:
+		if !hasSQLiteFTS5(r.db) {
+			return r.searchSubstrings(terms, limit)
+		}
+
+		return products, r.db.
+			Select("products.*").
+			Joins("JOIN products_fts ON products_fts.rowid = products.id").
+			Where("products_fts MATCH ?", ftsQuery(terms)).
+			Order("bm25(products_fts, 10.0, 1.0)").
+			Limit(limit).
+			Find(&products).Error
+	case postgresDialect:
+		tsQuery := tsQuery(terms)
+
+		return products, r.db.
+			Where("search_vector @@ to_tsquery('simple', ?)", tsQuery).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:                "ts_rank(search_vector, to_tsquery('simple', ?)) DESC",
+				Vars:               []interface{}{tsQuery},
+				WithoutParentheses: true,
+			}}).
+			Limit(limit).
+			Find(&products).Error
+	default:
+		return nil, fmt.Errorf("%s: %w", r.db.Dialector.Name(), ErrSearchNotSupported)
+	}
+}
+
+// searchSubstrings returns the products containing every term in their
+// name or description, those with the first term in their name first.
+// Terms are letters and digits only, so they need no escaping.
+func (r ProductDBRepository) searchSubstrings(terms []string, limit int) ([]*entity.Product, error) {
+	var products []*entity.Product
+
+	db := r.db
+
+	for _, t := range terms {
+		db = db.Where("(name LIKE ? OR description LIKE ?)", "%"+t+"%", "%"+t+"%")
+	}
+
+	return products, db.
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "CASE WHEN name LIKE ? THEN 0 ELSE 1 END, id",
+			Vars:               []interface{}{"%" + terms[0] + "%"},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).
+		Find(&products).Error
+}
+
+func hasSQLiteFTS5(db *gorm.DB) bool {
+	sqliteFTS5.once.Do(func() {
+		var used int
+
+		err := db.Raw(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used).Error
+		sqliteFTS5.available = err == nil && used == 1
+	})
+
+	return sqliteFTS5.available
+}
+
+// searchTerms splits query into lower cased words, dropping every character
+// with a meaning in the FTS5 or tsquery syntax.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"`
+	}
+
+	return strings.Join(quoted, " ") + "*"
+}
+
+func tsQuery(terms []string) string {
+	return strings.Join(terms, " & ") + ":*"
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"testing"
+)
+
+func TestProductSearch(t *testing.T) {
+	db := newTestDB(t, &entity.Product{})
+
+	if err := MigrateProductSearch(db); err != nil && !errors.Is(err, ErrFullTextUnavailable) {
+		t.Fatalf("MigrateProductSearch() error = %v", err)
+	}
+
+	r := NewProductDBRepository(db)
+
+	for _, p := range []*entity.Product{
+		{Name: "Red apple", Description: "Sweet and crunchy"},
+		{Name: "Green apple", Description: "Sour"},
+		{Name: "Apple pie", Description: "Baked with red apples"},
+		{Name: "Banana", Description: "Yellow"},
+	} {
+		if _, err := r.Create(p); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "every term must match", query: "red apple", want: []string{"Red apple", "Apple pie"}},
+		{name: "last term matches as a prefix", query: "bana", want: []string{"Banana"}},
+		{name: "description matches", query: "sour", want: []string{"Green apple"}},
+		{name: "syntax characters are dropped", query: `"yellow" OR *`, want: nil},
+		{name: "no terms", query: "!?", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := r.Search(tt.query, 10)
+
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+
+			var got []string
+
+			for _, p := range products {
+				got = append(got, p.Name)
+			}
+
+			if !sameNames(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductSearchSubstrings(t *testing.T) {
+	r := NewProductDBRepository(newTestDB(t, &entity.Product{}))
+
+	for _, p := range []*entity.Product{
+		{Name: "Crumble", Description: "Apple and pear"},
+		{Name: "Pineapple", Description: "Tropical"},
+		{Name: "Pear", Description: "Juicy"},
+	} {
+		if _, err := r.Create(p); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	products, err := r.searchSubstrings([]string{"apple"}, 10)
+
+	if err != nil {
+		t.Fatalf("searchSubstrings() error = %v", err)
+	}
+
+	var got []string
+
+	for _, p := range products {
+		got = append(got, p.Name)
+	}
+
+	// name matches come first
+	if want := []string{"Pineapple", "Crumble"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("searchSubstrings() = %q, want %q", got, want)
+	}
+}
+
+// sameNames compares got and want ignoring order, as ranking differs
+// between full-text and substring search.
+func sameNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	seen := make(map[string]int)
+
+	for _, n := range got {
+		seen[n]++
+	}
+
+	for _, n := range want {
+		if seen[n] == 0 {
+			return false
+		}
+		seen[n]--
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/ratelimit"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// RateLimitDBRepository is a ratelimit.Store shared by all instances using
+// the database. Each update runs in a transaction that starts with an
+// upsert of the counter, which locks it until the new state is written:
+// concurrent requests of the same client wait for each other instead of
+// overwriting each other's count.
+type RateLimitDBRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitDBRepository(db *gorm.DB) RateLimitDBRepository {
+	return RateLimitDBRepository{db: db}
+}
+
+func (r RateLimitDBRepository) Update(key string, expiresAt time.Time, fn func(s *ratelimit.State)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// new keys start from the zero state, existing ones only get their
+		// version bumped so that the row is locked and returned as stored
+		c := &entity.RateLimitCounter{Key: key, ExpiresAt: expiresAt}
+
+		err := tx.Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"version": gorm.Expr("rate_limit_counters.version + 1")}),
+			},
+			clause.Returning{},
+		).Create(c).Error
+
+		if err != nil {
+			return err
+		}
+
+		s := ratelimit.State{Value: c.Value, Previous: c.Previous, Stamp: c.Stamp}
+
+		if time.Now().After(c.ExpiresAt) {
+			s = ratelimit.State{}
+		}
+
+		fn(&s)
+
+		return tx.Model(&entity.RateLimitCounter{}).
+			Where("id = ?", c.ID).
+			Updates(map[string]interface{}{
+				"value":      s.Value,
+				"previous":   s.Previous,
+				"stamp":      s.Stamp,
+				"expires_at": expiresAt,
+			}).Error
+	})
+}
+
+func (r RateLimitDBRepository) DeleteExpired(now time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", now).Delete(&entity.RateLimitCounter{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/ratelimit"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite3")), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	return db
+}
+
+func TestRateLimitDBRepositoryCountsConcurrentUpdates(t *testing.T) {
+	r := NewRateLimitDBRepository(newTestDB(t, &entity.RateLimitCounter{}))
+	expiresAt := time.Now().Add(time.Minute)
+
+	const requests = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+
+	for range requests {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- r.Update("burst", expiresAt, func(s *ratelimit.State) {
+				s.Value++
+				s.Stamp = 1
+			})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	var got ratelimit.State
+
+	if err := r.Update("burst", expiresAt, func(s *ratelimit.State) { got = *s }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if got.Value != requests {
+		t.Errorf("count = %v, want %d: concurrent updates were lost", got.Value, requests)
+	}
+}
+
+func TestRateLimitDBRepositoryResetsExpiredState(t *testing.T) {
+	r := NewRateLimitDBRepository(newTestDB(t, &entity.RateLimitCounter{}))
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      ratelimit.State
+	}{
+		{name: "new key starts from zero", expiresAt: time.Now().Add(-time.Second), want: ratelimit.State{}},
+		{name: "expired state is passed as zero", expiresAt: time.Now().Add(time.Minute), want: ratelimit.State{}},
+		{name: "live state is kept", expiresAt: time.Now().Add(time.Minute), want: ratelimit.State{Value: 3, Previous: 1, Stamp: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ratelimit.State
+
+			err := r.Update("key", tt.expiresAt, func(s *ratelimit.State) {
+				got = *s
+				*s = ratelimit.State{Value: 3, Previous: 1, Stamp: 7}
+			})
+
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("state = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type RefreshTokenDBRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenDBRepository(db *gorm.DB) RefreshTokenDBRepository {
+	return RefreshTokenDBRepository{db: db}
+}
+
+func (r RefreshTokenDBRepository) Create(t *entity.RefreshToken) (*entity.RefreshToken, error) {
+	return t, r.db.Create(&t).Error
+}
+
+func (r RefreshTokenDBRepository) GetByHash(tokenHash string) (*entity.RefreshToken, error) {
+	var t *entity.RefreshToken
+
+	return t, r.db.Where("token_hash = ?", tokenHash).First(&t).Error
+}
+
+// Consume marks the token as used. It reports false when the token
+// had already been used, so concurrent rotations cannot both succeed.
+func (r RefreshTokenDBRepository) Consume(id uint) (bool, error) {
+	res := r.db.Model(&entity.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r RefreshTokenDBRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByUser revokes every refresh token of the user but those of
+// exceptFamilyID, which may be empty to revoke them all.
+func (r RefreshTokenDBRepository) RevokeByUser(userID uint, exceptFamilyID string) error {
+	return r.db.Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, exceptFamilyID).
+		Update("revoked_at", time.Now()).Error
+}
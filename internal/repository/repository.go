@@ -1,17 +1,135 @@
 package repository
 
-import "github.com/SomchaiSPB/user-auth/internal/entity"
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"time"
+)
 
 type UserRepository interface {
 	Create(u *entity.User) (*entity.User, error)
 	Exists(username string) bool
+	GetByID(id uint) (*entity.User, error)
 	GetByName(username string) (*entity.User, error)
+	GetStatus(id uint) (*entity.User, error)
+	GetWithFilters(req pagination.Request, sorts []Sort, filters ...Filter) (*pagination.Page[*entity.User], error)
+	Update(u *entity.User) (*entity.User, error)
+	SetDisabled(id uint, disabledAt *time.Time) error
+	Delete(id uint) error
+	GetDeleted(req pagination.Request) (*pagination.Page[*entity.User], error)
+	Restore(id uint) (*entity.User, error)
+	PurgeDeleted(before time.Time) (int64, error)
+	SetTOTP(id uint, secret string, enabledAt *time.Time) error
+	UseTOTPCounter(id uint, counter int64) (bool, error)
+	ReplacePassword(id uint, oldHash, newHash string) (bool, error)
+	MarkVerificationSent(id uint, now, sentBefore time.Time) (bool, error)
+	MarkEmailVerified(id uint, now time.Time) error
 }
 
 type ProductRepository interface {
 	Create(p *entity.Product) (*entity.Product, error)
+	Update(p *entity.Product) (*entity.Product, error)
+	Delete(id uint) error
 	GetByID(id uint) (*entity.Product, error)
 	Get(limit, offset int) ([]*entity.Product, error)
 	GetByName(name string) (*entity.Product, error)
-	GetWithFilters(filters ...Filter) ([]*entity.Product, error)
+	GetWithFilters(req pagination.Request, sorts []Sort, filters ...Filter) (*pagination.Page[*entity.Product], error)
+	Search(query string, limit int) ([]*entity.Product, error)
+	GetNames() (map[uint]string, error)
+	GetDeleted(req pagination.Request) (*pagination.Page[*entity.Product], error)
+	Restore(id uint) (*entity.Product, error)
+	PurgeDeleted(before time.Time) (int64, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(t *entity.RefreshToken) (*entity.RefreshToken, error)
+	GetByHash(tokenHash string) (*entity.RefreshToken, error)
+	Consume(id uint) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeByUser(userID uint, exceptFamilyID string) error
+}
+
+type SessionRepository interface {
+	Create(s *entity.Session) (*entity.Session, error)
+	GetByID(id uint) (*entity.Session, error)
+	GetByFamily(familyID string) (*entity.Session, error)
+	GetActiveByUser(userID uint, now time.Time) ([]*entity.Session, error)
+	Touch(id uint, now, seenBefore time.Time) error
+	Extend(id uint, now, expiresAt time.Time) error
+	Revoke(id, userID uint) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeByUser(userID uint, exceptFamilyID string) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type PasswordResetTokenRepository interface {
+	Create(t *entity.PasswordResetToken) (*entity.PasswordResetToken, error)
+	GetByHash(tokenHash string) (*entity.PasswordResetToken, error)
+	Consume(id uint) (bool, error)
+	DeleteByUser(userID uint) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type EmailVerificationTokenRepository interface {
+	Create(t *entity.EmailVerificationToken) (*entity.EmailVerificationToken, error)
+	GetByHash(tokenHash string) (*entity.EmailVerificationToken, error)
+	Consume(id uint) (bool, error)
+	DeleteByUser(userID uint) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type MagicLinkTokenRepository interface {
+	Create(t *entity.MagicLinkToken) (*entity.MagicLinkToken, error)
+	GetByHash(tokenHash string) (*entity.MagicLinkToken, error)
+	Consume(id uint) (bool, error)
+	DeleteByUser(userID uint) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type MFAChallengeRepository interface {
+	Create(c *entity.MFAChallenge) (*entity.MFAChallenge, error)
+	GetByHash(tokenHash string) (*entity.MFAChallenge, error)
+	RecordFailure(id uint, maxAttempts int) (bool, error)
+	Consume(id uint) (bool, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type RecoveryCodeRepository interface {
+	Replace(userID uint, codeHashes []string) error
+	GetUnused(userID uint) ([]*entity.RecoveryCode, error)
+	Consume(id uint) (bool, error)
+	DeleteByUser(userID uint) error
+}
+
+type WebAuthnCredentialRepository interface {
+	Create(c *entity.WebAuthnCredential) (*entity.WebAuthnCredential, error)
+	GetByCredentialID(credentialID string) (*entity.WebAuthnCredential, error)
+	GetByUser(userID uint) ([]*entity.WebAuthnCredential, error)
+	UpdateSignCount(id uint, previous, signCount uint32) (bool, error)
+	Delete(userID, id uint) error
+}
+
+type WebAuthnChallengeRepository interface {
+	Create(c *entity.WebAuthnChallenge) (*entity.WebAuthnChallenge, error)
+	Take(challenge, ceremony string) (*entity.WebAuthnChallenge, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type LoginFailureRepository interface {
+	GetBySubjects(subjects ...string) ([]*entity.LoginFailure, error)
+	Increment(subject string, now, windowStart time.Time) (int, error)
+	Decrement(subject string) error
+	Delete(subject string) error
+	DeleteBefore(before time.Time) (int64, error)
+}
+
+type RevokedTokenRepository interface {
+	Create(t *entity.RevokedToken) (*entity.RevokedToken, error)
+	Exists(jti string) (bool, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type RoleRepository interface {
+	GetByName(name string) (*entity.Role, error)
+	Ensure(name string, permissions []string) (*entity.Role, error)
 }
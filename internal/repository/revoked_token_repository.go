@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type RevokedTokenDBRepository struct {
+	db *gorm.DB
+}
+
+func NewRevokedTokenDBRepository(db *gorm.DB) RevokedTokenDBRepository {
+	return RevokedTokenDBRepository{db: db}
+}
+
+// Create stores the revocation. Revoking an already revoked jti is a no-op.
+func (r RevokedTokenDBRepository) Create(t *entity.RevokedToken) (*entity.RevokedToken, error) {
+	return t, r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&t).Error
+}
+
+func (r RevokedTokenDBRepository) Exists(jti string) (bool, error) {
+	var exists bool
+
+	return exists, r.db.Model(&entity.RevokedToken{}).Select("count(id) > 0").Where("jti = ?", jti).Find(&exists).Error
+}
+
+func (r RevokedTokenDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.RevokedToken{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+)
+
+type RoleDBRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleDBRepository(db *gorm.DB) RoleDBRepository {
+	return RoleDBRepository{db: db}
+}
+
+func (r RoleDBRepository) GetByName(name string) (*entity.Role, error) {
+	var role *entity.Role
+
+	return role, r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+}
+
+// Ensure creates the role when missing and sets exactly the given permissions.
+func (r RoleDBRepository) Ensure(name string, permissions []string) (*entity.Role, error) {
+	var role *entity.Role
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		perms := make([]*entity.Permission, 0, len(permissions))
+
+		for _, p := range permissions {
+			perm := &entity.Permission{}
+
+			if err := tx.Where(entity.Permission{Name: p}).FirstOrCreate(perm).Error; err != nil {
+				return err
+			}
+
+			perms = append(perms, perm)
+		}
+
+		role = &entity.Role{}
+
+		if err := tx.Where(entity.Role{Name: name}).FirstOrCreate(role).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(role).Association("Permissions").Replace(perms)
+	})
+
+	return role, err
+}
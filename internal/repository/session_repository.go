@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SessionDBRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionDBRepository(db *gorm.DB) SessionDBRepository {
+	return SessionDBRepository{db: db}
+}
+
+func (r SessionDBRepository) Create(s *entity.Session) (*entity.Session, error) {
+	return s, r.db.Create(&s).Error
+}
+
+func (r SessionDBRepository) GetByID(id uint) (*entity.Session, error) {
+	var s *entity.Session
+
+	return s, r.db.First(&s, id).Error
+}
+
+func (r SessionDBRepository) GetByFamily(familyID string) (*entity.Session, error) {
+	var s *entity.Session
+
+	return s, r.db.Where("family_id = ?", familyID).First(&s).Error
+}
+
+// GetActiveByUser lists the sessions of the user that were neither revoked
+// nor expired at now, most recently seen first.
+func (r SessionDBRepository) GetActiveByUser(userID uint, now time.Time) ([]*entity.Session, error) {
+	var sessions []*entity.Session
+
+	return sessions, r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+}
+
+// Touch records that the session was used at now. Sessions seen after
+// seenBefore are left alone, which keeps busy clients from writing on
+// every request.
+func (r SessionDBRepository) Touch(id uint, now, seenBefore time.Time) error {
+	return r.db.Model(&entity.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, seenBefore).
+		Update("last_seen_at", now).Error
+}
+
+// Extend keeps the session alive until the refresh token issued at now
+// expires.
+func (r SessionDBRepository) Extend(id uint, now, expiresAt time.Time) error {
+	return r.db.Model(&entity.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// Revoke revokes a session of the user. It reports false when the user
+// has no such active session.
+func (r SessionDBRepository) Revoke(id, userID uint) (bool, error) {
+	res := r.db.Model(&entity.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r SessionDBRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&entity.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByUser revokes every session of the user but the one of
+// exceptFamilyID, which may be empty to revoke them all.
+func (r SessionDBRepository) RevokeByUser(userID uint, exceptFamilyID string) error {
+	return r.db.Model(&entity.Session{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, exceptFamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteExpired deletes the sessions that expired before the given time
+// and the revoked ones. Tokens of deleted sessions are rejected as well.
+func (r SessionDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ? OR revoked_at IS NOT NULL", before).Delete(&entity.Session{})
+
+	return res.RowsAffected, res.Error
+}
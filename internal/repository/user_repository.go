@@ -2,7 +2,9 @@ package repository
 
 import (
 	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
 	"gorm.io/gorm"
+	"time"
 )
 
 type UserDBRepository struct {
@@ -25,8 +27,189 @@ func (r UserDBRepository) Exists(username string) bool {
 	return exists
 }
 
+func (r UserDBRepository) GetByID(id uint) (*entity.User, error) {
+	var u *entity.User
+
+	return u, r.db.Preload("Roles.Permissions").First(&u, id).Error
+}
+
 func (r UserDBRepository) GetByName(username string) (*entity.User, error) {
 	var u *entity.User
 
-	return u, r.db.Where("name = ?", username).First(&u).Error
+	return u, r.db.Preload("Roles.Permissions").Where("name = ?", username).First(&u).Error
+}
+
+// GetStatus loads only what tells whether the user may use the API, for
+// checks on every request.
+func (r UserDBRepository) GetStatus(id uint) (*entity.User, error) {
+	var u *entity.User
+
+	return u, r.db.Select("id", "disabled_at", "email_verified_at").First(&u, id).Error
+}
+
+func (r UserDBRepository) GetWithFilters(req pagination.Request, sorts []Sort, filters ...Filter) (*pagination.Page[*entity.User], error) {
+	scope := func() *gorm.DB {
+		db := r.db.Session(&gorm.Session{NewDB: true})
+
+		for _, filter := range filters {
+			db = db.Where(filter.Query(), filter.Args())
+		}
+
+		return db
+	}
+
+	return findPage(r.db, scope, sorts, req, userID)
+}
+
+// Update saves the profile of the user. Passwords, second factors and
+// roles are changed by their own methods, so that a stale copy of the user
+// cannot undo them.
+func (r UserDBRepository) Update(u *entity.User) (*entity.User, error) {
+	return u, r.db.Model(u).Select("Name", "EmailVerifiedAt", "VerificationSentAt").Updates(u).Error
+}
+
+// SetDisabled disables the user at disabledAt, or enables it when nil.
+func (r UserDBRepository) SetDisabled(id uint, disabledAt *time.Time) error {
+	res := r.db.Model(&entity.User{}).Where("id = ?", id).Update("disabled_at", disabledAt)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete moves the user to the trash, from where it is purged after the
+// retention period.
+func (r UserDBRepository) Delete(id uint) error {
+	res := r.db.Delete(&entity.User{}, id)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetTOTP stores the TOTP secret of the user and whether it is enforced.
+// The replay counter starts over with every secret.
+func (r UserDBRepository) SetTOTP(id uint, secret string, enabledAt *time.Time) error {
+	return r.db.Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_enabled_at":   enabledAt,
+		"totp_last_counter": 0,
+	}).Error
+}
+
+// UseTOTPCounter records the time step of an accepted TOTP code. It reports
+// false when a code of that or a later step was already accepted.
+func (r UserDBRepository) UseTOTPCounter(id uint, counter int64) (bool, error) {
+	res := r.db.Model(&entity.User{}).
+		Where("id = ? AND totp_last_counter < ?", id, counter).
+		Update("totp_last_counter", counter)
+
+	return res.RowsAffected == 1, res.Error
+}
+
+// GetDeleted lists soft deleted users, most recently deleted first.
+func (r UserDBRepository) GetDeleted(req pagination.Request) (*pagination.Page[*entity.User], error) {
+	scope := func() *gorm.DB {
+		return r.db.Session(&gorm.Session{NewDB: true}).Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	return findPage(r.db, scope, []Sort{{Column: "deleted_at", Desc: true}}, req, userID)
+}
+
+func (r UserDBRepository) Restore(id uint) (*entity.User, error) {
+	res := r.db.Unscoped().Model(&entity.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetByID(id)
+}
+
+// PurgeDeleted permanently removes users soft deleted before the given time
+// together with the rows referencing them.
+func (r UserDBRepository) PurgeDeleted(before time.Time) (int64, error) {
+	var purged int64
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+
+		if err := tx.Unscoped().Model(&entity.User{}).Where("deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id IN ?", ids).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&entity.RefreshToken{}, &entity.MFAChallenge{}, &entity.RecoveryCode{},
+			&entity.WebAuthnCredential{}, &entity.WebAuthnChallenge{}, &entity.PasswordResetToken{},
+			&entity.MagicLinkToken{}, &entity.EmailVerificationToken{}, &entity.Session{},
+		} {
+			if err := tx.Where("user_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		res := tx.Unscoped().Delete(&entity.User{}, ids)
+		purged = res.RowsAffected
+
+		return res.Error
+	})
+
+	return purged, err
+}
+
+// ReplacePassword swaps the password hash only while it still is oldHash,
+// so that a concurrent password change is not overwritten.
+func (r UserDBRepository) ReplacePassword(id uint, oldHash, newHash string) (bool, error) {
+	res := r.db.Model(&entity.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		Update("password", newHash)
+
+	return res.RowsAffected == 1, res.Error
+}
+
+// MarkVerificationSent records that a verification email is sent to an
+// unverified user. It reports false when the user is verified or got one
+// after sentBefore, which throttles resending.
+func (r UserDBRepository) MarkVerificationSent(id uint, now, sentBefore time.Time) (bool, error) {
+	res := r.db.Model(&entity.User{}).
+		Where("id = ? AND email_verified_at IS NULL AND (verification_sent_at IS NULL OR verification_sent_at < ?)", id, sentBefore).
+		Update("verification_sent_at", now)
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r UserDBRepository) MarkEmailVerified(id uint, now time.Time) error {
+	return r.db.Model(&entity.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", now).Error
+}
+
+func userID(u *entity.User) uint {
+	return u.ID
 }
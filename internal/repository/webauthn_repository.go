@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type WebAuthnCredentialDBRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnCredentialDBRepository(db *gorm.DB) WebAuthnCredentialDBRepository {
+	return WebAuthnCredentialDBRepository{db: db}
+}
+
+func (r WebAuthnCredentialDBRepository) Create(c *entity.WebAuthnCredential) (*entity.WebAuthnCredential, error) {
+	return c, r.db.Create(&c).Error
+}
+
+func (r WebAuthnCredentialDBRepository) GetByCredentialID(credentialID string) (*entity.WebAuthnCredential, error) {
+	var c *entity.WebAuthnCredential
+
+	return c, r.db.Where("credential_id = ?", credentialID).First(&c).Error
+}
+
+func (r WebAuthnCredentialDBRepository) GetByUser(userID uint) ([]*entity.WebAuthnCredential, error) {
+	var credentials []*entity.WebAuthnCredential
+
+	return credentials, r.db.Where("user_id = ?", userID).Order("id").Find(&credentials).Error
+}
+
+// UpdateSignCount stores the counter of the last assertion. It reports
+// false when a concurrent assertion already stored a counter at least as
+// high, which the authenticator only produces once.
+func (r WebAuthnCredentialDBRepository) UpdateSignCount(id uint, previous, signCount uint32) (bool, error) {
+	res := r.db.Model(&entity.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previous).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": time.Now()})
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r WebAuthnCredentialDBRepository) Delete(userID, id uint) error {
+	res := r.db.Where("user_id = ?", userID).Delete(&entity.WebAuthnCredential{}, id)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+type WebAuthnChallengeDBRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnChallengeDBRepository(db *gorm.DB) WebAuthnChallengeDBRepository {
+	return WebAuthnChallengeDBRepository{db: db}
+}
+
+func (r WebAuthnChallengeDBRepository) Create(c *entity.WebAuthnChallenge) (*entity.WebAuthnChallenge, error) {
+	return c, r.db.Create(&c).Error
+}
+
+// Take removes and returns the pending ceremony of the challenge, so every
+// challenge is answered at most once.
+func (r WebAuthnChallengeDBRepository) Take(challenge, ceremony string) (*entity.WebAuthnChallenge, error) {
+	var c *entity.WebAuthnChallenge
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("challenge = ? AND ceremony = ?", challenge, ceremony).First(&c).Error; err != nil {
+			return err
+		}
+
+		res := tx.Delete(&entity.WebAuthnChallenge{}, c.ID)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	return c, err
+}
+
+func (r WebAuthnChallengeDBRepository) DeleteExpired(before time.Time) (int64, error) {
+	res := r.db.Where("expires_at < ?", before).Delete(&entity.WebAuthnChallenge{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/mailer"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"gorm.io/gorm"
+	"net/mail"
+	"time"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired email verification token error")
+
+// EmailVerificationOptions configures the verification links. The token
+// is added to VerifyURL as the token query parameter; a user gets at most
+// one link per ResendInterval. OnSendError receives the errors of links
+// resent in the background.
+type EmailVerificationOptions struct {
+	TokenTTL       time.Duration
+	VerifyURL      string
+	ResendInterval time.Duration
+	OnSendError    func(error)
+}
+
+// EmailVerificationService confirms that new users own the email address
+// they signed up with, with a hashed single-use token sent to it.
+type EmailVerificationService struct {
+	userSvc                  *UserService
+	userRepository           repository.UserRepository
+	verifyTokenRepository    repository.EmailVerificationTokenRepository
+	resetTokenRepository     repository.PasswordResetTokenRepository
+	magicLinkTokenRepository repository.MagicLinkTokenRepository
+	mailer                   mailer.Sender
+	options                  EmailVerificationOptions
+}
+
+func NewEmailVerificationSvc(
+	us *UserService,
+	ur repository.UserRepository,
+	evr repository.EmailVerificationTokenRepository,
+	prr repository.PasswordResetTokenRepository,
+	mlr repository.MagicLinkTokenRepository,
+	m mailer.Sender,
+	opts EmailVerificationOptions,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		userSvc:                  us,
+		userRepository:           ur,
+		verifyTokenRepository:    evr,
+		resetTokenRepository:     prr,
+		magicLinkTokenRepository: mlr,
+		mailer:                   m,
+		options:                  opts,
+	}
+}
+
+// SignUp creates the user and emails a verification link. The user is
+// returned even when sending fails, along with an ErrSendMail error, as
+// the link can be sent again with Resend.
+func (s EmailVerificationService) SignUp(data []byte) ([]byte, error) {
+	u, err := s.userSvc.createUser(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sendErr := s.sendVerificationLink(u)
+
+	u.Password = ""
+
+	response, err := json.Marshal(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, sendErr
+}
+
+// Resend emails a new verification link. It succeeds whether or not the
+// user exists or is already verified and sends in the background, so that
+// neither the response nor its timing tell account names apart.
+func (s EmailVerificationService) Resend(data []byte) error {
+	var resendDto dto.ResendVerificationRequestDTO
+
+	if err := json.Unmarshal(data, &resendDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(resendDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.userRepository.GetByName(resendDto.Username)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	sendInBackground(func() error { return s.sendVerificationLink(u) }, s.options.OnSendError)
+
+	return nil
+}
+
+// UpdateProfile changes the own account of the user. When the email
+// address changes, the verification, reset and sign-in links sent to the
+// old one stop working, the old address is told about the change and a verification
+// link is sent to the new one. As with SignUp, the account is returned
+// along with an ErrSendMail error when sending fails.
+func (s EmailVerificationService) UpdateProfile(userID uint, data []byte, clientIP string) ([]byte, error) {
+	u, previousName, err := s.userSvc.updateProfile(userID, data, clientIP)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sendErr error
+
+	if previousName != "" {
+		if err := s.verifyTokenRepository.DeleteByUser(u.ID); err != nil {
+			return nil, err
+		}
+
+		if err := s.resetTokenRepository.DeleteByUser(u.ID); err != nil {
+			return nil, err
+		}
+
+		if err := s.magicLinkTokenRepository.DeleteByUser(u.ID); err != nil {
+			return nil, err
+		}
+
+		sendErr = errors.Join(s.sendEmailChangedNotice(previousName, u.Name), s.sendVerificationLink(u))
+	}
+
+	u.Password = ""
+
+	response, err := json.Marshal(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, sendErr
+}
+
+// Verify marks the email address of the user of the token as verified.
+// Verifying twice succeeds.
+func (s EmailVerificationService) Verify(data []byte) error {
+	var verifyDto dto.VerifyEmailRequestDTO
+
+	if err := json.Unmarshal(data, &verifyDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(verifyDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	t, err := s.verifyTokenRepository.GetByHash(hashOpaqueToken(verifyDto.Token))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	if time.Now().After(t.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	u, err := s.userRepository.GetByID(t.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	// the token proves access to the address it was sent to only
+	if u.Name != t.Email {
+		return ErrInvalidVerificationToken
+	}
+
+	if u.EmailVerified() {
+		return nil
+	}
+
+	consumed, err := s.verifyTokenRepository.Consume(t.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrInvalidVerificationToken
+	}
+
+	return s.userRepository.MarkEmailVerified(u.ID, time.Now())
+}
+
+func (s EmailVerificationService) PurgeExpiredTokens() (int64, error) {
+	return s.verifyTokenRepository.DeleteExpired(time.Now())
+}
+
+// sendVerificationLink issues a verification token for u and mails it.
+// Verified users, users who got a link less than ResendInterval ago and
+// users whose name is not an email address are skipped.
+func (s EmailVerificationService) sendVerificationLink(u *entity.User) error {
+	address, err := mail.ParseAddress(u.Name)
+
+	if err != nil || u.EmailVerified() {
+		return nil
+	}
+
+	now := time.Now()
+
+	marked, err := s.userRepository.MarkVerificationSent(u.ID, now, now.Add(-s.options.ResendInterval))
+
+	if err != nil {
+		return err
+	}
+
+	if !marked {
+		return nil
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+
+	if err != nil {
+		return ErrGenerateToken
+	}
+
+	t := &entity.EmailVerificationToken{
+		UserID:    u.ID,
+		Email:     u.Name,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(s.options.TokenTTL),
+	}
+
+	if _, err := s.verifyTokenRepository.Create(t); err != nil {
+		return fmt.Errorf("%w: %w", ErrGenerateToken, err)
+	}
+
+	link, err := linkWithToken(s.options.VerifyURL, token)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      address.Address,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Welcome! Confirm that %s is your email address by opening this link:\n%s\n\n"+
+				"The link is valid for %s. If you did not sign up, ignore this email.\n",
+			u.Name, link, s.options.TokenTTL,
+		),
+	})
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendMail, err)
+	}
+
+	return nil
+}
+
+// sendEmailChangedNotice tells the previous address of an account that it
+// is no longer the one of the account, so that a hijack does not go
+// unnoticed.
+func (s EmailVerificationService) sendEmailChangedNotice(previousName, newName string) error {
+	address, err := mail.ParseAddress(previousName)
+
+	if err != nil {
+		return nil
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      address.Address,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf(
+			"The email address of your account was changed from %s to %s.\n\n"+
+				"Password reset and sign-in links sent to this address no longer work. "+
+				"If you did not make this change, contact support right away.\n",
+			previousName, newName,
+		),
+	})
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendMail, err)
+	}
+
+	return nil
+}
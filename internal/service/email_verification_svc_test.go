@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"testing"
+	"time"
+)
+
+func newTestEmailVerificationSvc(e *testEnv, m *testMailer) *EmailVerificationService {
+	return NewEmailVerificationSvc(
+		e.userSvc,
+		repository.NewUserDBRepository(e.db),
+		repository.NewEmailVerificationTokenDBRepository(e.db),
+		repository.NewPasswordResetTokenDBRepository(e.db),
+		repository.NewMagicLinkTokenDBRepository(e.db),
+		m,
+		EmailVerificationOptions{
+			TokenTTL:  time.Hour,
+			VerifyURL: "https://example.com/verify-email",
+		},
+	)
+}
+
+func verifyToken(t *testing.T, token string) []byte {
+	return mustMarshal(t, dto.VerifyEmailRequestDTO{Token: token})
+}
+
+func TestEmailVerification(t *testing.T) {
+	const address = "new@example.com"
+
+	tests := []struct {
+		name string
+		// prepare runs after sign-up and returns the token to verify with
+		prepare func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string
+		wantErr error
+	}{
+		{
+			name: "emailed token",
+			prepare: func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string {
+				return token
+			},
+		},
+		{
+			name: "verifying twice",
+			prepare: func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string {
+				if err := s.Verify(verifyToken(t, token)); err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "unknown token",
+			prepare: func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string {
+				return token + "x"
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "expired token",
+			prepare: func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string {
+				err := e.db.Model(&entity.EmailVerificationToken{}).Where("1 = 1").
+					Update("expires_at", time.Now().Add(-time.Second)).Error
+
+				if err != nil {
+					t.Fatalf("expiring token: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "token sent to a previous address",
+			prepare: func(t *testing.T, e *testEnv, s *EmailVerificationService, token string) string {
+				u, err := e.userSvc.userRepository.GetByName(address)
+
+				if err != nil {
+					t.Fatalf("GetByName() error = %v", err)
+				}
+
+				u.Name = "other@example.com"
+
+				if _, err := e.userSvc.userRepository.Update(u); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			m := &testMailer{}
+			s := newTestEmailVerificationSvc(e, m)
+
+			if _, err := s.SignUp(mustMarshal(t, dto.CreateUserDTO{Username: address, Password: testPassword})); err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+
+			token := tt.prepare(t, e, s, m.lastToken(t, address))
+
+			if err := s.Verify(verifyToken(t, token)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			u, err := e.userSvc.userRepository.GetByName(address)
+
+			if err != nil || !u.EmailVerified() {
+				t.Errorf("user = %+v, %v, want a verified user", u, err)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationStoresTokenHashed(t *testing.T) {
+	e := newTestEnv(t)
+	m := &testMailer{}
+	s := newTestEmailVerificationSvc(e, m)
+
+	if _, err := s.SignUp(mustMarshal(t, dto.CreateUserDTO{Username: "new@example.com", Password: testPassword})); err != nil {
+		t.Fatalf("SignUp() error = %v", err)
+	}
+
+	token := m.lastToken(t, "new@example.com")
+
+	var stored entity.EmailVerificationToken
+
+	if err := e.db.First(&stored).Error; err != nil {
+		t.Fatalf("loading token: %v", err)
+	}
+
+	if stored.TokenHash == token || stored.TokenHash != hashOpaqueToken(token) {
+		t.Errorf("stored hash = %q, want the hash of the emailed token", stored.TokenHash)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"strings"
+	"time"
+)
+
+const (
+	accountSubjectPrefix = "user:"
+	ipSubjectPrefix      = "ip:"
+
+	// accountFreeFailures wrong passwords are tolerated without delay,
+	// later ones double the wait starting at backoffBaseDelay.
+	accountFreeFailures = 3
+	backoffBaseDelay    = time.Second
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed sign-in attempts error")
+	ErrAccountLocked   = errors.New("account is temporarily locked error")
+)
+
+// ThrottleError tells when signing in may be retried.
+type ThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// LockoutOptions configures LoginGuard. Failures older than
+// LockoutDuration are forgotten.
+type LockoutOptions struct {
+	MaxFailures     int
+	LockoutDuration time.Duration
+	IPMaxFailures   int
+}
+
+// LoginGuard tracks failed sign-ins per account and per client address.
+// Both back off exponentially; accounts are locked for LockoutDuration
+// once MaxFailures is reached. Attempts are counted before the password
+// hash is compared, so that concurrent attempts see each other and
+// throttled ones cost no hashing.
+type LoginGuard struct {
+	loginFailureRepository repository.LoginFailureRepository
+	options                LockoutOptions
+}
+
+func NewLoginGuard(lfr repository.LoginFailureRepository, opts LockoutOptions) *LoginGuard {
+	return &LoginGuard{loginFailureRepository: lfr, options: opts}
+}
+
+// Attempt counts a sign-in attempt of username from ip as failed until
+// RecordSuccess takes it back, and returns a *ThrottleError when it has to
+// wait. The count is taken in one statement, so of concurrent attempts
+// only as many pass as the limits allow.
+func (g LoginGuard) Attempt(username, ip string) error {
+	seen, err := g.loginFailureRepository.GetBySubjects(g.subjects(username, ip)...)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if throttle := g.throttle(seen, now); throttle != nil {
+		return throttle
+	}
+
+	windowStart := now.Add(-g.options.LockoutDuration)
+	var raced []*entity.LoginFailure
+
+	for _, subject := range g.subjects(username, ip) {
+		failures, err := g.loginFailureRepository.Increment(subject, now, windowStart)
+
+		if err != nil {
+			return err
+		}
+
+		// attempts counted since the check above count as failures that
+		// just happened
+		if previous := failures - 1; previous > seenFailures(seen, subject, windowStart) {
+			raced = append(raced, &entity.LoginFailure{Subject: subject, Failures: previous, LastFailureAt: now})
+		}
+	}
+
+	if throttle := g.throttle(raced, now); throttle != nil {
+		return throttle
+	}
+
+	return nil
+}
+
+// RecordSuccess forgets the failures of the account and takes back the
+// attempt of the address. Other failures of the address are kept,
+// otherwise signing in to one account would reset the backoff of guesses
+// against others.
+func (g LoginGuard) RecordSuccess(username, ip string) error {
+	if err := g.loginFailureRepository.Delete(accountSubject(username)); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	return g.loginFailureRepository.Decrement(ipSubjectPrefix + ip)
+}
+
+// throttle returns the longest wait that failures impose at now, or nil.
+func (g LoginGuard) throttle(failures []*entity.LoginFailure, now time.Time) *ThrottleError {
+	var throttle *ThrottleError
+
+	for _, f := range failures {
+		if f.LastFailureAt.Before(now.Add(-g.options.LockoutDuration)) {
+			continue
+		}
+
+		reason := ErrTooManyAttempts
+		var delay time.Duration
+
+		switch {
+		case strings.HasPrefix(f.Subject, accountSubjectPrefix) && f.Failures >= g.options.MaxFailures:
+			reason = ErrAccountLocked
+			delay = g.options.LockoutDuration
+		case strings.HasPrefix(f.Subject, accountSubjectPrefix):
+			delay = g.backoff(f.Failures, accountFreeFailures)
+		default:
+			delay = g.backoff(f.Failures, g.options.IPMaxFailures)
+		}
+
+		retryAfter := f.LastFailureAt.Add(delay).Sub(now)
+
+		if retryAfter <= 0 {
+			continue
+		}
+
+		// a lockout is reported over a backoff of the address, otherwise
+		// the longest wait wins
+		locked := errors.Is(reason, ErrAccountLocked)
+
+		if throttle == nil || locked || !errors.Is(throttle.Err, ErrAccountLocked) && retryAfter > throttle.RetryAfter {
+			throttle = &ThrottleError{Err: reason, RetryAfter: retryAfter}
+		}
+	}
+
+	return throttle
+}
+
+// Unlock lifts a lockout of the account before it expires.
+func (g LoginGuard) Unlock(username string) error {
+	return g.loginFailureRepository.Delete(accountSubject(username))
+}
+
+func (g LoginGuard) PurgeExpired() (int64, error) {
+	return g.loginFailureRepository.DeleteBefore(time.Now().Add(-g.options.LockoutDuration))
+}
+
+// backoff doubles the delay for every failure past the free ones, capped
+// at the lockout duration.
+func (g LoginGuard) backoff(failures, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+
+	delay := backoffBaseDelay
+
+	for i := free + 1; i < failures && delay < g.options.LockoutDuration; i++ {
+		delay *= 2
+	}
+
+	return min(delay, g.options.LockoutDuration)
+}
+
+// seenFailures returns the failures of subject that are still counted,
+// those before windowStart are forgotten.
+func seenFailures(failures []*entity.LoginFailure, subject string, windowStart time.Time) int {
+	for _, f := range failures {
+		if f.Subject == subject && !f.LastFailureAt.Before(windowStart) {
+			return f.Failures
+		}
+	}
+
+	return 0
+}
+
+func (g LoginGuard) subjects(username, ip string) []string {
+	subjects := []string{accountSubject(username)}
+
+	if ip != "" {
+		subjects = append(subjects, ipSubjectPrefix+ip)
+	}
+
+	return subjects
+}
+
+// accountSubject is keyed by name, not by user ID, so unknown names are
+// throttled the same way and lockouts do not reveal which accounts exist.
+func accountSubject(username string) string {
+	return accountSubjectPrefix + strings.ToLower(strings.TrimSpace(username))
+}
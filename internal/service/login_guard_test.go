@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g := LoginGuard{options: testLockout}
+
+	tests := []struct {
+		failures int
+		free     int
+		want     time.Duration
+	}{
+		{failures: 0, free: 3, want: 0},
+		{failures: 3, free: 3, want: 0},
+		{failures: 4, free: 3, want: time.Second},
+		{failures: 5, free: 3, want: 2 * time.Second},
+		{failures: 6, free: 3, want: 4 * time.Second},
+		{failures: 13, free: 3, want: 512 * time.Second},
+		{failures: 14, free: 3, want: testLockout.LockoutDuration},
+		{failures: 1000, free: 3, want: testLockout.LockoutDuration},
+		{failures: 21, free: 20, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := g.backoff(tt.failures, tt.free); got != tt.want {
+			t.Errorf("backoff(%d, %d) = %v, want %v", tt.failures, tt.free, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardThrottle(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures []*entity.LoginFailure
+		wantErr  error
+		// wantRetry is the expected wait, give or take the test runtime
+		wantRetry time.Duration
+	}{
+		{name: "no failures"},
+		{
+			name:     "free failures",
+			failures: []*entity.LoginFailure{{Subject: "user:a@example.com", Failures: accountFreeFailures, LastFailureAt: now}},
+		},
+		{
+			name:      "account backoff",
+			failures:  []*entity.LoginFailure{{Subject: "user:a@example.com", Failures: 4, LastFailureAt: now}},
+			wantErr:   ErrTooManyAttempts,
+			wantRetry: time.Second,
+		},
+		{
+			name:     "account backoff over",
+			failures: []*entity.LoginFailure{{Subject: "user:a@example.com", Failures: 4, LastFailureAt: now.Add(-2 * time.Second)}},
+		},
+		{
+			name:      "account locked",
+			failures:  []*entity.LoginFailure{{Subject: "user:a@example.com", Failures: testLockout.MaxFailures, LastFailureAt: now.Add(-time.Minute)}},
+			wantErr:   ErrAccountLocked,
+			wantRetry: testLockout.LockoutDuration - time.Minute,
+		},
+		{
+			name: "lockout forgotten",
+			failures: []*entity.LoginFailure{
+				{Subject: "user:a@example.com", Failures: 100, LastFailureAt: now.Add(-testLockout.LockoutDuration - time.Second)},
+			},
+		},
+		{
+			name:     "address below its limit",
+			failures: []*entity.LoginFailure{{Subject: "ip:192.0.2.1", Failures: testLockout.IPMaxFailures, LastFailureAt: now}},
+		},
+		{
+			name:      "address backoff",
+			failures:  []*entity.LoginFailure{{Subject: "ip:192.0.2.1", Failures: testLockout.IPMaxFailures + 3, LastFailureAt: now}},
+			wantErr:   ErrTooManyAttempts,
+			wantRetry: 4 * time.Second,
+		},
+		{
+			name: "longest backoff wins",
+			failures: []*entity.LoginFailure{
+				{Subject: "user:a@example.com", Failures: 4, LastFailureAt: now},
+				{Subject: "ip:192.0.2.1", Failures: testLockout.IPMaxFailures + 3, LastFailureAt: now},
+			},
+			wantErr:   ErrTooManyAttempts,
+			wantRetry: 4 * time.Second,
+		},
+		{
+			name: "lockout wins over a longer address backoff",
+			failures: []*entity.LoginFailure{
+				{Subject: "ip:192.0.2.1", Failures: testLockout.IPMaxFailures + 20, LastFailureAt: now},
+				{Subject: "user:a@example.com", Failures: testLockout.MaxFailures, LastFailureAt: now.Add(-10 * time.Minute)},
+			},
+			wantErr:   ErrAccountLocked,
+			wantRetry: testLockout.LockoutDuration - 10*time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewLoginGuard(&testLoginFailures{failures: tt.failures}, testLockout)
+
+			err := g.Attempt("A@example.com ", "192.0.2.1")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Attempt() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			var throttle *ThrottleError
+
+			if !errors.As(err, &throttle) {
+				t.Fatalf("Attempt() error = %v, want a *ThrottleError", err)
+			}
+
+			if d := tt.wantRetry - throttle.RetryAfter; d < 0 || d > time.Second {
+				t.Errorf("RetryAfter = %v, want %v", throttle.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+// failSignIns counts failures of the account username from ip.
+func (e *testEnv) failSignIns(t *testing.T, username, ip string, failures int) {
+	t.Helper()
+
+	r := repository.NewLoginFailureDBRepository(e.db)
+	now := time.Now()
+
+	for range failures {
+		for _, subject := range []string{accountSubject(username), ipSubjectPrefix + ip} {
+			if _, err := r.Increment(subject, now, now.Add(-testLockout.LockoutDuration)); err != nil {
+				t.Fatalf("Increment() error = %v", err)
+			}
+		}
+	}
+}
+
+func TestLoginGuardAttempt(t *testing.T) {
+	e := newTestEnv(t)
+	g := NewLoginGuard(repository.NewLoginFailureDBRepository(e.db), testLockout)
+
+	e.failSignIns(t, "a@example.com", "192.0.2.1", testLockout.MaxFailures)
+
+	if err := g.Attempt("a@example.com", "198.51.100.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Attempt() of the account error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	// other accounts from the same address are below the address limit
+	if err := g.Attempt("b@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("Attempt() of another account error = %v", err)
+	}
+
+	if err := g.RecordSuccess("A@EXAMPLE.COM", "192.0.2.1"); err != nil {
+		t.Fatalf("RecordSuccess() error = %v", err)
+	}
+
+	if err := g.Attempt("a@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("Attempt() after a success error = %v", err)
+	}
+}
+
+func TestLoginGuardSuccessesDoNotThrottleAddress(t *testing.T) {
+	e := newTestEnv(t)
+	g := NewLoginGuard(repository.NewLoginFailureDBRepository(e.db), testLockout)
+
+	for i := range testLockout.IPMaxFailures * 2 {
+		username := fmt.Sprintf("user%d@example.com", i)
+
+		if err := g.Attempt(username, "192.0.2.1"); err != nil {
+			t.Fatalf("Attempt() %d error = %v", i, err)
+		}
+
+		if err := g.RecordSuccess(username, "192.0.2.1"); err != nil {
+			t.Fatalf("RecordSuccess() error = %v", err)
+		}
+	}
+}
+
+func TestLoginGuardConcurrentAttempts(t *testing.T) {
+	e := newTestEnv(t)
+	g := NewLoginGuard(repository.NewLoginFailureDBRepository(e.db), testLockout)
+
+	const attempts = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+
+	for i := range attempts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// every attempt comes from another address
+			errs <- g.Attempt("a@example.com", fmt.Sprintf("192.0.2.%d", i))
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	passed := 0
+
+	for err := range errs {
+		var throttle *ThrottleError
+
+		switch {
+		case err == nil:
+			passed++
+		case !errors.As(err, &throttle):
+			t.Fatalf("Attempt() error = %v", err)
+		}
+	}
+
+	// the free failures pass, the first one after them has to wait
+	if want := accountFreeFailures + 1; passed != want {
+		t.Errorf("%d of %d concurrent attempts passed, want %d", passed, attempts, want)
+	}
+}
+
+// testLoginFailures serves fixed failures and does not count new ones.
+type testLoginFailures struct {
+	failures []*entity.LoginFailure
+}
+
+func (r *testLoginFailures) GetBySubjects(subjects ...string) ([]*entity.LoginFailure, error) {
+	var found []*entity.LoginFailure
+
+	for _, f := range r.failures {
+		for _, s := range subjects {
+			if f.Subject == s {
+				found = append(found, f)
+			}
+		}
+	}
+
+	return found, nil
+}
+
+func (r *testLoginFailures) Increment(subject string, now, windowStart time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *testLoginFailures) Decrement(subject string) error {
+	return nil
+}
+
+func (r *testLoginFailures) Delete(subject string) error {
+	return nil
+}
+
+func (r *testLoginFailures) DeleteBefore(before time.Time) (int64, error) {
+	return 0, nil
+}
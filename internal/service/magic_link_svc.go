@@ -0,0 +1,202 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/mailer"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"gorm.io/gorm"
+	"net/mail"
+	"time"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired sign-in link error")
+
+// MagicLinkOptions configures the sign-in links. The token is added to
+// LoginURL as the token query parameter. OnSendError receives the errors of
+// links sent in the background.
+type MagicLinkOptions struct {
+	TokenTTL    time.Duration
+	LoginURL    string
+	OnSendError func(error)
+}
+
+// MagicLinkService signs users in without a password, with a single-use
+// token sent to their email address.
+type MagicLinkService struct {
+	userSvc                  *UserService
+	userRepository           repository.UserRepository
+	magicLinkTokenRepository repository.MagicLinkTokenRepository
+	mailer                   mailer.Sender
+	options                  MagicLinkOptions
+}
+
+func NewMagicLinkSvc(
+	us *UserService,
+	ur repository.UserRepository,
+	mlr repository.MagicLinkTokenRepository,
+	m mailer.Sender,
+	opts MagicLinkOptions,
+) *MagicLinkService {
+	return &MagicLinkService{
+		userSvc:                  us,
+		userRepository:           ur,
+		magicLinkTokenRepository: mlr,
+		mailer:                   m,
+		options:                  opts,
+	}
+}
+
+// Request emails a sign-in link to the user. It succeeds whether or not
+// the user exists and sends in the background, so that neither the
+// response nor its timing tell account names apart.
+func (s MagicLinkService) Request(data []byte) error {
+	var requestDto dto.MagicLinkRequestDTO
+
+	if err := json.Unmarshal(data, &requestDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(requestDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.userRepository.GetByName(requestDto.Username)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	sendInBackground(func() error { return s.sendMagicLink(u) }, s.options.OnSendError)
+
+	return nil
+}
+
+// Verify exchanges the token of a sign-in link for a token pair, or an MFA
+// challenge when the user enabled a second factor. Opening the link proves
+// access to the email address, which is marked as verified. The lockout
+// and backoff of Authenticate apply as well.
+func (s MagicLinkService) Verify(data []byte, client Client, signer signing.Signer) ([]byte, error) {
+	var verifyDto dto.MagicLinkVerifyDTO
+
+	if err := json.Unmarshal(data, &verifyDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(verifyDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	t, err := s.magicLinkTokenRepository.GetByHash(hashOpaqueToken(verifyDto.Token))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+
+	if t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	u, err := s.userRepository.GetByID(t.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+
+	// a locked account keeps the link, it works once the lockout is over
+	if err := s.userSvc.loginGuard.Attempt(u.Name, client.IP); err != nil {
+		return nil, err
+	}
+
+	consumed, err := s.magicLinkTokenRepository.Consume(t.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !consumed {
+		return nil, ErrInvalidMagicLink
+	}
+
+	if !u.EmailVerified() {
+		now := time.Now()
+
+		if err := s.userRepository.MarkEmailVerified(u.ID, now); err != nil {
+			return nil, err
+		}
+
+		u.EmailVerifiedAt = &now
+	}
+
+	if err := s.userSvc.loginGuard.RecordSuccess(u.Name, client.IP); err != nil {
+		return nil, err
+	}
+
+	return s.userSvc.completeSignIn(u, client, signer, false)
+}
+
+func (s MagicLinkService) PurgeExpiredTokens() (int64, error) {
+	return s.magicLinkTokenRepository.DeleteExpired(time.Now())
+}
+
+// sendMagicLink issues a sign-in token for u and mails it. Users whose
+// name is not an email address cannot receive one and are skipped.
+func (s MagicLinkService) sendMagicLink(u *entity.User) error {
+	address, err := mail.ParseAddress(u.Name)
+
+	if err != nil {
+		return nil
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+
+	if err != nil {
+		return ErrGenerateToken
+	}
+
+	t := &entity.MagicLinkToken{
+		UserID:    u.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.options.TokenTTL),
+	}
+
+	if _, err := s.magicLinkTokenRepository.Create(t); err != nil {
+		return fmt.Errorf("%w: %w", ErrGenerateToken, err)
+	}
+
+	link, err := linkWithToken(s.options.LoginURL, token)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      address.Address,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Someone asked to sign in to your account %s.\n\n"+
+				"Open this link to sign in:\n%s\n\n"+
+				"The link is valid for %s and works once. If you did not ask for it, ignore this email.\n",
+			u.Name, link, s.options.TokenTTL,
+		),
+	})
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendMail, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"testing"
+	"time"
+)
+
+func newTestMagicLinkSvc(e *testEnv, m *testMailer, onSendError func(error)) *MagicLinkService {
+	return NewMagicLinkSvc(
+		e.userSvc,
+		repository.NewUserDBRepository(e.db),
+		repository.NewMagicLinkTokenDBRepository(e.db),
+		m,
+		MagicLinkOptions{
+			TokenTTL:    time.Hour,
+			LoginURL:    "https://example.com/magic-link",
+			OnSendError: onSendError,
+		},
+	)
+}
+
+func TestMagicLinkRequestRespondsAlike(t *testing.T) {
+	const address = "user@example.com"
+
+	errMailDown := errors.New("mail server down")
+
+	tests := []struct {
+		name     string
+		username string
+		mailErr  error
+		// wantSendErr is the error reported after responding, if any
+		wantSendErr error
+		wantLink    bool
+	}{
+		{name: "existing user", username: address, wantLink: true},
+		{name: "unknown user", username: "nobody@example.com"},
+		{name: "mail server down", username: address, mailErr: errMailDown, wantSendErr: errMailDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.createUser(t, address)
+
+			m := &testMailer{err: tt.mailErr}
+			sendErrs := make(chan error, 1)
+			s := newTestMagicLinkSvc(e, m, func(err error) { sendErrs <- err })
+
+			if err := s.Request(mustMarshal(t, dto.MagicLinkRequestDTO{Username: tt.username})); err != nil {
+				t.Fatalf("Request() error = %v, want nil", err)
+			}
+
+			if tt.wantLink {
+				m.lastToken(t, tt.username)
+			}
+
+			if tt.wantSendErr == nil {
+				return
+			}
+
+			select {
+			case err := <-sendErrs:
+				if !errors.Is(err, ErrSendMail) || !errors.Is(err, tt.wantSendErr) {
+					t.Errorf("reported error = %v, want %v", err, tt.wantSendErr)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("sending error was not reported")
+			}
+		})
+	}
+}
+
+func TestMagicLinkVerifyLoginGuard(t *testing.T) {
+	const address = "user@example.com"
+
+	e := newTestEnv(t)
+	u := e.createUser(t, address)
+	m := &testMailer{}
+	s := newTestMagicLinkSvc(e, m, nil)
+
+	if err := s.Request(mustMarshal(t, dto.MagicLinkRequestDTO{Username: address})); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+
+	link := mustMarshal(t, dto.MagicLinkVerifyDTO{Token: m.lastToken(t, address)})
+
+	e.failSignIns(t, u.Name, "198.51.100.1", testLockout.MaxFailures)
+
+	if _, err := s.Verify(link, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Verify() of a locked account error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	if err := e.userSvc.loginGuard.Unlock(u.Name); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+
+	// the link was not used up by the refused attempt
+	if _, err := s.Verify(link, Client{IP: "192.0.2.1"}, e.signer); err != nil {
+		t.Fatalf("Verify() after unlocking error = %v", err)
+	}
+
+	if _, err := s.Verify(link, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("second Verify() error = %v, want %v", err, ErrInvalidMagicLink)
+	}
+}
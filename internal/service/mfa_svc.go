@@ -0,0 +1,387 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/hash"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"github.com/SomchaiSPB/user-auth/internal/totp"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	// mfaMaxAttempts is the number of wrong codes a challenge accepts
+	// before the password has to be entered again.
+	mfaMaxAttempts = 5
+
+	recoveryCodeCount = 10
+	// recoveryCodeLength characters of recoveryCodeAlphabet carry 50 bits.
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled error")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled error")
+	ErrMFANotEnrolled    = errors.New("totp enrollment is not started error")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code error")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token error")
+)
+
+// EnrollTOTP generates a new TOTP secret for the user. It is only enforced
+// once ConfirmTOTP proved the authenticator app was set up.
+func (s UserService) EnrollTOTP(userID uint) ([]byte, error) {
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if u.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepository.SetTOTP(u.ID, secret, nil); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(dto.TOTPEnrollResponseDTO{
+		Secret: secret,
+		URI:    totp.URI(s.tokenOptions.Issuer, u.Name, secret),
+	})
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proved the
+// enrollment worked, and returns the recovery codes.
+func (s UserService) ConfirmTOTP(userID uint, data []byte) ([]byte, error) {
+	codeDto, err := parseTOTPCode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if u.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if u.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	counter, ok := totp.Validate(u.TOTPSecret, codeDto.Code, time.Now())
+
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	now := time.Now()
+
+	if err := s.userRepository.SetTOTP(u.ID, u.TOTPSecret, &now); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepository.UseTOTPCounter(u.ID, counter); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(u.ID)
+}
+
+// DisableTOTP turns two-factor authentication off, requiring a TOTP or a
+// recovery code, and drops the recovery codes.
+func (s UserService) DisableTOTP(userID uint, data []byte) error {
+	codeDto, err := parseTOTPCode(data)
+
+	if err != nil {
+		return err
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return err
+	}
+
+	if !u.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	if err := s.verifySecondFactor(u, codeDto.Code, codeDto.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := s.userRepository.SetTOTP(u.ID, "", nil); err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepository.DeleteByUser(u.ID)
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and
+// returns new ones, requiring a TOTP code.
+func (s UserService) RegenerateRecoveryCodes(userID uint, data []byte) ([]byte, error) {
+	codeDto, err := parseTOTPCode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.MFAEnabled() {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verifySecondFactor(u, codeDto.Code, ""); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(u.ID)
+}
+
+// VerifyMFA completes a sign-in started by Authenticate. Each challenge is
+// single-use and rejects further codes after mfaMaxAttempts wrong ones.
+func (s UserService) VerifyMFA(data []byte, client Client, signer signing.Signer) ([]byte, error) {
+	var verifyDto dto.MFAVerifyRequestDTO
+
+	if err := json.Unmarshal(data, &verifyDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(verifyDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	c, err := s.mfaChallengeRepository.GetByHash(hashOpaqueToken(verifyDto.MFAToken))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if c.UsedAt != nil || c.Attempts >= mfaMaxAttempts || time.Now().After(c.ExpiresAt) {
+		return nil, ErrInvalidMFAToken
+	}
+
+	u, err := s.userRepository.GetByID(c.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if err := s.verifySecondFactor(u, verifyDto.Code, verifyDto.RecoveryCode); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return nil, err
+		}
+
+		attemptsLeft, recErr := s.mfaChallengeRepository.RecordFailure(c.ID, mfaMaxAttempts)
+
+		if recErr != nil {
+			return nil, recErr
+		}
+
+		if !attemptsLeft {
+			return nil, ErrInvalidMFAToken
+		}
+
+		return nil, err
+	}
+
+	consumed, err := s.mfaChallengeRepository.Consume(c.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !consumed {
+		return nil, ErrInvalidMFAToken
+	}
+
+	return s.completeSignIn(u, client, signer, true)
+}
+
+func (s UserService) PurgeExpiredMFAChallenges() (int64, error) {
+	return s.mfaChallengeRepository.DeleteExpired(time.Now())
+}
+
+func (s UserService) startMFAChallenge(u *entity.User) (*dto.MFAChallengeResponseDTO, error) {
+	token, tokenHash, err := generateOpaqueToken()
+
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	c := &entity.MFAChallenge{
+		UserID:    u.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.tokenOptions.MFAChallengeTTL),
+	}
+
+	if _, err := s.mfaChallengeRepository.Create(c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGenerateToken, err)
+	}
+
+	return &dto.MFAChallengeResponseDTO{
+		MFARequired:  true,
+		MFAToken:     token,
+		MFAExpiresAt: c.ExpiresAt.Unix(),
+	}, nil
+}
+
+// verifySecondFactor accepts either a TOTP code not used before or an
+// unused recovery code, consuming it.
+func (s UserService) verifySecondFactor(u *entity.User, code, recoveryCode string) error {
+	if !u.MFAEnabled() {
+		return ErrInvalidMFACode
+	}
+
+	if code != "" {
+		counter, ok := totp.Validate(u.TOTPSecret, code, time.Now())
+
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		fresh, err := s.userRepository.UseTOTPCounter(u.ID, counter)
+
+		if err != nil {
+			return err
+		}
+
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	codes, err := s.recoveryCodeRepository.GetUnused(u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	normalized := normalizeRecoveryCode(recoveryCode)
+
+	for _, rc := range codes {
+		if !s.hasher.CheckPasswordHash(normalized, rc.CodeHash) {
+			continue
+		}
+
+		consumed, err := s.recoveryCodeRepository.Consume(rc.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if !consumed {
+			return ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	return ErrInvalidMFACode
+}
+
+func (s UserService) replaceRecoveryCodes(userID uint) ([]byte, error) {
+	codes, hashes, err := generateRecoveryCodes(s.hasher)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recoveryCodeRepository.Replace(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(dto.RecoveryCodesResponseDTO{RecoveryCodes: codes})
+}
+
+func (s UserService) getUser(id uint) (*entity.User, error) {
+	u, err := s.userRepository.GetByID(id)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func parseTOTPCode(data []byte) (*dto.TOTPCodeRequestDTO, error) {
+	var codeDto dto.TOTPCodeRequestDTO
+
+	if err := json.Unmarshal(data, &codeDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(codeDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	return &codeDto, nil
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx for display
+// together with the hashes of their normalized form.
+func generateRecoveryCodes(h hash.Hasher) ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength)
+
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+
+		codeHash, err := h.HashPassword(string(b))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		hashes[i] = codeHash
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mfaSetup is what a user keeps from enabling TOTP.
+type mfaSetup struct {
+	secret      string
+	confirmCode string
+	recovery    []string
+}
+
+// enableTOTP enrolls u and confirms it with the code of the current time
+// step.
+func (e *testEnv) enableTOTP(t *testing.T, u *entity.User) mfaSetup {
+	t.Helper()
+
+	response, err := e.userSvc.EnrollTOTP(u.ID)
+
+	if err != nil {
+		t.Fatalf("EnrollTOTP() error = %v", err)
+	}
+
+	var enrolled dto.TOTPEnrollResponseDTO
+
+	if err := json.Unmarshal(response, &enrolled); err != nil {
+		t.Fatalf("decoding enrollment: %v", err)
+	}
+
+	setup := mfaSetup{secret: enrolled.Secret, confirmCode: totpCode(t, enrolled.Secret, 0)}
+
+	response, err = e.userSvc.ConfirmTOTP(u.ID, mustMarshal(t, dto.TOTPCodeRequestDTO{Code: setup.confirmCode}))
+
+	if err != nil {
+		t.Fatalf("ConfirmTOTP() error = %v", err)
+	}
+
+	var recovery dto.RecoveryCodesResponseDTO
+
+	if err := json.Unmarshal(response, &recovery); err != nil || len(recovery.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("response %s does not hold %d recovery codes", response, recoveryCodeCount)
+	}
+
+	setup.recovery = recovery.RecoveryCodes
+
+	return setup
+}
+
+// totpCode returns the code of the time step steps away from now.
+func totpCode(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Counter(time.Now())+steps)
+
+	if err != nil {
+		t.Fatalf("Code() error = %v", err)
+	}
+
+	return code
+}
+
+// startMFA signs u in with a password and returns the MFA token.
+func (e *testEnv) startMFA(t *testing.T, u *entity.User) string {
+	t.Helper()
+
+	response, err := e.userSvc.completeSignIn(u, Client{}, e.signer, false)
+
+	if err != nil {
+		t.Fatalf("completeSignIn() error = %v", err)
+	}
+
+	var challenge dto.MFAChallengeResponseDTO
+
+	if err := json.Unmarshal(response, &challenge); err != nil || !challenge.MFARequired {
+		t.Fatalf("response %s is not an MFA challenge", response)
+	}
+
+	return challenge.MFAToken
+}
+
+func TestVerifyMFA(t *testing.T) {
+	tests := []struct {
+		name string
+		// codes returns the codes of the attempts on one challenge, the
+		// last of which is checked against wantErr
+		codes   func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO
+		wantErr error
+	}{
+		{
+			name: "next totp code",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				return []dto.MFAVerifyRequestDTO{{Code: totpCode(t, setup.secret, 1)}}
+			},
+		},
+		{
+			name: "totp code used to confirm the enrollment",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				return []dto.MFAVerifyRequestDTO{{Code: setup.confirmCode}}
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "totp code of an earlier step",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				return []dto.MFAVerifyRequestDTO{{Code: totpCode(t, setup.secret, -1)}}
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "recovery code",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				return []dto.MFAVerifyRequestDTO{{RecoveryCode: setup.recovery[3]}}
+			},
+		},
+		{
+			name: "recovery code typed with spaces and upper case",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				return []dto.MFAVerifyRequestDTO{{RecoveryCode: " " + strings.ToUpper(setup.recovery[3]) + " "}}
+			},
+		},
+		{
+			name: "wrong codes until the challenge is used up",
+			codes: func(t *testing.T, setup mfaSetup) []dto.MFAVerifyRequestDTO {
+				codes := make([]dto.MFAVerifyRequestDTO, mfaMaxAttempts)
+
+				for i := range codes {
+					codes[i] = dto.MFAVerifyRequestDTO{RecoveryCode: "wrong"}
+				}
+				return append(codes, dto.MFAVerifyRequestDTO{Code: totpCode(t, setup.secret, 1)})
+			},
+			wantErr: ErrInvalidMFAToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			setup := e.enableTOTP(t, u)
+
+			u, err := e.userSvc.userRepository.GetByID(u.ID)
+
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+
+			token := e.startMFA(t, u)
+
+			for _, attempt := range tt.codes(t, setup) {
+				attempt.MFAToken = token
+				_, err = e.userSvc.VerifyMFA(mustMarshal(t, attempt), Client{}, e.signer)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyMFA() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyMFAConsumesCodes(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	recovery := e.enableTOTP(t, u).recovery
+
+	u, err := e.userSvc.userRepository.GetByID(u.ID)
+
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+
+	token := e.startMFA(t, u)
+	verify := mustMarshal(t, dto.MFAVerifyRequestDTO{MFAToken: token, RecoveryCode: recovery[0]})
+
+	if _, err := e.userSvc.VerifyMFA(verify, Client{}, e.signer); err != nil {
+		t.Fatalf("VerifyMFA() error = %v", err)
+	}
+
+	if _, err := e.userSvc.VerifyMFA(verify, Client{}, e.signer); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("VerifyMFA() with a used challenge error = %v, want %v", err, ErrInvalidMFAToken)
+	}
+
+	verify = mustMarshal(t, dto.MFAVerifyRequestDTO{MFAToken: e.startMFA(t, u), RecoveryCode: recovery[0]})
+
+	if _, err := e.userSvc.VerifyMFA(verify, Client{}, e.signer); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA() with a used recovery code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
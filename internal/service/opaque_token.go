@@ -0,0 +1,43 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const opaqueTokenBytes = 32
+
+// generateOpaqueToken returns a random url-safe token and its hash.
+// Only the hash is meant to be persisted.
+func generateOpaqueToken() (string, string, error) {
+	b := make([]byte, opaqueTokenBytes)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+const tokenIDBytes = 16
+
+// generateTokenID returns a random identifier suitable for the jti claim.
+func generateTokenID() (string, error) {
+	b := make([]byte, tokenIDBytes)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
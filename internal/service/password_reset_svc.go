@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/mailer"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"gorm.io/gorm"
+	"net/mail"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token error")
+	ErrSendMail          = errors.New("sending email error")
+	ErrNoEmailAddress    = errors.New("user name is not an email address error")
+)
+
+// PasswordResetOptions configures the reset links. The token is added to
+// ResetURL as the token query parameter. OnSendError receives the errors of
+// links sent in the background.
+type PasswordResetOptions struct {
+	TokenTTL    time.Duration
+	ResetURL    string
+	OnSendError func(error)
+}
+
+// PasswordResetService lets users who forgot their password set a new one
+// with a single-use token sent to their email address.
+type PasswordResetService struct {
+	userSvc              *UserService
+	userRepository       repository.UserRepository
+	resetTokenRepository repository.PasswordResetTokenRepository
+	mailer               mailer.Sender
+	options              PasswordResetOptions
+}
+
+func NewPasswordResetSvc(
+	us *UserService,
+	ur repository.UserRepository,
+	prr repository.PasswordResetTokenRepository,
+	m mailer.Sender,
+	opts PasswordResetOptions,
+) *PasswordResetService {
+	return &PasswordResetService{
+		userSvc:              us,
+		userRepository:       ur,
+		resetTokenRepository: prr,
+		mailer:               m,
+		options:              opts,
+	}
+}
+
+// Forgot emails a reset link to the user. It succeeds whether or not the
+// user exists and sends in the background, so that neither the response
+// nor its timing tell account names apart.
+func (s PasswordResetService) Forgot(data []byte) error {
+	var forgotDto dto.ForgotPasswordRequestDTO
+
+	if err := json.Unmarshal(data, &forgotDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(forgotDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.userRepository.GetByName(forgotDto.Username)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	sendInBackground(func() error { return s.sendResetLink(u) }, s.options.OnSendError)
+
+	return nil
+}
+
+// Reset sets the new password, revokes the refresh tokens of the user and
+// lifts a sign-in lockout.
+func (s PasswordResetService) Reset(data []byte) error {
+	var resetDto dto.ResetPasswordRequestDTO
+
+	if err := json.Unmarshal(data, &resetDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(resetDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	t, err := s.resetTokenRepository.GetByHash(hashOpaqueToken(resetDto.Token))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	u, err := s.userRepository.GetByID(t.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// the policy is checked before consuming, so a rejected password can be
+	// corrected with the same link
+	if err := s.userSvc.passwordPolicy.Check(resetDto.Password, u.Name); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	consumed, err := s.resetTokenRepository.Consume(t.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.userSvc.setPassword(u, resetDto.Password); err != nil {
+		if errors.Is(err, ErrPasswordChanged) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// other links sent before are no longer needed
+	return s.resetTokenRepository.DeleteByUser(u.ID)
+}
+
+// ForceReset clears the password of the user, revokes its refresh tokens
+// and emails a reset link, so that the user has to choose a new password
+// before signing in with one again.
+func (s PasswordResetService) ForceReset(id string) error {
+	userID, err := parseUserID(id)
+
+	if err != nil {
+		return err
+	}
+
+	u, err := s.userSvc.getUser(userID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := mail.ParseAddress(u.Name); err != nil {
+		return ErrNoEmailAddress
+	}
+
+	// no hash matches an empty one, Reset replaces it
+	cleared, err := s.userRepository.ReplacePassword(u.ID, u.Password, "")
+
+	if err != nil {
+		return err
+	}
+
+	if !cleared {
+		return ErrPasswordChanged
+	}
+
+	u.Password = ""
+
+	if err := s.userSvc.revokeSessions(u.ID, ""); err != nil {
+		return err
+	}
+
+	return s.sendResetLink(u)
+}
+
+func (s PasswordResetService) PurgeExpiredTokens() (int64, error) {
+	return s.resetTokenRepository.DeleteExpired(time.Now())
+}
+
+// sendResetLink issues a reset token for u and mails it. Users whose name
+// is not an email address cannot receive one and are skipped.
+func (s PasswordResetService) sendResetLink(u *entity.User) error {
+	address, err := mail.ParseAddress(u.Name)
+
+	if err != nil {
+		return nil
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+
+	if err != nil {
+		return ErrGenerateToken
+	}
+
+	t := &entity.PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.options.TokenTTL),
+	}
+
+	if _, err := s.resetTokenRepository.Create(t); err != nil {
+		return fmt.Errorf("%w: %w", ErrGenerateToken, err)
+	}
+
+	link, err := linkWithToken(s.options.ResetURL, token)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      address.Address,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your account %s.\n\n"+
+				"Open this link to choose a new password:\n%s\n\n"+
+				"The link is valid for %s and works once. If you did not ask for it, ignore this email.\n",
+			u.Name, link, s.options.TokenTTL,
+		),
+	})
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendMail, err)
+	}
+
+	return nil
+}
+
+// linkWithToken adds token to the query of base.
+func linkWithToken(base, token string) (string, error) {
+	u, err := url.Parse(base)
+
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// sendInBackground runs send without making the request wait for it and
+// passes its error to onError, when set.
+func sendInBackground(send func() error, onError func(error)) {
+	go func() {
+		if err := send(); err != nil && onError != nil {
+			onError(err)
+		}
+	}()
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/password"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestForgotRespondsAlike(t *testing.T) {
+	const address = "user@example.com"
+
+	errMailDown := errors.New("mail server down")
+
+	tests := []struct {
+		name     string
+		username string
+		mailErr  error
+		// wantSendErr is the error reported after responding, if any
+		wantSendErr error
+		wantLink    bool
+	}{
+		{name: "existing user", username: address, wantLink: true},
+		{name: "unknown user", username: "nobody@example.com"},
+		{name: "mail server down", username: address, mailErr: errMailDown, wantSendErr: errMailDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.createUser(t, address)
+
+			m := &testMailer{err: tt.mailErr}
+			sendErrs := make(chan error, 1)
+
+			s := NewPasswordResetSvc(
+				e.userSvc,
+				repository.NewUserDBRepository(e.db),
+				repository.NewPasswordResetTokenDBRepository(e.db),
+				m,
+				PasswordResetOptions{
+					TokenTTL:    time.Hour,
+					ResetURL:    "https://example.com/reset-password",
+					OnSendError: func(err error) { sendErrs <- err },
+				},
+			)
+
+			if err := s.Forgot(mustMarshal(t, dto.ForgotPasswordRequestDTO{Username: tt.username})); err != nil {
+				t.Fatalf("Forgot() error = %v, want nil", err)
+			}
+
+			if tt.wantLink {
+				m.lastToken(t, tt.username)
+			}
+
+			if tt.wantSendErr == nil {
+				return
+			}
+
+			select {
+			case err := <-sendErrs:
+				if !errors.Is(err, ErrSendMail) || !errors.Is(err, tt.wantSendErr) {
+					t.Errorf("reported error = %v, want %v", err, tt.wantSendErr)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("sending error was not reported")
+			}
+		})
+	}
+}
+
+func TestForgotRejectsInvalidRequest(t *testing.T) {
+	e := newTestEnv(t)
+	s := NewPasswordResetSvc(
+		e.userSvc,
+		repository.NewUserDBRepository(e.db),
+		repository.NewPasswordResetTokenDBRepository(e.db),
+		&testMailer{},
+		PasswordResetOptions{TokenTTL: time.Hour, ResetURL: "https://example.com/reset-password"},
+	)
+
+	if err := s.Forgot([]byte(`{}`)); !errors.Is(err, ErrValidation) {
+		t.Errorf("Forgot() error = %v, want %v", err, ErrValidation)
+	}
+}
+
+func newTestPasswordResetSvc(e *testEnv, m *testMailer) *PasswordResetService {
+	return NewPasswordResetSvc(
+		e.userSvc,
+		repository.NewUserDBRepository(e.db),
+		repository.NewPasswordResetTokenDBRepository(e.db),
+		m,
+		PasswordResetOptions{TokenTTL: time.Hour, ResetURL: "https://example.com/reset-password"},
+	)
+}
+
+// resetToken mails a reset link to u and returns its token.
+func resetToken(t *testing.T, s *PasswordResetService, m *testMailer, u *entity.User) string {
+	t.Helper()
+
+	if err := s.sendResetLink(u); err != nil {
+		t.Fatalf("sendResetLink() error = %v", err)
+	}
+
+	return m.lastToken(t, u.Name)
+}
+
+func resetRequest(t *testing.T, token, password string) []byte {
+	return mustMarshal(t, dto.ResetPasswordRequestDTO{Token: token, Password: password})
+}
+
+func TestReset(t *testing.T) {
+	e := newTestEnv(t)
+	m := &testMailer{}
+	s := newTestPasswordResetSvc(e, m)
+	u := e.createUser(t, "user@example.com")
+
+	tokens := e.signIn(t, u)
+	older := resetToken(t, s, m, u)
+	token := resetToken(t, s, m, u)
+	e.failSignIns(t, u.Name, "192.0.2.1", testLockout.MaxFailures)
+
+	if err := s.Reset(resetRequest(t, token, "a new password")); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	stored, err := e.userSvc.getUser(u.ID)
+
+	if err != nil {
+		t.Fatalf("getUser() error = %v", err)
+	}
+
+	if !e.userSvc.hasher.CheckPasswordHash("a new password", stored.Password) {
+		t.Errorf("new password does not match the stored hash")
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() of a session before the reset error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if err := e.userSvc.loginGuard.Attempt(u.Name, "198.51.100.1"); err != nil {
+		t.Errorf("Attempt() after the reset error = %v, want the lockout lifted", err)
+	}
+
+	if err := s.Reset(resetRequest(t, token, "another password")); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Reset() with a used token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+
+	if err := s.Reset(resetRequest(t, older, "another password")); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Reset() with an older token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestResetRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// request returns the reset request to send with the token mailed to u
+		request func(t *testing.T, e *testEnv, token string) []byte
+		wantErr error
+	}{
+		{
+			name: "unknown token",
+			request: func(t *testing.T, e *testEnv, token string) []byte {
+				return resetRequest(t, token+"x", "a new password")
+			},
+			wantErr: ErrInvalidResetToken,
+		},
+		{
+			name: "expired token",
+			request: func(t *testing.T, e *testEnv, token string) []byte {
+				err := e.db.Model(&entity.PasswordResetToken{}).Where("1 = 1").
+					Update("expires_at", time.Now().Add(-time.Second)).Error
+
+				if err != nil {
+					t.Fatalf("expiring token: %v", err)
+				}
+				return resetRequest(t, token, "a new password")
+			},
+			wantErr: ErrInvalidResetToken,
+		},
+		{
+			name:    "missing password",
+			request: func(t *testing.T, e *testEnv, token string) []byte { return resetRequest(t, token, "") },
+			wantErr: ErrValidation,
+		},
+		{
+			name:    "missing token",
+			request: func(t *testing.T, e *testEnv, token string) []byte { return resetRequest(t, "", "a new password") },
+			wantErr: ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			m := &testMailer{}
+			s := newTestPasswordResetSvc(e, m)
+			u := e.createUser(t, "user@example.com")
+			tokens := e.signIn(t, u)
+
+			if err := s.Reset(tt.request(t, e, resetToken(t, s, m, u))); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reset() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := e.refresh(tokens.RefreshToken); err != nil {
+				t.Errorf("Refresh() after a rejected reset error = %v", err)
+			}
+		})
+	}
+}
+
+func TestResetPolicyRejectionKeepsToken(t *testing.T) {
+	e := newTestEnv(t)
+	e.userSvc.passwordPolicy = password.Policy{MinLength: 10}
+	m := &testMailer{}
+	s := newTestPasswordResetSvc(e, m)
+	u := e.createUser(t, "user@example.com")
+	token := resetToken(t, s, m, u)
+
+	if err := s.Reset(resetRequest(t, token, "short")); !errors.Is(err, ErrValidation) {
+		t.Fatalf("Reset() with a weak password error = %v, want %v", err, ErrValidation)
+	}
+
+	if err := s.Reset(resetRequest(t, token, "a new password")); err != nil {
+		t.Errorf("Reset() after a policy rejection error = %v", err)
+	}
+}
+
+func TestForceReset(t *testing.T) {
+	e := newTestEnv(t)
+	m := &testMailer{}
+	s := newTestPasswordResetSvc(e, m)
+	u := e.createUser(t, "user@example.com")
+	tokens := e.signIn(t, u)
+
+	if err := s.ForceReset(strconv.Itoa(int(u.ID))); err != nil {
+		t.Fatalf("ForceReset() error = %v", err)
+	}
+
+	stored, err := e.userSvc.getUser(u.ID)
+
+	if err != nil {
+		t.Fatalf("getUser() error = %v", err)
+	}
+
+	if stored.Password != "" {
+		t.Errorf("password hash after ForceReset() = %q, want it cleared", stored.Password)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after ForceReset() error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	signIn := mustMarshal(t, dto.AuthUserRequestDTO{Username: u.Name, Password: testPassword})
+
+	if _, err := e.userSvc.Authenticate(signIn, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrWrongCredentials) {
+		t.Errorf("Authenticate() with the old password error = %v, want %v", err, ErrWrongCredentials)
+	}
+
+	// the mailed link sets a new password
+	if err := s.Reset(resetRequest(t, m.lastToken(t, u.Name), "a new password")); err != nil {
+		t.Errorf("Reset() with the mailed token error = %v", err)
+	}
+}
+
+func TestForceResetRejects(t *testing.T) {
+	e := newTestEnv(t)
+	m := &testMailer{}
+	s := newTestPasswordResetSvc(e, m)
+	u := e.createUser(t, "user")
+
+	tests := []struct {
+		id      string
+		wantErr error
+	}{
+		{id: strconv.Itoa(int(u.ID)), wantErr: ErrNoEmailAddress},
+		{id: "999", wantErr: ErrUserNotFound},
+		{id: "x", wantErr: ErrInvalidUserID},
+	}
+
+	for _, tt := range tests {
+		if err := s.ForceReset(tt.id); !errors.Is(err, tt.wantErr) {
+			t.Errorf("ForceReset(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+		}
+	}
+
+	if len(m.sent) != 0 {
+		t.Errorf("ForceReset() sent %d messages, want none", len(m.sent))
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter error")
+
+// productSortColumns whitelists the fields accepted by the sort parameter.
+var productSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"price":      "price",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// productFilterParsers maps the supported query parameters to typed filters.
+var productFilterParsers = map[string]func(string) (repository.Filter, error){
+	"price_gte": func(v string) (repository.Filter, error) {
+		price, err := strconv.ParseFloat(v, 64)
+		return repository.PriceGte(price), err
+	},
+	"price_lte": func(v string) (repository.Filter, error) {
+		price, err := strconv.ParseFloat(v, 64)
+		return repository.PriceLte(price), err
+	},
+	"name_contains": func(v string) (repository.Filter, error) {
+		return repository.NameContains(v), nil
+	},
+	"created_after": func(v string) (repository.Filter, error) {
+		t, err := parseFilterTime(v)
+		return repository.CreatedAfter(t), err
+	},
+	"created_before": func(v string) (repository.Filter, error) {
+		t, err := parseFilterTime(v)
+		return repository.CreatedBefore(t), err
+	},
+}
+
+// parseProductQuery turns list query parameters such as
+// price_gte=10&name_contains=ipa&sort=-price,name into filters and sorts.
+// Parameters that are not filters are ignored.
+func parseProductQuery(query url.Values) ([]repository.Filter, []repository.Sort, error) {
+	var filters []repository.Filter
+
+	for param, parse := range productFilterParsers {
+		for _, v := range query[param] {
+			f, err := parse(v)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %w: %s=%s", ErrValidation, ErrInvalidFilter, param, v)
+			}
+
+			filters = append(filters, f)
+		}
+	}
+
+	sorts, err := parseSort(query.Get("sort"), productSortColumns)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filters, sorts, nil
+}
+
+// parseSort parses a comma separated field list, a leading minus sorts descending.
+func parseSort(sort string, columns map[string]string) ([]repository.Sort, error) {
+	if sort == "" {
+		return nil, nil
+	}
+
+	var sorts []repository.Sort
+
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+
+		column, ok := columns[field]
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %w: cannot sort by %q", ErrValidation, ErrInvalidFilter, field)
+		}
+
+		sorts = append(sorts, repository.Sort{Column: column, Desc: desc})
+	}
+
+	return sorts, nil
+}
+
+// parseFilterTime accepts RFC 3339 timestamps and plain dates.
+func parseFilterTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProductQuery(t *testing.T) {
+	createdAfter := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	createdBefore := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFilters []repository.Filter
+		wantSorts   []repository.Sort
+		wantErr     bool
+	}{
+		{name: "no parameters"},
+		{name: "other parameters are ignored", query: "limit=10&after=abc&price=5"},
+		{
+			name:  "filters",
+			query: "price_gte=10&price_lte=99.5&name_contains=iPa&created_after=2024-05-01&created_before=2024-06-01T12:30:00Z",
+			wantFilters: []repository.Filter{
+				repository.PriceGte(10),
+				repository.PriceLte(99.5),
+				repository.NameContains("iPa"),
+				repository.CreatedAfter(createdAfter),
+				repository.CreatedBefore(createdBefore),
+			},
+		},
+		{
+			name:        "repeated parameter",
+			query:       "price_gte=10&price_gte=20",
+			wantFilters: []repository.Filter{repository.PriceGte(10), repository.PriceGte(20)},
+		},
+		{
+			name:      "sort",
+			query:     "sort=-price, name",
+			wantSorts: []repository.Sort{{Column: "price", Desc: true}, {Column: "name"}},
+		},
+		{
+			name:      "only the first sort parameter",
+			query:     "sort=price&sort=-name",
+			wantSorts: []repository.Sort{{Column: "price"}},
+		},
+		{name: "invalid price", query: "price_gte=cheap", wantErr: true},
+		{name: "empty price", query: "price_lte=", wantErr: true},
+		{name: "invalid time", query: "created_after=yesterday", wantErr: true},
+		{name: "one invalid repeated value", query: "price_gte=10&price_gte=x", wantErr: true},
+		{name: "sort by a column that is not listed", query: "sort=description", wantErr: true},
+		{name: "sort by an expression", query: "sort=price%3Bdrop+table+products", wantErr: true},
+		{name: "empty sort field", query: "sort=price,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			filters, sorts, err := parseProductQuery(query)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) || !errors.Is(err, ErrInvalidFilter) {
+					t.Errorf("parseProductQuery() error = %v, want %v and %v", err, ErrValidation, ErrInvalidFilter)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseProductQuery() error = %v", err)
+			}
+
+			if got, want := filterStrings(filters), filterStrings(tt.wantFilters); !reflect.DeepEqual(got, want) {
+				t.Errorf("parseProductQuery() filters = %v, want %v", got, want)
+			}
+
+			if !reflect.DeepEqual(sorts, tt.wantSorts) {
+				t.Errorf("parseProductQuery() sorts = %+v, want %+v", sorts, tt.wantSorts)
+			}
+		})
+	}
+}
@@ -3,21 +3,36 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
 	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/suggest"
 	"gorm.io/gorm"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
-	ErrEmptyRequestName = errors.New("product name is empty in uri query error")
-	ErrProductNotFound  = errors.New("product not found error")
+	ErrEmptyRequestName  = errors.New("product name is empty in uri query error")
+	ErrProductNotFound   = errors.New("product not found error")
+	ErrProductNameExists = errors.New("product name already exists error")
+	ErrInvalidProductID  = errors.New("invalid product id error")
+	ErrSaveProduct       = errors.New("product save error")
+	ErrEmptySearchQuery  = errors.New("search query is empty error")
+	ErrEmptySuggestQuery = errors.New("suggest prefix is empty error")
 )
 
 type ProductService struct {
 	productRepository repository.ProductRepository
+	suggestIndex      *suggest.Index
 }
 
-func NewProductSvc(pr repository.ProductRepository) *ProductService {
-	return &ProductService{productRepository: pr}
+func NewProductSvc(pr repository.ProductRepository, idx *suggest.Index) *ProductService {
+	return &ProductService{productRepository: pr, suggestIndex: idx}
 }
 
 func (s ProductService) GetProduct(name string) ([]byte, error) {
@@ -36,10 +51,35 @@ func (s ProductService) GetProduct(name string) ([]byte, error) {
 	return json.Marshal(p)
 }
 
-func (s ProductService) GetProducts(page, perPage int) ([]byte, error) {
-	offset := (page - 1) * perPage
+// GetProducts lists products narrowed down by the filters and sort order in query.
+func (s ProductService) GetProducts(req pagination.Request, query url.Values) ([]byte, *pagination.Meta, error) {
+	filters, sorts, err := parseProductQuery(query)
 
-	p, err := s.productRepository.Get(perPage, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page, err := s.productRepository.GetWithFilters(req, sorts, filters...)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(page.Items)
+
+	return data, &page.Meta, err
+}
+
+// Search returns up to limit products ranked by relevance to query.
+func (s ProductService) Search(query string, limit int) ([]byte, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrEmptySearchQuery
+	}
+
+	p, err := s.productRepository.Search(query, limit)
 
 	if err != nil {
 		return nil, err
@@ -47,3 +87,222 @@ func (s ProductService) GetProducts(page, perPage int) ([]byte, error) {
 
 	return json.Marshal(p)
 }
+
+// Suggest returns up to limit product names matching prefix, tolerating typos.
+func (s ProductService) Suggest(prefix string, limit int) ([]byte, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return nil, ErrEmptySuggestQuery
+	}
+
+	return json.Marshal(s.suggestIndex.Suggest(prefix, limit))
+}
+
+// RebuildSuggestIndex reloads the suggest index from the repository, picking
+// up writes made by other instances.
+func (s ProductService) RebuildSuggestIndex() error {
+	names, err := s.productRepository.GetNames()
+
+	if err != nil {
+		return err
+	}
+
+	s.suggestIndex.Rebuild(names)
+
+	return nil
+}
+
+func (s ProductService) Create(data []byte) ([]byte, error) {
+	var productDto dto.CreateProductDTO
+
+	if err := json.Unmarshal(data, &productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	p := &entity.Product{
+		Name:        productDto.Name,
+		Description: productDto.Description,
+		Price:       productDto.Price,
+	}
+
+	created, err := s.productRepository.Create(p)
+
+	if err != nil {
+		return nil, saveProductErr(p.Name, err)
+	}
+
+	s.suggestIndex.Put(created.ID, created.Name)
+
+	return json.Marshal(created)
+}
+
+// Update replaces every editable field of the product.
+func (s ProductService) Update(id string, data []byte) ([]byte, error) {
+	var productDto dto.UpdateProductDTO
+
+	if err := json.Unmarshal(data, &productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	p, err := s.getByID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.Name = productDto.Name
+	p.Description = productDto.Description
+	p.Price = productDto.Price
+
+	updated, err := s.productRepository.Update(p)
+
+	if err != nil {
+		return nil, saveProductErr(p.Name, err)
+	}
+
+	s.suggestIndex.Put(updated.ID, updated.Name)
+
+	return json.Marshal(updated)
+}
+
+// Patch updates only the fields present in the request.
+func (s ProductService) Patch(id string, data []byte) ([]byte, error) {
+	var productDto dto.PatchProductDTO
+
+	if err := json.Unmarshal(data, &productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(productDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	p, err := s.getByID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if productDto.Name != nil {
+		p.Name = *productDto.Name
+	}
+	if productDto.Description != nil {
+		p.Description = *productDto.Description
+	}
+	if productDto.Price != nil {
+		p.Price = *productDto.Price
+	}
+
+	updated, err := s.productRepository.Update(p)
+
+	if err != nil {
+		return nil, saveProductErr(p.Name, err)
+	}
+
+	s.suggestIndex.Put(updated.ID, updated.Name)
+
+	return json.Marshal(updated)
+}
+
+func (s ProductService) Delete(id string) error {
+	productID, err := parseProductID(id)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.productRepository.Delete(productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+
+	s.suggestIndex.Remove(productID)
+
+	return nil
+}
+
+func (s ProductService) getByID(id string) (*entity.Product, error) {
+	productID, err := parseProductID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.productRepository.GetByID(productID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func parseProductID(id string) (uint, error) {
+	productID, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil || productID == 0 {
+		return 0, fmt.Errorf("%s: %w", id, ErrInvalidProductID)
+	}
+
+	return uint(productID), nil
+}
+
+func saveProductErr(name string, err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%s: %w", name, ErrProductNameExists)
+	}
+
+	return fmt.Errorf("%w: %w", ErrSaveProduct, err)
+}
+
+func (s ProductService) GetDeletedProducts(req pagination.Request) ([]byte, *pagination.Meta, error) {
+	page, err := s.productRepository.GetDeleted(req)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(page.Items)
+
+	return data, &page.Meta, err
+}
+
+func (s ProductService) Restore(id string) ([]byte, error) {
+	productID, err := parseProductID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.productRepository.Restore(productID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	s.suggestIndex.Put(p.ID, p.Name)
+
+	return json.Marshal(p)
+}
+
+func (s ProductService) PurgeDeleted(before time.Time) (int64, error) {
+	return s.productRepository.PurgeDeleted(before)
+}
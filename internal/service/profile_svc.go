@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"gorm.io/gorm"
+)
+
+// Me returns the account of the authenticated user.
+func (s UserService) Me(userID uint) ([]byte, error) {
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.Password = ""
+
+	return json.Marshal(u)
+}
+
+// updateProfile changes the fields of the own account present in data. A
+// new username is an email address still to be verified; changing it
+// requires the current password, as it is where reset links are sent.
+// previousName is the old username when it changed.
+func (s UserService) updateProfile(userID uint, data []byte, clientIP string) (updated *entity.User, previousName string, err error) {
+	var profileDto dto.UpdateProfileDTO
+
+	if err := json.Unmarshal(data, &profileDto); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(profileDto); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if profileDto.Username != nil && *profileDto.Username != u.Name {
+		if err := s.checkCurrentPassword(u, profileDto.CurrentPassword, clientIP); err != nil {
+			return nil, "", err
+		}
+
+		if exists := s.userRepository.Exists(*profileDto.Username); exists {
+			return nil, "", fmt.Errorf("%s: %w", *profileDto.Username, ErrUserNameExists)
+		}
+
+		previousName = u.Name
+		u.Name = *profileDto.Username
+		u.EmailVerifiedAt = nil
+		u.VerificationSentAt = nil
+	}
+
+	updated, err = s.userRepository.Update(u)
+
+	if err != nil {
+		// soft deleted users keep their name until purged
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, "", fmt.Errorf("%s: %w", u.Name, ErrUserNameExists)
+		}
+		return nil, "", err
+	}
+
+	return updated, previousName, nil
+}
+
+// ChangePassword replaces the password of the own account, requiring the
+// current one. Every session is signed out and a new one is started for
+// client. Wrong current passwords are throttled like failed sign-ins.
+func (s UserService) ChangePassword(userID uint, data []byte, client Client, signer signing.Signer) ([]byte, error) {
+	var passwordDto dto.ChangePasswordDTO
+
+	if err := json.Unmarshal(data, &passwordDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(passwordDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCurrentPassword(u, passwordDto.CurrentPassword, client.IP); err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Check(passwordDto.NewPassword, u.Name); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := s.setPassword(u, passwordDto.NewPassword); err != nil {
+		return nil, err
+	}
+
+	session, err := s.startSession(u, client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.issueTokens(u, session, signer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+// DeleteAccount moves the own account to the trash, requiring the
+// password, and signs it out of every session.
+func (s UserService) DeleteAccount(userID uint, data []byte, clientIP string) error {
+	var deleteDto dto.DeleteAccountDTO
+
+	if err := json.Unmarshal(data, &deleteDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(deleteDto); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCurrentPassword(u, deleteDto.Password, clientIP); err != nil {
+		return err
+	}
+
+	if err := s.revokeSessions(u.ID, ""); err != nil {
+		return err
+	}
+
+	if err := s.userRepository.Delete(u.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkCurrentPassword confirms a sensitive change with the password of
+// u, so that a stolen access token alone is not enough.
+func (s UserService) checkCurrentPassword(u *entity.User, password, clientIP string) error {
+	if err := s.loginGuard.Attempt(u.Name, clientIP); err != nil {
+		return err
+	}
+
+	if ok := s.hasher.CheckPasswordHash(password, u.Password); !ok {
+		return ErrWrongCredentials
+	}
+
+	return s.loginGuard.RecordSuccess(u.Name, clientIP)
+}
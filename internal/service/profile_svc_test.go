@@ -0,0 +1,250 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/password"
+	"testing"
+	"time"
+)
+
+func TestChangePassword(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+
+	tokens := e.signIn(t, u)
+	session := e.session(t, tokens.RefreshToken)
+	change := mustMarshal(t, dto.ChangePasswordDTO{CurrentPassword: testPassword, NewPassword: "a new password"})
+
+	response, err := e.userSvc.ChangePassword(u.ID, change, Client{IP: "192.0.2.1"}, e.signer)
+
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	var changed dto.AuthUserResponseDTO
+
+	if err := json.Unmarshal(response, &changed); err != nil || changed.RefreshToken == "" {
+		t.Fatalf("response %s is not a token pair", response)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(session)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() of a session before the change error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() of a session before the change error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := e.refresh(changed.RefreshToken); err != nil {
+		t.Errorf("Refresh() of the new session error = %v", err)
+	}
+
+	stored, err := e.userSvc.getUser(u.ID)
+
+	if err != nil {
+		t.Fatalf("getUser() error = %v", err)
+	}
+
+	if !e.userSvc.hasher.CheckPasswordHash("a new password", stored.Password) {
+		t.Errorf("new password does not match the stored hash")
+	}
+}
+
+func TestChangePasswordRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  dto.ChangePasswordDTO
+		wantErr error
+	}{
+		{
+			name:    "wrong current password",
+			change:  dto.ChangePasswordDTO{CurrentPassword: "wrong", NewPassword: "a new password"},
+			wantErr: ErrWrongCredentials,
+		},
+		{
+			name:    "policy violation",
+			change:  dto.ChangePasswordDTO{CurrentPassword: testPassword, NewPassword: "short"},
+			wantErr: ErrValidation,
+		},
+		{
+			name:    "missing current password",
+			change:  dto.ChangePasswordDTO{NewPassword: "a new password"},
+			wantErr: ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.userSvc.passwordPolicy = password.Policy{MinLength: 10}
+			u := e.createUser(t, "user@example.com")
+			tokens := e.signIn(t, u)
+
+			_, err := e.userSvc.ChangePassword(u.ID, mustMarshal(t, tt.change), Client{IP: "192.0.2.1"}, e.signer)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// the password and the sessions are kept
+			if _, err := e.refresh(tokens.RefreshToken); err != nil {
+				t.Errorf("Refresh() after a rejected change error = %v", err)
+			}
+
+			stored, err := e.userSvc.getUser(u.ID)
+
+			if err != nil {
+				t.Fatalf("getUser() error = %v", err)
+			}
+
+			if !e.userSvc.hasher.CheckPasswordHash(testPassword, stored.Password) {
+				t.Errorf("password changed by a rejected change")
+			}
+		})
+	}
+}
+
+func TestChangePasswordThrottlesWrongPasswords(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+
+	wrong := mustMarshal(t, dto.ChangePasswordDTO{CurrentPassword: "wrong", NewPassword: "a new password"})
+	right := mustMarshal(t, dto.ChangePasswordDTO{CurrentPassword: testPassword, NewPassword: "a new password"})
+
+	for i := range accountFreeFailures + 1 {
+		if _, err := e.userSvc.ChangePassword(u.ID, wrong, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrWrongCredentials) {
+			t.Fatalf("ChangePassword() %d error = %v, want %v", i, err, ErrWrongCredentials)
+		}
+	}
+
+	// the right password has to wait as well
+	if _, err := e.userSvc.ChangePassword(u.ID, right, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("ChangePassword() after wrong passwords error = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	tests := []struct {
+		name         string
+		profile      dto.UpdateProfileDTO
+		wantErr      error
+		wantName     string
+		wantPrevious string
+		wantVerified bool
+	}{
+		{
+			name:         "new email address",
+			profile:      dto.UpdateProfileDTO{Username: ptr("new@example.com"), CurrentPassword: testPassword},
+			wantName:     "new@example.com",
+			wantPrevious: "user@example.com",
+		},
+		{
+			name:         "no changes",
+			wantName:     "user@example.com",
+			wantVerified: true,
+		},
+		{
+			name:    "missing current password",
+			profile: dto.UpdateProfileDTO{Username: ptr("new@example.com")},
+			wantErr: ErrValidation,
+		},
+		{
+			name:    "wrong current password",
+			profile: dto.UpdateProfileDTO{Username: ptr("new@example.com"), CurrentPassword: "wrong"},
+			wantErr: ErrWrongCredentials,
+		},
+		{
+			name:    "not an email address",
+			profile: dto.UpdateProfileDTO{Username: ptr("new"), CurrentPassword: testPassword},
+			wantErr: ErrValidation,
+		},
+		{
+			name:    "taken email address",
+			profile: dto.UpdateProfileDTO{Username: ptr("other@example.com"), CurrentPassword: testPassword},
+			wantErr: ErrUserNameExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			e.createUser(t, "other@example.com")
+
+			if err := e.db.Model(u).Update("email_verified_at", time.Now()).Error; err != nil {
+				t.Fatalf("verifying user: %v", err)
+			}
+
+			updated, previous, err := e.userSvc.updateProfile(u.ID, mustMarshal(t, tt.profile), "192.0.2.1")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("updateProfile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := e.userSvc.getUser(u.ID)
+
+			if err != nil {
+				t.Fatalf("getUser() error = %v", err)
+			}
+
+			if tt.wantErr != nil {
+				if stored.Name != u.Name || !stored.EmailVerified() {
+					t.Errorf("user after a rejected update = %q, verified %v, want it unchanged", stored.Name, stored.EmailVerified())
+				}
+				return
+			}
+
+			if updated.Name != tt.wantName || stored.Name != tt.wantName {
+				t.Errorf("updateProfile() name = %q, stored %q, want %q", updated.Name, stored.Name, tt.wantName)
+			}
+
+			if previous != tt.wantPrevious {
+				t.Errorf("updateProfile() previous name = %q, want %q", previous, tt.wantPrevious)
+			}
+
+			if stored.EmailVerified() != tt.wantVerified {
+				t.Errorf("verified = %v, want %v", stored.EmailVerified(), tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	tokens := e.signIn(t, u)
+
+	wrong := mustMarshal(t, dto.DeleteAccountDTO{Password: "wrong"})
+
+	if err := e.userSvc.DeleteAccount(u.ID, wrong, "192.0.2.1"); !errors.Is(err, ErrWrongCredentials) {
+		t.Fatalf("DeleteAccount() with a wrong password error = %v, want %v", err, ErrWrongCredentials)
+	}
+
+	if _, err := e.userSvc.getUser(u.ID); err != nil {
+		t.Fatalf("getUser() after a rejected deletion error = %v", err)
+	}
+
+	if err := e.userSvc.DeleteAccount(u.ID, []byte(`{}`), "192.0.2.1"); !errors.Is(err, ErrValidation) {
+		t.Errorf("DeleteAccount() without a password error = %v, want %v", err, ErrValidation)
+	}
+
+	right := mustMarshal(t, dto.DeleteAccountDTO{Password: testPassword})
+
+	if err := e.userSvc.DeleteAccount(u.ID, right, "192.0.2.1"); err != nil {
+		t.Fatalf("DeleteAccount() error = %v", err)
+	}
+
+	if _, err := e.userSvc.Me(u.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Me() of a deleted account error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() of a deleted account error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if err := e.userSvc.DeleteAccount(u.ID, right, "192.0.2.1"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeleteAccount() twice error = %v, want %v", err, ErrUserNotFound)
+	}
+}
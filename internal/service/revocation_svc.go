@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"sync"
+	"time"
+)
+
+// notRevokedCacheTTL bounds how long a negative lookup is trusted before the
+// database is consulted again, so revocations made by other instances are
+// picked up quickly while hot tokens do not hit the database on every request.
+const notRevokedCacheTTL = 5 * time.Second
+
+var (
+	ErrEmptyTokenID = errors.New("token id is empty error")
+	ErrRevokeToken  = errors.New("revoke token error")
+)
+
+// RevocationService keeps a denylist of access token ids backed by the
+// database with an in-memory cache in front of it.
+type RevocationService struct {
+	revokedTokenRepository repository.RevokedTokenRepository
+
+	mu         sync.RWMutex
+	revoked    map[string]time.Time
+	notRevoked map[string]time.Time
+}
+
+func NewRevocationSvc(rtr repository.RevokedTokenRepository) *RevocationService {
+	return &RevocationService{
+		revokedTokenRepository: rtr,
+		revoked:                make(map[string]time.Time),
+		notRevoked:             make(map[string]time.Time),
+	}
+}
+
+// Revoke denylists the token id until expiresAt, the moment the token
+// stops being valid on its own.
+func (s *RevocationService) Revoke(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return ErrEmptyTokenID
+	}
+
+	t := &entity.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}
+
+	if _, err := s.revokedTokenRepository.Create(t); err != nil {
+		return fmt.Errorf("%w: %w", ErrRevokeToken, err)
+	}
+
+	s.mu.Lock()
+	s.revoked[jti] = expiresAt
+	delete(s.notRevoked, jti)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *RevocationService) IsRevoked(jti string) (bool, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	_, revoked := s.revoked[jti]
+	checkedUntil, checked := s.notRevoked[jti]
+	s.mu.RUnlock()
+
+	if revoked {
+		return true, nil
+	}
+
+	if checked && now.Before(checkedUntil) {
+		return false, nil
+	}
+
+	exists, err := s.revokedTokenRepository.Exists(jti)
+
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if exists {
+		// the exact expiry is unknown here, keep it until the next purge
+		s.revoked[jti] = now.Add(tokenExpTime)
+	} else {
+		s.notRevoked[jti] = now.Add(notRevokedCacheTTL)
+	}
+	s.mu.Unlock()
+
+	return exists, nil
+}
+
+// PurgeExpired drops denylist entries for tokens that have expired anyway.
+func (s *RevocationService) PurgeExpired() (int64, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	for jti, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, jti)
+		}
+	}
+	for jti, exp := range s.notRevoked {
+		if now.After(exp) {
+			delete(s.notRevoked, jti)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.revokedTokenRepository.DeleteExpired(now)
+}
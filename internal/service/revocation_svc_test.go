@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"testing"
+	"time"
+)
+
+func newTestRevocationSvc(e *testEnv) *RevocationService {
+	return NewRevocationSvc(repository.NewRevokedTokenDBRepository(e.db))
+}
+
+func TestRevocationSvcRevoke(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestRevocationSvc(e)
+
+	if err := s.Revoke("", time.Now().Add(time.Hour)); !errors.Is(err, ErrEmptyTokenID) {
+		t.Errorf("Revoke() of an empty id error = %v, want %v", err, ErrEmptyTokenID)
+	}
+
+	if revoked, err := s.IsRevoked("a"); err != nil || revoked {
+		t.Fatalf("IsRevoked() = %v, %v, want false", revoked, err)
+	}
+
+	if err := s.Revoke("a", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	// revoking drops the cached negative lookup
+	if revoked, err := s.IsRevoked("a"); err != nil || !revoked {
+		t.Errorf("IsRevoked() after Revoke() = %v, %v, want true", revoked, err)
+	}
+
+	// other instances read the revocation from the database
+	if revoked, err := newTestRevocationSvc(e).IsRevoked("a"); err != nil || !revoked {
+		t.Errorf("IsRevoked() of another instance = %v, %v, want true", revoked, err)
+	}
+}
+
+func TestRevocationSvcSeesRevocationsOfOtherInstances(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestRevocationSvc(e)
+
+	if revoked, err := s.IsRevoked("a"); err != nil || revoked {
+		t.Fatalf("IsRevoked() = %v, %v, want false", revoked, err)
+	}
+
+	// another instance revokes the token
+	err := e.db.Create(&entity.RevokedToken{JTI: "a", ExpiresAt: time.Now().Add(time.Hour)}).Error
+
+	if err != nil {
+		t.Fatalf("creating revoked token: %v", err)
+	}
+
+	if revoked, _ := s.IsRevoked("a"); revoked {
+		t.Errorf("IsRevoked() within notRevokedCacheTTL = true, want the cached false")
+	}
+
+	// let notRevokedCacheTTL pass
+	s.mu.Lock()
+	s.notRevoked["a"] = time.Now().Add(-time.Millisecond)
+	s.mu.Unlock()
+
+	if revoked, err := s.IsRevoked("a"); err != nil || !revoked {
+		t.Errorf("IsRevoked() after notRevokedCacheTTL = %v, %v, want true", revoked, err)
+	}
+}
+
+func TestRevocationSvcPurgeExpired(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestRevocationSvc(e)
+
+	if err := s.Revoke("expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	if err := s.Revoke("valid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	if _, err := s.IsRevoked("unknown"); err != nil {
+		t.Fatalf("IsRevoked() error = %v", err)
+	}
+
+	s.mu.Lock()
+	s.notRevoked["unknown"] = time.Now().Add(-time.Millisecond)
+	s.mu.Unlock()
+
+	purged, err := s.PurgeExpired()
+
+	if err != nil {
+		t.Fatalf("PurgeExpired() error = %v", err)
+	}
+
+	if purged != 1 {
+		t.Errorf("PurgeExpired() = %d, want 1", purged)
+	}
+
+	s.mu.RLock()
+	_, expiredCached := s.revoked["expired"]
+	_, validCached := s.revoked["valid"]
+	_, unknownCached := s.notRevoked["unknown"]
+	s.mu.RUnlock()
+
+	if expiredCached || unknownCached {
+		t.Errorf("PurgeExpired() kept expired cache entries")
+	}
+
+	if !validCached {
+		t.Errorf("PurgeExpired() dropped a valid cache entry")
+	}
+
+	var stored []entity.RevokedToken
+
+	if err := e.db.Find(&stored).Error; err != nil {
+		t.Fatalf("listing revoked tokens: %v", err)
+	}
+
+	if len(stored) != 1 || stored[0].JTI != "valid" {
+		t.Errorf("revoked tokens after PurgeExpired() = %+v, want only valid", stored)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/hash"
+	"github.com/SomchaiSPB/user-auth/internal/mailer"
+	"github.com/SomchaiSPB/user-auth/internal/password"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct horse battery staple"
+
+var testLockout = LockoutOptions{
+	MaxFailures:     5,
+	LockoutDuration: 15 * time.Minute,
+	IPMaxFailures:   20,
+}
+
+// testEnv is a UserService on a fresh sqlite database.
+type testEnv struct {
+	db      *gorm.DB
+	userSvc *UserService
+	signer  signing.Signer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite3")), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+
+	err = db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.Role{}, &entity.Permission{},
+		&entity.MFAChallenge{}, &entity.RecoveryCode{}, &entity.WebAuthnCredential{}, &entity.WebAuthnChallenge{},
+		&entity.LoginFailure{}, &entity.PasswordResetToken{}, &entity.MagicLinkToken{}, &entity.EmailVerificationToken{},
+		&entity.Session{}, &entity.RevokedToken{})
+
+	if err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	if err := db.Create(&entity.Role{Name: entity.RoleUser}).Error; err != nil {
+		t.Fatalf("creating default role: %v", err)
+	}
+
+	key, err := signing.NewHMACKey("test", []byte("test secret"))
+
+	if err != nil {
+		t.Fatalf("creating signing key: %v", err)
+	}
+
+	userSvc := NewUserSvc(
+		repository.NewUserDBRepository(db),
+		repository.NewRefreshTokenDBRepository(db),
+		repository.NewRoleDBRepository(db),
+		repository.NewMFAChallengeDBRepository(db),
+		repository.NewRecoveryCodeDBRepository(db),
+		repository.NewSessionDBRepository(db),
+		NewLoginGuard(repository.NewLoginFailureDBRepository(db), testLockout),
+		password.Policy{},
+		hash.NewBcryptHasher(bcrypt.MinCost),
+		TokenOptions{
+			Issuer:          "test",
+			Audience:        "test-api",
+			RefreshTokenTTL: time.Hour,
+			MFAChallengeTTL: time.Minute,
+		},
+	)
+
+	return &testEnv{db: db, userSvc: userSvc, signer: key}
+}
+
+// createUser stores a user with testPassword.
+func (e *testEnv) createUser(t *testing.T, name string) *entity.User {
+	t.Helper()
+
+	hashedPass, err := e.userSvc.hasher.HashPassword(testPassword)
+
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	u, err := e.userSvc.userRepository.Create(&entity.User{Name: name, Password: hashedPass})
+
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	return u
+}
+
+// testMailer keeps the messages sent with it, or fails them with err.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	err  error
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// lastToken returns the token of the link in the last message sent to
+// address. It waits a moment for links sent in the background.
+func (m *testMailer) lastToken(t *testing.T, address string) string {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if token, ok := m.findToken(address); ok {
+			return token
+		}
+	}
+
+	t.Fatalf("no link was sent to %s", address)
+	return ""
+}
+
+func (m *testMailer) findToken(address string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != address {
+			continue
+		}
+
+		for _, field := range strings.Fields(m.sent[i].Body) {
+			if link, err := url.Parse(field); err == nil && link.Query().Has("token") {
+				return link.Query().Get("token"), true
+			}
+		}
+	}
+
+	return "", false
+}
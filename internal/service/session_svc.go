@@ -0,0 +1,235 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+// sessionTouchInterval is how stale the last seen time of a session may
+// get, so that busy clients do not write on every request.
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrSessionRevoked  = errors.New("session is revoked error")
+	ErrSessionNotFound = errors.New("session not found error")
+)
+
+// Client describes where a sign-in comes from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// GetSessions lists the active sessions of the user, flagging the one of
+// currentSessionID.
+func (s UserService) GetSessions(userID uint, currentSessionID string) ([]byte, error) {
+	sessions, err := s.sessionRepository.GetActiveByUser(userID, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.SessionResponseDTO, len(sessions))
+
+	for i, session := range sessions {
+		response[i] = dto.SessionResponseDTO{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    sessionID(session) == currentSessionID,
+		}
+	}
+
+	return json.Marshal(response)
+}
+
+// RevokeSession signs the user out of one of its sessions: its refresh
+// tokens are revoked and its access tokens rejected right away.
+func (s UserService) RevokeSession(userID uint, id string) error {
+	session, err := s.getSession(userID, id)
+
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.sessionRepository.Revoke(session.ID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	return s.refreshTokenRepository.RevokeFamily(session.FamilyID)
+}
+
+// RevokeOtherSessions signs the user out everywhere but in the session of
+// currentSessionID. Tokens issued before sessions existed carry none, in
+// which case every session is revoked.
+func (s UserService) RevokeOtherSessions(userID uint, currentSessionID string) error {
+	var keepFamilyID string
+
+	if currentSessionID != "" {
+		current, err := s.getSession(userID, currentSessionID)
+
+		if err != nil {
+			return err
+		}
+
+		keepFamilyID = current.FamilyID
+	}
+
+	return s.revokeSessions(userID, keepFamilyID)
+}
+
+// CheckSession returns ErrSessionRevoked when access tokens of the session
+// must no longer be accepted, and records that the session is in use.
+func (s UserService) CheckSession(userID uint, sessionID string) error {
+	// tokens issued before sessions existed expire on their own
+	if sessionID == "" {
+		return nil
+	}
+
+	session, err := s.getSession(userID, sessionID)
+
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+
+	now := time.Now()
+
+	if !session.Active(now) {
+		return ErrSessionRevoked
+	}
+
+	if session.LastSeenAt.Before(now.Add(-sessionTouchInterval)) {
+		// best effort, a missed update only makes the session look older
+		_ = s.sessionRepository.Touch(session.ID, now, now.Add(-sessionTouchInterval))
+	}
+
+	return nil
+}
+
+func (s UserService) PurgeExpiredSessions() (int64, error) {
+	return s.sessionRepository.DeleteExpired(time.Now())
+}
+
+// startSession records a sign-in from client, starting a new refresh token
+// family.
+func (s UserService) startSession(u *entity.User, client Client) (*entity.Session, error) {
+	familyID, _, err := generateOpaqueToken()
+
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	return s.createSession(u, familyID, client)
+}
+
+func (s UserService) createSession(u *entity.User, familyID string, client Client) (*entity.Session, error) {
+	now := time.Now()
+
+	session := &entity.Session{
+		UserID:     u.ID,
+		FamilyID:   familyID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.tokenOptions.RefreshTokenTTL),
+	}
+
+	if _, err := s.sessionRepository.Create(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// refreshSession extends the session of a refresh token family by another
+// RefreshTokenTTL. Families started before sessions existed get one for
+// client.
+func (s UserService) refreshSession(u *entity.User, familyID string, client Client) (*entity.Session, error) {
+	session, err := s.sessionRepository.GetByFamily(familyID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.createSession(u, familyID, client)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if !session.Active(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.tokenOptions.RefreshTokenTTL)
+
+	if err := s.sessionRepository.Extend(session.ID, session.LastSeenAt, session.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// revokeSessions signs the user out of every session but the one of
+// keepFamilyID, which may be empty to revoke them all.
+func (s UserService) revokeSessions(userID uint, keepFamilyID string) error {
+	if err := s.sessionRepository.RevokeByUser(userID, keepFamilyID); err != nil {
+		return err
+	}
+
+	return s.refreshTokenRepository.RevokeByUser(userID, keepFamilyID)
+}
+
+// revokeFamily revokes a refresh token family and the session it belongs to.
+func (s UserService) revokeFamily(familyID string) error {
+	if err := s.sessionRepository.RevokeFamily(familyID); err != nil {
+		return err
+	}
+
+	return s.refreshTokenRepository.RevokeFamily(familyID)
+}
+
+func (s UserService) getSession(userID uint, sessionID string) (*entity.Session, error) {
+	id, err := strconv.ParseUint(sessionID, 10, 64)
+
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	session, err := s.sessionRepository.GetByID(uint(id))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// sessionID is the sid claim of the access tokens of session.
+func sessionID(session *entity.Session) string {
+	return strconv.FormatUint(uint64(session.ID), 10)
+}
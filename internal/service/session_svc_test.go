@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"testing"
+	"time"
+)
+
+// session returns the session started by the sign-in that issued
+// refreshToken.
+func (e *testEnv) session(t *testing.T, refreshToken string) *entity.Session {
+	t.Helper()
+
+	rt, err := e.userSvc.refreshTokenRepository.GetByHash(hashOpaqueToken(refreshToken))
+
+	if err != nil {
+		t.Fatalf("GetByHash() error = %v", err)
+	}
+
+	session, err := e.userSvc.sessionRepository.GetByFamily(rt.FamilyID)
+
+	if err != nil {
+		t.Fatalf("GetByFamily() error = %v", err)
+	}
+
+	return session
+}
+
+func TestCheckSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// sessionID returns the sid to check after u signed in to session
+		sessionID func(t *testing.T, e *testEnv, session *entity.Session) string
+		wantErr   error
+	}{
+		{
+			name:      "active session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string { return sessionID(session) },
+		},
+		{
+			name:      "token without session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string { return "" },
+		},
+		{
+			name:      "unknown session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string { return "999" },
+			wantErr:   ErrSessionRevoked,
+		},
+		{
+			name:      "malformed session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string { return "x" },
+			wantErr:   ErrSessionRevoked,
+		},
+		{
+			name: "session of another user",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string {
+				other := e.createUser(t, "other@example.com")
+
+				return sessionID(e.session(t, e.signIn(t, other).RefreshToken))
+			},
+			wantErr: ErrSessionRevoked,
+		},
+		{
+			name: "revoked session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string {
+				if err := e.userSvc.RevokeSession(session.UserID, sessionID(session)); err != nil {
+					t.Fatalf("RevokeSession() error = %v", err)
+				}
+				return sessionID(session)
+			},
+			wantErr: ErrSessionRevoked,
+		},
+		{
+			name: "expired session",
+			sessionID: func(t *testing.T, e *testEnv, session *entity.Session) string {
+				err := e.db.Model(session).Update("expires_at", time.Now().Add(-time.Second)).Error
+
+				if err != nil {
+					t.Fatalf("expiring session: %v", err)
+				}
+				return sessionID(session)
+			},
+			wantErr: ErrSessionRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			session := e.session(t, e.signIn(t, u).RefreshToken)
+
+			if err := e.userSvc.CheckSession(u.ID, tt.sessionID(t, e, session)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckSession() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSessionTouchesStaleSessions(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastSeen  time.Duration
+		wantTouch bool
+	}{
+		{name: "recently seen", lastSeen: sessionTouchInterval / 2},
+		{name: "stale", lastSeen: 2 * sessionTouchInterval, wantTouch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			session := e.session(t, e.signIn(t, u).RefreshToken)
+			lastSeen := time.Now().Add(-tt.lastSeen)
+
+			if err := e.db.Model(session).Update("last_seen_at", lastSeen).Error; err != nil {
+				t.Fatalf("updating last seen: %v", err)
+			}
+
+			if err := e.userSvc.CheckSession(u.ID, sessionID(session)); err != nil {
+				t.Fatalf("CheckSession() error = %v", err)
+			}
+
+			got, err := e.userSvc.sessionRepository.GetByID(session.ID)
+
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+
+			if touched := got.LastSeenAt.After(lastSeen); touched != tt.wantTouch {
+				t.Errorf("LastSeenAt = %v after %v, touched %v, want %v", got.LastSeenAt, lastSeen, touched, tt.wantTouch)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	other := e.createUser(t, "other@example.com")
+
+	tokens := e.signIn(t, u)
+	session := e.session(t, tokens.RefreshToken)
+	otherTokens := e.signIn(t, other)
+	otherSession := e.session(t, otherTokens.RefreshToken)
+
+	if err := e.userSvc.RevokeSession(u.ID, sessionID(otherSession)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() of another user's session error = %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if err := e.userSvc.CheckSession(other.ID, sessionID(otherSession)); err != nil {
+		t.Errorf("CheckSession() of the other user's session error = %v", err)
+	}
+
+	if _, err := e.refresh(otherTokens.RefreshToken); err != nil {
+		t.Errorf("Refresh() of the other user's session error = %v", err)
+	}
+
+	if err := e.userSvc.RevokeSession(u.ID, sessionID(session)); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(session)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() of a revoked session error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() of a revoked session error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if err := e.userSvc.RevokeSession(u.ID, sessionID(session)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() twice error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	other := e.createUser(t, "other@example.com")
+
+	current := e.signIn(t, u)
+	currentSession := e.session(t, current.RefreshToken)
+
+	// refreshing keeps the family and with it the session
+	current, err := e.refresh(current.RefreshToken)
+
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	elsewhere := e.signIn(t, u)
+	elsewhereSession := e.session(t, elsewhere.RefreshToken)
+	otherSession := e.session(t, e.signIn(t, other).RefreshToken)
+
+	if err := e.userSvc.RevokeOtherSessions(u.ID, sessionID(otherSession)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeOtherSessions() keeping another user's session error = %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if err := e.userSvc.RevokeOtherSessions(u.ID, sessionID(currentSession)); err != nil {
+		t.Fatalf("RevokeOtherSessions() error = %v", err)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(currentSession)); err != nil {
+		t.Errorf("CheckSession() of the current session error = %v", err)
+	}
+
+	if _, err := e.refresh(current.RefreshToken); err != nil {
+		t.Errorf("Refresh() of the current session error = %v", err)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(elsewhereSession)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() of another session error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	if _, err := e.refresh(elsewhere.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() of another session error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if err := e.userSvc.CheckSession(other.ID, sessionID(otherSession)); err != nil {
+		t.Errorf("CheckSession() of the other user's session error = %v", err)
+	}
+
+	// tokens without a session revoke every session
+	if err := e.userSvc.RevokeOtherSessions(u.ID, ""); err != nil {
+		t.Fatalf("RevokeOtherSessions() without a session error = %v", err)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(currentSession)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() after revoking every session error = %v, want %v", err, ErrSessionRevoked)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"gorm.io/gorm"
+	"net/url"
+	"time"
+)
+
+var ErrAccountDisabled = errors.New("account is disabled error")
+
+// GetUsers lists users narrowed down by the filters and sort order in query.
+func (s UserService) GetUsers(req pagination.Request, query url.Values) ([]byte, *pagination.Meta, error) {
+	filters, sorts, err := parseUserQuery(query)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page, err := s.userRepository.GetWithFilters(req, sorts, filters...)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, nil, err
+	}
+
+	for _, u := range page.Items {
+		u.Password = ""
+	}
+
+	data, err := json.Marshal(page.Items)
+
+	return data, &page.Meta, err
+}
+
+func (s UserService) GetUser(id string) ([]byte, error) {
+	userID, err := parseUserID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.Password = ""
+
+	return json.Marshal(u)
+}
+
+// Disable keeps the user from signing in and signs it out of every
+// session. Access tokens are rejected from then on, see CheckActive.
+func (s UserService) Disable(id string) ([]byte, error) {
+	now := time.Now()
+
+	return s.setDisabled(id, &now)
+}
+
+func (s UserService) Enable(id string) ([]byte, error) {
+	return s.setDisabled(id, nil)
+}
+
+// DeleteUser moves the user to the trash and signs it out of every session.
+func (s UserService) DeleteUser(id string) error {
+	userID, err := parseUserID(id)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepository.Delete(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return s.revokeSessions(userID, "")
+}
+
+// CheckActive returns ErrAccountDisabled, ErrEmailNotVerified or
+// ErrUserNotFound when access tokens of the user must no longer be
+// accepted.
+func (s UserService) CheckActive(userID uint) error {
+	u, err := s.userRepository.GetStatus(userID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return s.checkAccess(u)
+}
+
+func (s UserService) setDisabled(id string, disabledAt *time.Time) ([]byte, error) {
+	userID, err := parseUserID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepository.SetDisabled(userID, disabledAt); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if disabledAt != nil {
+		if err := s.revokeSessions(userID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetUser(id)
+}
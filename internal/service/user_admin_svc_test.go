@@ -0,0 +1,223 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDisable(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	other := e.createUser(t, "other@example.com")
+
+	tokens := e.signIn(t, u)
+	session := e.session(t, tokens.RefreshToken)
+	otherTokens := e.signIn(t, other)
+
+	data, err := e.userSvc.Disable(strconv.Itoa(int(u.ID)))
+
+	if err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+
+	var disabled entity.User
+
+	if err := json.Unmarshal(data, &disabled); err != nil || !disabled.Disabled() {
+		t.Errorf("Disable() = %s, want a disabled user", data)
+	}
+
+	if err := e.userSvc.CheckActive(u.ID); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("CheckActive() error = %v, want %v", err, ErrAccountDisabled)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(session)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	signIn := mustMarshal(t, dto.AuthUserRequestDTO{Username: u.Name, Password: testPassword})
+
+	if _, err := e.userSvc.Authenticate(signIn, Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("Authenticate() error = %v, want %v", err, ErrAccountDisabled)
+	}
+
+	// other users are left alone
+	if err := e.userSvc.CheckActive(other.ID); err != nil {
+		t.Errorf("CheckActive() of another user error = %v", err)
+	}
+
+	if _, err := e.refresh(otherTokens.RefreshToken); err != nil {
+		t.Errorf("Refresh() of another user error = %v", err)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	id := strconv.Itoa(int(u.ID))
+
+	if _, err := e.userSvc.Disable(id); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+
+	data, err := e.userSvc.Enable(id)
+
+	if err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	var enabled entity.User
+
+	if err := json.Unmarshal(data, &enabled); err != nil || enabled.Disabled() {
+		t.Errorf("Enable() = %s, want an enabled user", data)
+	}
+
+	if err := e.userSvc.CheckActive(u.ID); err != nil {
+		t.Errorf("CheckActive() error = %v", err)
+	}
+
+	// sessions revoked while disabled stay revoked, signing in works again
+	e.signIn(t, u)
+}
+
+func TestSetDisabledRejects(t *testing.T) {
+	e := newTestEnv(t)
+
+	tests := []struct {
+		id      string
+		wantErr error
+	}{
+		{id: "999", wantErr: ErrUserNotFound},
+		{id: "0", wantErr: ErrInvalidUserID},
+		{id: "x", wantErr: ErrInvalidUserID},
+	}
+
+	for _, tt := range tests {
+		if _, err := e.userSvc.Disable(tt.id); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Disable(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+		}
+
+		if _, err := e.userSvc.Enable(tt.id); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Enable(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+	id := strconv.Itoa(int(u.ID))
+
+	tokens := e.signIn(t, u)
+	session := e.session(t, tokens.RefreshToken)
+
+	if err := e.userSvc.DeleteUser(id); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if err := e.userSvc.CheckActive(u.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("CheckActive() error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	if err := e.userSvc.CheckSession(u.ID, sessionID(session)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := e.userSvc.GetUser(id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	if err := e.userSvc.DeleteUser(id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeleteUser() twice error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	if err := e.userSvc.DeleteUser("x"); !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("DeleteUser() of an invalid id error = %v, want %v", err, ErrInvalidUserID)
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.createUser(t, "alice@example.com")
+	bob := e.createUser(t, "bob@example.org")
+	carol := e.createUser(t, "carol@example.com")
+
+	if _, err := e.userSvc.Disable(strconv.Itoa(int(bob.ID))); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+
+	if err := e.db.Model(carol).Update("email_verified_at", time.Now()).Error; err != nil {
+		t.Fatalf("verifying user: %v", err)
+	}
+
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr error
+	}{
+		{query: "", want: []string{"alice@example.com", "bob@example.org", "carol@example.com"}},
+		{query: "name_contains=EXAMPLE.COM&sort=-name", want: []string{"carol@example.com", "alice@example.com"}},
+		{query: "disabled=true", want: []string{"bob@example.org"}},
+		{query: "disabled=false&verified=false", want: []string{"alice@example.com"}},
+		{query: "verified=true", want: []string{"carol@example.com"}},
+		{query: "created_after=2000-01-01&sort=-id", want: []string{"carol@example.com", "bob@example.org", "alice@example.com"}},
+		{query: "disabled=yes", wantErr: ErrValidation},
+		{query: "created_before=soon", wantErr: ErrValidation},
+		{query: "sort=password", wantErr: ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			data, _, err := e.userSvc.GetUsers(pagination.Request{Limit: 10}, query)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUsers() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			var users []entity.User
+
+			if err := json.Unmarshal(data, &users); err != nil {
+				t.Fatalf("GetUsers() = %s, want users", data)
+			}
+
+			names := make([]string, len(users))
+
+			for i, u := range users {
+				names[i] = u.Name
+
+				if u.Password != "" {
+					t.Errorf("GetUsers() returned the password hash of %s", u.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("GetUsers() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
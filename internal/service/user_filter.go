@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"net/url"
+	"strconv"
+)
+
+// userSortColumns whitelists the fields accepted by the sort parameter.
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// userFilterParsers maps the supported query parameters to typed filters.
+var userFilterParsers = map[string]func(string) (repository.Filter, error){
+	"name_contains": func(v string) (repository.Filter, error) {
+		return repository.NameContains(v), nil
+	},
+	"disabled": func(v string) (repository.Filter, error) {
+		disabled, err := strconv.ParseBool(v)
+		return repository.Disabled(disabled), err
+	},
+	"verified": func(v string) (repository.Filter, error) {
+		verified, err := strconv.ParseBool(v)
+		return repository.EmailVerified(verified), err
+	},
+	"created_after": func(v string) (repository.Filter, error) {
+		t, err := parseFilterTime(v)
+		return repository.CreatedAfter(t), err
+	},
+	"created_before": func(v string) (repository.Filter, error) {
+		t, err := parseFilterTime(v)
+		return repository.CreatedBefore(t), err
+	},
+}
+
+// parseUserQuery turns list query parameters such as
+// name_contains=example.com&disabled=false&sort=-created_at into filters
+// and sorts. Parameters that are not filters are ignored.
+func parseUserQuery(query url.Values) ([]repository.Filter, []repository.Sort, error) {
+	var filters []repository.Filter
+
+	for param, parse := range userFilterParsers {
+		for _, v := range query[param] {
+			f, err := parse(v)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %w: %s=%s", ErrValidation, ErrInvalidFilter, param, v)
+			}
+
+			filters = append(filters, f)
+		}
+	}
+
+	sorts, err := parseSort(query.Get("sort"), userSortColumns)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filters, sorts, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// filterStrings describes filters in a stable order, as they are parsed
+// from a map.
+func filterStrings(filters []repository.Filter) []string {
+	s := make([]string, len(filters))
+
+	for i, f := range filters {
+		s[i] = fmt.Sprintf("%v %v", f.Query(), f.Args())
+	}
+
+	slices.Sort(s)
+
+	return s
+}
+
+func TestParseUserQuery(t *testing.T) {
+	createdAfter := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	createdBefore := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFilters []repository.Filter
+		wantSorts   []repository.Sort
+		wantErr     bool
+	}{
+		{name: "no parameters"},
+		{name: "other parameters are ignored", query: "limit=10&password=x"},
+		{
+			name:  "filters",
+			query: "name_contains=Example&disabled=false&verified=1&created_after=2024-05-01&created_before=2024-06-01T12:30:00Z",
+			wantFilters: []repository.Filter{
+				repository.NameContains("Example"),
+				repository.Disabled(false),
+				repository.EmailVerified(true),
+				repository.CreatedAfter(createdAfter),
+				repository.CreatedBefore(createdBefore),
+			},
+		},
+		{
+			name:        "repeated parameter",
+			query:       "name_contains=a&name_contains=b",
+			wantFilters: []repository.Filter{repository.NameContains("a"), repository.NameContains("b")},
+		},
+		{
+			name:      "sort",
+			query:     "sort=-created_at,name",
+			wantSorts: []repository.Sort{{Column: "created_at", Desc: true}, {Column: "name"}},
+		},
+		{name: "invalid bool", query: "disabled=maybe", wantErr: true},
+		{name: "invalid verified", query: "verified=", wantErr: true},
+		{name: "invalid time", query: "created_after=yesterday", wantErr: true},
+		{name: "invalid date", query: "created_before=2024-13-01", wantErr: true},
+		{name: "one invalid value", query: "disabled=true&disabled=x", wantErr: true},
+		{name: "sort by a column that is not listed", query: "sort=password", wantErr: true},
+		{name: "empty sort field", query: "sort=name,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			filters, sorts, err := parseUserQuery(query)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) || !errors.Is(err, ErrInvalidFilter) {
+					t.Errorf("parseUserQuery() error = %v, want %v and %v", err, ErrValidation, ErrInvalidFilter)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseUserQuery() error = %v", err)
+			}
+
+			if got, want := filterStrings(filters), filterStrings(tt.wantFilters); !reflect.DeepEqual(got, want) {
+				t.Errorf("parseUserQuery() filters = %v, want %v", got, want)
+			}
+
+			if !reflect.DeepEqual(sorts, tt.wantSorts) {
+				t.Errorf("parseUserQuery() sorts = %+v, want %+v", sorts, tt.wantSorts)
+			}
+		})
+	}
+}
@@ -7,10 +7,16 @@ import (
 	"github.com/SomchaiSPB/user-auth/internal/dto"
 	"github.com/SomchaiSPB/user-auth/internal/entity"
 	"github.com/SomchaiSPB/user-auth/internal/hash"
+	"github.com/SomchaiSPB/user-auth/internal/pagination"
+	"github.com/SomchaiSPB/user-auth/internal/password"
 	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,22 +25,82 @@ const tokenExpTime = 15 * time.Minute
 var validate *validator.Validate
 
 var (
-	ErrUserNameExists   = errors.New("user already exists error")
-	ErrCreateUser       = errors.New("user create error")
-	ErrWrongCredentials = errors.New("wrong credentials error")
-	ErrGenerateToken    = errors.New("generate token error")
-	ErrValidation       = errors.New("validation error")
+	ErrUserNameExists      = errors.New("user already exists error")
+	ErrCreateUser          = errors.New("user create error")
+	ErrWrongCredentials    = errors.New("wrong credentials error")
+	ErrGenerateToken       = errors.New("generate token error")
+	ErrValidation          = errors.New("validation error")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token error")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected error")
+	ErrUserNotFound        = errors.New("user not found error")
+	ErrInvalidUserID       = errors.New("invalid user id error")
+	ErrPasswordChanged     = errors.New("password was changed concurrently error")
+	ErrEmailNotVerified    = errors.New("email address is not verified error")
 )
 
+// TokenOptions configures the tokens issued by UserService.
+type TokenOptions struct {
+	Issuer          string
+	Audience        string
+	RefreshTokenTTL time.Duration
+	MFAChallengeTTL time.Duration
+	// RequireVerifiedEmail rejects sign-ins of users who did not verify
+	// their email address yet.
+	RequireVerifiedEmail bool
+}
+
 type UserService struct {
-	userRepository repository.UserRepository
+	userRepository         repository.UserRepository
+	refreshTokenRepository repository.RefreshTokenRepository
+	roleRepository         repository.RoleRepository
+	mfaChallengeRepository repository.MFAChallengeRepository
+	recoveryCodeRepository repository.RecoveryCodeRepository
+	sessionRepository      repository.SessionRepository
+	loginGuard             *LoginGuard
+	passwordPolicy         password.Policy
+	hasher                 hash.Hasher
+	tokenOptions           TokenOptions
 }
 
-func NewUserSvc(ur repository.UserRepository) *UserService {
-	return &UserService{userRepository: ur}
+func NewUserSvc(
+	ur repository.UserRepository,
+	rtr repository.RefreshTokenRepository,
+	rr repository.RoleRepository,
+	mcr repository.MFAChallengeRepository,
+	rcr repository.RecoveryCodeRepository,
+	sr repository.SessionRepository,
+	lg *LoginGuard,
+	pp password.Policy,
+	h hash.Hasher,
+	opts TokenOptions,
+) *UserService {
+	return &UserService{
+		userRepository:         ur,
+		refreshTokenRepository: rtr,
+		roleRepository:         rr,
+		mfaChallengeRepository: mcr,
+		recoveryCodeRepository: rcr,
+		sessionRepository:      sr,
+		loginGuard:             lg,
+		passwordPolicy:         pp,
+		hasher:                 h,
+		tokenOptions:           opts,
+	}
 }
 
 func (s UserService) Create(data []byte) ([]byte, error) {
+	createdUser, err := s.createUser(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	createdUser.Password = ""
+
+	return json.Marshal(createdUser)
+}
+
+func (s UserService) createUser(data []byte) (*entity.User, error) {
 	var userDto dto.CreateUserDTO
 
 	if err := json.Unmarshal(data, &userDto); err != nil {
@@ -47,33 +113,49 @@ func (s UserService) Create(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
 	}
 
+	if err := s.passwordPolicy.Check(userDto.Password, userDto.Username); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
 	if exists := s.userRepository.Exists(userDto.Username); exists {
 		return nil, fmt.Errorf("%s: %w", userDto.Username, ErrUserNameExists)
 	}
 
-	hashedPass, err := hash.NewHasher().HashPassword(userDto.Password)
+	hashedPass, err := s.hasher.HashPassword(userDto.Password)
 
 	if err != nil {
 		return nil, fmt.Errorf("password hash error: %v", err)
 	}
 
+	defaultRole, err := s.roleRepository.GetByName(entity.RoleUser)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCreateUser, err)
+	}
+
 	u := &entity.User{
 		Name:     userDto.Username,
 		Password: hashedPass,
+		Roles:    []*entity.Role{defaultRole},
 	}
 
 	createdUser, err := s.userRepository.Create(u)
 
 	if err != nil {
+		// soft deleted users keep their name until purged
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, fmt.Errorf("%s: %w", userDto.Username, ErrUserNameExists)
+		}
 		return nil, fmt.Errorf("%w: %w", ErrCreateUser, err)
 	}
 
-	createdUser.Password = ""
-
-	return json.Marshal(createdUser)
+	return createdUser, nil
 }
 
-func (s UserService) Authenticate(data []byte, jwtSecret []byte) ([]byte, error) {
+// Authenticate checks the credentials and returns a token pair, or an MFA
+// challenge to complete with VerifyMFA when the user enabled a second factor.
+// Repeated failures from the account or IP of client are throttled.
+func (s UserService) Authenticate(data []byte, client Client, signer signing.Signer) ([]byte, error) {
 	var authDto dto.AuthUserRequestDTO
 
 	if err := json.Unmarshal(data, &authDto); err != nil {
@@ -86,6 +168,10 @@ func (s UserService) Authenticate(data []byte, jwtSecret []byte) ([]byte, error)
 		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
 	}
 
+	if err := s.loginGuard.Attempt(authDto.Username, client.IP); err != nil {
+		return nil, err
+	}
+
 	u, err := s.userRepository.GetByName(authDto.Username)
 
 	if err != nil {
@@ -95,42 +181,358 @@ func (s UserService) Authenticate(data []byte, jwtSecret []byte) ([]byte, error)
 		return nil, err
 	}
 
-	if ok := hash.NewHasher().CheckPasswordHash(authDto.Password, u.Password); !ok {
+	if ok := s.hasher.CheckPasswordHash(authDto.Password, u.Password); !ok {
 		return nil, ErrWrongCredentials
 	}
 
-	exp := time.Now().Add(tokenExpTime).Unix()
+	if s.hasher.NeedsRehash(u.Password) {
+		s.rehashPassword(u, authDto.Password)
+	}
 
-	tkn, err := generateJWT(u.Name, jwtSecret, exp)
+	if err := s.loginGuard.RecordSuccess(authDto.Username, client.IP); err != nil {
+		return nil, err
+	}
+
+	return s.completeSignIn(u, client, signer, false)
+}
+
+// Unlock lifts a sign-in lockout of the user.
+func (s UserService) Unlock(id string) error {
+	userID, err := parseUserID(id)
 
 	if err != nil {
-		return nil, ErrGenerateToken
+		return err
+	}
+
+	u, err := s.getUser(userID)
+
+	if err != nil {
+		return err
 	}
 
-	response := dto.AuthUserResponseDTO{
-		Token:     tkn,
-		ExpiresAt: exp,
+	return s.loginGuard.Unlock(u.Name)
+}
+
+func (s UserService) PurgeExpiredLoginFailures() (int64, error) {
+	return s.loginGuard.PurgeExpired()
+}
+
+// setPassword replaces the password of u, signs it out of every session and
+// lifts a sign-in lockout.
+func (s UserService) setPassword(u *entity.User, password string) error {
+	hashedPass, err := s.hasher.HashPassword(password)
+
+	if err != nil {
+		return fmt.Errorf("password hash error: %v", err)
+	}
+
+	updated, err := s.userRepository.ReplacePassword(u.ID, u.Password, hashedPass)
+
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return ErrPasswordChanged
+	}
+
+	u.Password = hashedPass
+
+	if err := s.revokeSessions(u.ID, ""); err != nil {
+		return err
+	}
+
+	return s.loginGuard.Unlock(u.Name)
+}
+
+// rehashPassword upgrades the hash of a password checked at sign-in to the
+// current algorithm. It is best effort: on failure the old hash keeps
+// working and the upgrade is tried again on the next sign-in. The hash is
+// only replaced if the password did not change in between.
+func (s UserService) rehashPassword(u *entity.User, password string) {
+	hashedPass, err := s.hasher.HashPassword(password)
+
+	if err != nil {
+		return
+	}
+
+	if updated, err := s.userRepository.ReplacePassword(u.ID, u.Password, hashedPass); err == nil && updated {
+		u.Password = hashedPass
+	}
+}
+
+// checkAccess returns ErrAccountDisabled or ErrEmailNotVerified when u may
+// not sign in or use its tokens.
+func (s UserService) checkAccess(u *entity.User) error {
+	if u.Disabled() {
+		return ErrAccountDisabled
+	}
+
+	if s.tokenOptions.RequireVerifiedEmail && !u.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// completeSignIn starts a new session of u on client, unless a second factor
+// is still missing, in which case an MFA challenge is returned instead.
+func (s UserService) completeSignIn(u *entity.User, client Client, signer signing.Signer, multiFactor bool) ([]byte, error) {
+	if err := s.checkAccess(u); err != nil {
+		return nil, err
+	}
+
+	if u.MFAEnabled() && !multiFactor {
+		challenge, err := s.startMFAChallenge(u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(challenge)
+	}
+
+	session, err := s.startSession(u, client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.issueTokens(u, session, signer)
+
+	if err != nil {
+		return nil, err
 	}
 
 	return json.Marshal(response)
 }
 
-func generateJWT(username string, jwtSecret []byte, exp int64) (string, error) {
-	claims := jwt.MapClaims{
-		"username": username,
-		"exp":      exp,
+// Refresh exchanges a refresh token for a new token pair. Every refresh
+// token is single-use: presenting one that was already rotated revokes
+// the whole family it belongs to, along with its session. Tokens issued
+// before sessions existed get one for client.
+func (s UserService) Refresh(data []byte, client Client, signer signing.Signer) ([]byte, error) {
+	var refreshDto dto.RefreshTokenRequestDTO
+
+	if err := json.Unmarshal(data, &refreshDto); err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	err := validate.Struct(refreshDto)
 
-	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
 	}
 
-	return tokenString, nil
+	rt, err := s.refreshTokenRepository.GetByHash(hashOpaqueToken(refreshDto.RefreshToken))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if rt.RevokedAt != nil || time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	consumed, err := s.refreshTokenRepository.Consume(rt.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !consumed {
+		if err := s.revokeFamily(rt.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	u, err := s.userRepository.GetByID(rt.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	// the email address may have changed since the sign-in
+	if err := s.checkAccess(u); err != nil {
+		return nil, err
+	}
+
+	session, err := s.refreshSession(u, rt.FamilyID, client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.issueTokens(u, session, signer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+// RevokeRefreshToken revokes the whole family of the given refresh token
+// and ends its session. Tokens of other users than userID are treated as
+// unknown.
+func (s UserService) RevokeRefreshToken(userID uint, data []byte) error {
+	var refreshDto dto.RefreshTokenRequestDTO
+
+	if err := json.Unmarshal(data, &refreshDto); err != nil {
+		return err
+	}
+
+	err := validate.Struct(refreshDto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	rt, err := s.refreshTokenRepository.GetByHash(hashOpaqueToken(refreshDto.RefreshToken))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	if rt.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+
+	return s.revokeFamily(rt.FamilyID)
+}
+
+func (s UserService) GetDeletedUsers(req pagination.Request) ([]byte, *pagination.Meta, error) {
+	page, err := s.userRepository.GetDeleted(req)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, nil, err
+	}
+
+	for _, u := range page.Items {
+		u.Password = ""
+	}
+
+	data, err := json.Marshal(page.Items)
+
+	return data, &page.Meta, err
+}
+
+func (s UserService) Restore(id string) ([]byte, error) {
+	userID, err := parseUserID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.userRepository.Restore(userID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	u.Password = ""
+
+	return json.Marshal(u)
+}
+
+func (s UserService) PurgeDeleted(before time.Time) (int64, error) {
+	return s.userRepository.PurgeDeleted(before)
+}
+
+func (s UserService) issueTokens(u *entity.User, session *entity.Session, signer signing.Signer) (*dto.AuthUserResponseDTO, error) {
+	now := time.Now()
+	exp := now.Add(tokenExpTime)
+
+	jti, err := generateTokenID()
+
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	claims := &signing.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(u.ID), 10),
+			Issuer:    s.tokenOptions.Issuer,
+			Audience:  jwt.ClaimStrings{s.tokenOptions.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+		Username:    u.Name,
+		Roles:       u.RoleNames(),
+		Permissions: u.PermissionNames(),
+		SessionID:   sessionID(session),
+	}
+
+	tkn, err := signer.Sign(claims)
+
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	refreshTkn, refreshHash, err := generateOpaqueToken()
+
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	rt := &entity.RefreshToken{
+		UserID:    u.ID,
+		FamilyID:  session.FamilyID,
+		TokenHash: refreshHash,
+		ExpiresAt: now.Add(s.tokenOptions.RefreshTokenTTL),
+	}
+
+	if _, err := s.refreshTokenRepository.Create(rt); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGenerateToken, err)
+	}
+
+	return &dto.AuthUserResponseDTO{
+		Token:            tkn,
+		ExpiresAt:        exp.Unix(),
+		RefreshToken:     refreshTkn,
+		RefreshExpiresAt: rt.ExpiresAt.Unix(),
+	}, nil
+}
+
+func parseUserID(id string) (uint, error) {
+	userID, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil || userID == 0 {
+		return 0, fmt.Errorf("%s: %w", id, ErrInvalidUserID)
+	}
+
+	return uint(userID), nil
 }
 
 func init() {
 	validate = validator.New(validator.WithRequiredStructEnabled())
+
+	// report fields by the names clients send
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		if name == "" || name == "-" {
+			return f.Name
+		}
+
+		return name
+	})
 }
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signIn starts a session of u that must succeed.
+func (e *testEnv) signIn(t *testing.T, u *entity.User) dto.AuthUserResponseDTO {
+	t.Helper()
+
+	response, err := e.userSvc.completeSignIn(u, Client{IP: "192.0.2.1"}, e.signer, false)
+
+	if err != nil {
+		t.Fatalf("completeSignIn() error = %v", err)
+	}
+
+	var tokens dto.AuthUserResponseDTO
+
+	if err := json.Unmarshal(response, &tokens); err != nil || tokens.RefreshToken == "" {
+		t.Fatalf("response %s is not a token pair", response)
+	}
+
+	return tokens
+}
+
+func (e *testEnv) refresh(refreshToken string) (dto.AuthUserResponseDTO, error) {
+	var tokens dto.AuthUserResponseDTO
+
+	response, err := e.userSvc.Refresh([]byte(`{"refreshToken":"`+refreshToken+`"}`), Client{IP: "192.0.2.1"}, e.signer)
+
+	if err != nil {
+		return tokens, err
+	}
+
+	return tokens, json.Unmarshal(response, &tokens)
+}
+
+func TestRequireVerifiedEmailAfterSignIn(t *testing.T) {
+	tests := []struct {
+		name string
+		// change runs after the sign-in
+		change  func(t *testing.T, e *testEnv, u *entity.User)
+		wantErr error
+	}{
+		{
+			name:   "still verified",
+			change: func(t *testing.T, e *testEnv, u *entity.User) {},
+		},
+		{
+			name: "email address changed",
+			change: func(t *testing.T, e *testEnv, u *entity.User) {
+				if err := e.db.Model(u).Update("email_verified_at", nil).Error; err != nil {
+					t.Fatalf("unverifying user: %v", err)
+				}
+			},
+			wantErr: ErrEmailNotVerified,
+		},
+		{
+			name: "account disabled",
+			change: func(t *testing.T, e *testEnv, u *entity.User) {
+				if err := e.userSvc.userRepository.SetDisabled(u.ID, ptr(time.Now())); err != nil {
+					t.Fatalf("disabling user: %v", err)
+				}
+			},
+			wantErr: ErrAccountDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.userSvc.tokenOptions.RequireVerifiedEmail = true
+			u := e.createUser(t, "user@example.com")
+
+			if err := e.userSvc.userRepository.MarkEmailVerified(u.ID, time.Now()); err != nil {
+				t.Fatalf("MarkEmailVerified() error = %v", err)
+			}
+
+			u.EmailVerifiedAt = ptr(time.Now())
+			tokens := e.signIn(t, u)
+
+			tt.change(t, e, u)
+
+			if err := e.userSvc.CheckActive(u.ID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckActive() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := e.refresh(tokens.RefreshToken); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Refresh() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRefreshRotation(t *testing.T) {
+	// each step refreshes with the token issued by step use, where 0 is the
+	// token of the sign-in and step i issues token i+1 when it succeeds
+	type step struct {
+		use     int
+		wantErr error
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "rotated tokens chain",
+			steps: []step{{use: 0}, {use: 1}, {use: 2}},
+		},
+		{
+			name:  "reusing a rotated token",
+			steps: []step{{use: 0}, {use: 0, wantErr: ErrRefreshTokenReused}},
+		},
+		{
+			name:  "reuse revokes the newest token of the family",
+			steps: []step{{use: 0}, {use: 1}, {use: 1, wantErr: ErrRefreshTokenReused}, {use: 2, wantErr: ErrInvalidRefreshToken}},
+		},
+		{
+			name:  "reuse after the thief refreshed",
+			steps: []step{{use: 0}, {use: 1}, {use: 0, wantErr: ErrRefreshTokenReused}, {use: 2, wantErr: ErrInvalidRefreshToken}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			tokens := []string{e.signIn(t, u).RefreshToken}
+
+			for i, s := range tt.steps {
+				issued, err := e.refresh(tokens[s.use])
+
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Refresh() error = %v, want %v", i, err, s.wantErr)
+				}
+
+				if err == nil {
+					tokens = append(tokens, issued.RefreshToken)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshReuseKeepsOtherSignIns(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+
+	stolen := e.signIn(t, u).RefreshToken
+	other := e.signIn(t, u).RefreshToken
+
+	if _, err := e.refresh(stolen); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err := e.refresh(stolen); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused Refresh() error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, err := e.refresh(other); err != nil {
+		t.Errorf("Refresh() of another sign-in error = %v", err)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the refresh token to use after a sign-in with signedIn
+		token   func(t *testing.T, e *testEnv, signedIn string) string
+		wantErr error
+	}{
+		{
+			name:    "unknown token",
+			token:   func(t *testing.T, e *testEnv, signedIn string) string { return signedIn + "x" },
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "missing token",
+			token:   func(t *testing.T, e *testEnv, signedIn string) string { return "" },
+			wantErr: ErrValidation,
+		},
+		{
+			name: "expired token",
+			token: func(t *testing.T, e *testEnv, signedIn string) string {
+				err := e.db.Model(&entity.RefreshToken{}).Where("1 = 1").
+					Update("expires_at", time.Now().Add(-time.Second)).Error
+
+				if err != nil {
+					t.Fatalf("expiring token: %v", err)
+				}
+				return signedIn
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "signed out",
+			token: func(t *testing.T, e *testEnv, signedIn string) string {
+				u, err := e.userSvc.userRepository.GetByName("user@example.com")
+
+				if err != nil {
+					t.Fatalf("GetByName() error = %v", err)
+				}
+
+				if err := e.userSvc.RevokeRefreshToken(u.ID, []byte(`{"refreshToken":"`+signedIn+`"}`)); err != nil {
+					t.Fatalf("RevokeRefreshToken() error = %v", err)
+				}
+				return signedIn
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			u := e.createUser(t, "user@example.com")
+			token := tt.token(t, e, e.signIn(t, u).RefreshToken)
+
+			if _, err := e.refresh(token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Refresh() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevokeRefreshTokenOfAnotherUser(t *testing.T) {
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner@example.com")
+	other := e.createUser(t, "other@example.com")
+
+	token := e.signIn(t, owner).RefreshToken
+	body := []byte(`{"refreshToken":"` + token + `"}`)
+
+	if err := e.userSvc.RevokeRefreshToken(other.ID, body); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RevokeRefreshToken() by another user error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := e.refresh(token); err != nil {
+		t.Errorf("Refresh() after a rejected revocation error = %v", err)
+	}
+}
+
+func TestAuthenticateRehashesPassword(t *testing.T) {
+	e := newTestEnv(t)
+	u := e.createUser(t, "user@example.com")
+
+	// the stored bcrypt hash predates the switch to argon2id
+	e.userSvc.hasher = hash.NewArgon2Hasher(hash.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	signIn := mustMarshal(t, dto.AuthUserRequestDTO{Username: u.Name, Password: testPassword})
+
+	if _, err := e.userSvc.Authenticate(signIn, Client{}, e.signer); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	rehashed, err := e.userSvc.userRepository.GetByID(u.ID)
+
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+
+	if !strings.HasPrefix(rehashed.Password, "$argon2id$") || e.userSvc.hasher.NeedsRehash(rehashed.Password) {
+		t.Errorf("stored hash = %s, want an argon2id hash with the current parameters", rehashed.Password)
+	}
+
+	if _, err := e.userSvc.Authenticate(signIn, Client{}, e.signer); err != nil {
+		t.Errorf("Authenticate() with the rehashed password error = %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/entity"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/signing"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWebAuthnRegistration  = errors.New("webauthn registration error")
+	ErrWebAuthnLogin         = errors.New("webauthn login error")
+	ErrWebAuthnChallenge     = errors.New("webauthn challenge is unknown or expired error")
+	ErrCredentialExists      = errors.New("webauthn credential is already registered error")
+	ErrCredentialNotFound    = errors.New("webauthn credential not found error")
+	ErrInvalidCredentialID   = errors.New("invalid webauthn credential id error")
+	ErrCredentialUserInvalid = errors.New("webauthn credential does not belong to the user error")
+)
+
+// WebAuthnService runs the passkey registration and login ceremonies.
+// Signing in with a user verifying passkey satisfies two-factor
+// authentication, otherwise an MFA challenge follows as for passwords.
+type WebAuthnService struct {
+	userSvc              *UserService
+	userRepository       repository.UserRepository
+	credentialRepository repository.WebAuthnCredentialRepository
+	challengeRepository  repository.WebAuthnChallengeRepository
+	relyingParty         webauthn.RelyingParty
+}
+
+func NewWebAuthnSvc(
+	us *UserService,
+	ur repository.UserRepository,
+	cr repository.WebAuthnCredentialRepository,
+	chr repository.WebAuthnChallengeRepository,
+	rp webauthn.RelyingParty,
+) *WebAuthnService {
+	return &WebAuthnService{
+		userSvc:              us,
+		userRepository:       ur,
+		credentialRepository: cr,
+		challengeRepository:  chr,
+		relyingParty:         rp,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create().
+func (s WebAuthnService) BeginRegistration(userID uint) ([]byte, error) {
+	u, err := s.userSvc.getUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepository.GetByUser(u.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.startCeremony(u.ID, entity.WebAuthnCeremonyRegistration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user := webauthn.UserEntity{
+		ID:          webauthn.EncodeID(userHandle(u.ID)),
+		Name:        u.Name,
+		DisplayName: u.Name,
+	}
+
+	return json.Marshal(s.relyingParty.CreationOptions(challenge, user, descriptors(credentials)))
+}
+
+// FinishRegistration verifies the created credential and stores it.
+func (s WebAuthnService) FinishRegistration(userID uint, data []byte) ([]byte, error) {
+	var registrationDto dto.WebAuthnRegistrationDTO
+
+	if err := json.Unmarshal(data, &registrationDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(registrationDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	challenge, err := s.takeCeremony(registrationDto.Credential.Challenge, entity.WebAuthnCeremonyRegistration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge.UserID != userID {
+		return nil, ErrWebAuthnChallenge
+	}
+
+	credential, err := s.relyingParty.VerifyRegistration(registrationDto.Credential, challenge.Challenge)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnRegistration, err)
+	}
+
+	name := registrationDto.Name
+
+	if name == "" {
+		name = "Passkey " + time.Now().Format(time.DateOnly)
+	}
+
+	created, err := s.credentialRepository.Create(&entity.WebAuthnCredential{
+		UserID:         userID,
+		Name:           name,
+		CredentialID:   webauthn.EncodeID(credential.ID),
+		PublicKey:      credential.PublicKey,
+		Algorithm:      credential.Algorithm,
+		SignCount:      credential.SignCount,
+		Transports:     strings.Join(credential.Transports, ","),
+		BackupEligible: credential.BackupEligible,
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCredentialExists
+		}
+		return nil, err
+	}
+
+	return json.Marshal(created)
+}
+
+// BeginLogin returns the options for navigator.credentials.get(). Given a
+// username, they list the passkeys of the user, as security keys that do
+// not store discoverable credentials need the list. This reveals whether
+// the user has passkeys; unknown users and users without passkeys get the
+// same options as a login without username.
+func (s WebAuthnService) BeginLogin(data []byte) ([]byte, error) {
+	var loginDto dto.WebAuthnLoginBeginDTO
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &loginDto); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+
+	var userID uint
+	var allow []webauthn.CredentialDescriptor
+
+	if loginDto.Username != "" {
+		u, err := s.userRepository.GetByName(loginDto.Username)
+
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if err == nil {
+			credentials, err := s.credentialRepository.GetByUser(u.ID)
+
+			if err != nil {
+				return nil, err
+			}
+
+			userID = u.ID
+			allow = descriptors(credentials)
+		}
+	}
+
+	challenge, err := s.startCeremony(userID, entity.WebAuthnCeremonyLogin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(s.relyingParty.RequestOptions(challenge, allow))
+}
+
+// FinishLogin verifies the assertion and signs the credential owner in.
+// The lockout and backoff of Authenticate apply, and an assertion that
+// does not verify counts as a failed sign-in of the owner.
+func (s WebAuthnService) FinishLogin(data []byte, client Client, signer signing.Signer) ([]byte, error) {
+	var loginDto dto.WebAuthnLoginDTO
+
+	if err := json.Unmarshal(data, &loginDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if err := validate.Struct(loginDto); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	challenge, err := s.takeCeremony(loginDto.Credential.Challenge, entity.WebAuthnCeremonyLogin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawID, err := webauthn.DecodeID(loginDto.Credential.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	credential, err := s.credentialRepository.GetByCredentialID(webauthn.EncodeID(rawID))
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, ErrCredentialNotFound)
+		}
+		return nil, err
+	}
+
+	if challenge.UserID != 0 && challenge.UserID != credential.UserID {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, ErrCredentialUserInvalid)
+	}
+
+	if handle := loginDto.Credential.Response.UserHandle; handle != "" && handle != webauthn.EncodeID(userHandle(credential.UserID)) {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, ErrCredentialUserInvalid)
+	}
+
+	u, err := s.userRepository.GetByID(credential.UserID)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, ErrCredentialNotFound)
+		}
+		return nil, err
+	}
+
+	if err := s.userSvc.loginGuard.Attempt(u.Name, client.IP); err != nil {
+		return nil, err
+	}
+
+	assertion, err := s.relyingParty.VerifyAssertion(loginDto.Credential, challenge.Challenge, credential.PublicKey, credential.SignCount)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, err)
+	}
+
+	updated, err := s.credentialRepository.UpdateSignCount(credential.ID, credential.SignCount, assertion.SignCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnLogin, webauthn.ErrSignCount)
+	}
+
+	if err := s.userSvc.loginGuard.RecordSuccess(u.Name, client.IP); err != nil {
+		return nil, err
+	}
+
+	return s.userSvc.completeSignIn(u, client, signer, assertion.UserVerified)
+}
+
+func (s WebAuthnService) GetCredentials(userID uint) ([]byte, error) {
+	credentials, err := s.credentialRepository.GetByUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(credentials)
+}
+
+func (s WebAuthnService) DeleteCredential(userID uint, id string) error {
+	credentialID, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil || credentialID == 0 {
+		return fmt.Errorf("%s: %w", id, ErrInvalidCredentialID)
+	}
+
+	if err := s.credentialRepository.Delete(userID, uint(credentialID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCredentialNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s WebAuthnService) PurgeExpiredChallenges() (int64, error) {
+	return s.challengeRepository.DeleteExpired(time.Now())
+}
+
+func (s WebAuthnService) startCeremony(userID uint, ceremony string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.challengeRepository.Create(&entity.WebAuthnChallenge{
+		Challenge: challenge,
+		UserID:    userID,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(s.relyingParty.Timeout),
+	})
+
+	return challenge, err
+}
+
+// takeCeremony consumes the pending ceremony the credential answers.
+func (s WebAuthnService) takeCeremony(challengeOf func() (string, error), ceremony string) (*entity.WebAuthnChallenge, error) {
+	challenge, err := challengeOf()
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	c, err := s.challengeRepository.Take(challenge, ceremony)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnChallenge
+		}
+		return nil, err
+	}
+
+	if time.Now().After(c.ExpiresAt) {
+		return nil, ErrWebAuthnChallenge
+	}
+
+	return c, nil
+}
+
+// userHandle identifies the account a discoverable credential belongs to.
+func userHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+func descriptors(credentials []*entity.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	d := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+
+	for _, c := range credentials {
+		var transports []string
+
+		if c.Transports != "" {
+			transports = strings.Split(c.Transports, ",")
+		}
+
+		d = append(d, webauthn.CredentialDescriptor{Type: "public-key", ID: c.CredentialID, Transports: transports})
+	}
+
+	return d
+}
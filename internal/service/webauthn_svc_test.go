@@ -0,0 +1,312 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/dto"
+	"github.com/SomchaiSPB/user-auth/internal/repository"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn/webauthntest"
+	"testing"
+	"time"
+)
+
+var testRelyingParty = webauthn.RelyingParty{
+	ID:      "example.com",
+	Name:    "Example",
+	Origins: []string{"https://example.com"},
+	Timeout: time.Minute,
+}
+
+func newTestWebAuthnSvc(e *testEnv) *WebAuthnService {
+	return NewWebAuthnSvc(
+		e.userSvc,
+		repository.NewUserDBRepository(e.db),
+		repository.NewWebAuthnCredentialDBRepository(e.db),
+		repository.NewWebAuthnChallengeDBRepository(e.db),
+		testRelyingParty,
+	)
+}
+
+// challengeOf returns the challenge of creation or request options.
+func challengeOf(t *testing.T, options []byte) string {
+	t.Helper()
+
+	var o struct {
+		Challenge string `json:"challenge"`
+	}
+
+	if err := json.Unmarshal(options, &o); err != nil {
+		t.Fatalf("decoding options: %v", err)
+	}
+
+	return o.Challenge
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	return data
+}
+
+// registerPasskey runs a registration ceremony for u that must succeed.
+func registerPasskey(t *testing.T, s *WebAuthnService, userID uint) *webauthntest.Authenticator {
+	t.Helper()
+
+	a, err := webauthntest.New(testRelyingParty.ID, testRelyingParty.Origins[0])
+
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+
+	a.UserHandle = userHandle(userID)
+
+	options, err := s.BeginRegistration(userID)
+
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+
+	credential, err := a.Register(challengeOf(t, options))
+
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+
+	if _, err := s.FinishRegistration(userID, mustMarshal(t, dto.WebAuthnRegistrationDTO{Credential: credential})); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+
+	return a
+}
+
+// beginLogin answers a new login ceremony with a.
+func beginLogin(t *testing.T, s *WebAuthnService, a *webauthntest.Authenticator) []byte {
+	t.Helper()
+
+	options, err := s.BeginLogin(nil)
+
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+
+	assertion, err := a.Login(challengeOf(t, options))
+
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	return mustMarshal(t, dto.WebAuthnLoginDTO{Credential: assertion})
+}
+
+func TestWebAuthnRegistrationLoginRoundTrip(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	u := e.createUser(t, "passkey@example.com")
+
+	a := registerPasskey(t, s, u.ID)
+
+	for i := range 2 {
+		response, err := s.FinishLogin(beginLogin(t, s, a), Client{IP: "192.0.2.1"}, e.signer)
+
+		if err != nil {
+			t.Fatalf("login %d: FinishLogin() error = %v", i, err)
+		}
+
+		var tokens dto.AuthUserResponseDTO
+
+		if err := json.Unmarshal(response, &tokens); err != nil || tokens.Token == "" || tokens.RefreshToken == "" {
+			t.Fatalf("login %d: response %s is not a token pair", i, response)
+		}
+	}
+
+	credentials, err := s.credentialRepository.GetByUser(u.ID)
+
+	if err != nil || len(credentials) != 1 {
+		t.Fatalf("GetByUser() = %v, %v, want one credential", credentials, err)
+	}
+
+	if credentials[0].SignCount != a.SignCount {
+		t.Errorf("stored sign count = %d, want %d", credentials[0].SignCount, a.SignCount)
+	}
+}
+
+func TestWebAuthnRejectsReplayedChallenge(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	u := e.createUser(t, "passkey@example.com")
+
+	a := registerPasskey(t, s, u.ID)
+	login := beginLogin(t, s, a)
+
+	if _, err := s.FinishLogin(login, Client{}, e.signer); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	if _, err := s.FinishLogin(login, Client{}, e.signer); !errors.Is(err, ErrWebAuthnChallenge) {
+		t.Errorf("replayed FinishLogin() error = %v, want %v", err, ErrWebAuthnChallenge)
+	}
+}
+
+func TestWebAuthnRejectsClonedAuthenticator(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	u := e.createUser(t, "passkey@example.com")
+
+	a := registerPasskey(t, s, u.ID)
+	clone := *a
+
+	if _, err := s.FinishLogin(beginLogin(t, s, a), Client{}, e.signer); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	// the clone reports the counter the original already used
+	if _, err := s.FinishLogin(beginLogin(t, s, &clone), Client{}, e.signer); !errors.Is(err, webauthn.ErrSignCount) {
+		t.Errorf("FinishLogin() of the clone error = %v, want %v", err, webauthn.ErrSignCount)
+	}
+}
+
+func TestWebAuthnRejectsRegistrationChallengeOfAnotherUser(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	owner := e.createUser(t, "owner@example.com")
+	other := e.createUser(t, "other@example.com")
+
+	a, err := webauthntest.New(testRelyingParty.ID, testRelyingParty.Origins[0])
+
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+
+	options, err := s.BeginRegistration(owner.ID)
+
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+
+	credential, err := a.Register(challengeOf(t, options))
+
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+
+	data := mustMarshal(t, dto.WebAuthnRegistrationDTO{Credential: credential})
+
+	if _, err := s.FinishRegistration(other.ID, data); !errors.Is(err, ErrWebAuthnChallenge) {
+		t.Errorf("FinishRegistration() error = %v, want %v", err, ErrWebAuthnChallenge)
+	}
+}
+
+func TestWebAuthnLoginGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wantErr  error
+	}{
+		{name: "earlier failures", failures: 3},
+		{name: "throttled account", failures: 4, wantErr: ErrTooManyAttempts},
+		{name: "locked account", failures: testLockout.MaxFailures, wantErr: ErrAccountLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			s := newTestWebAuthnSvc(e)
+			u := e.createUser(t, "passkey@example.com")
+			a := registerPasskey(t, s, u.ID)
+
+			e.failSignIns(t, u.Name, "198.51.100.1", tt.failures)
+
+			if _, err := s.FinishLogin(beginLogin(t, s, a), Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FinishLogin() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			// the sign-in cleared the failures, one more does not throttle
+			e.failSignIns(t, u.Name, "198.51.100.1", 1)
+
+			if err := e.userSvc.loginGuard.Attempt(u.Name, "192.0.2.1"); err != nil {
+				t.Errorf("Attempt() after signing in error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWebAuthnFailedAssertionsThrottle(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	u := e.createUser(t, "passkey@example.com")
+	a := registerPasskey(t, s, u.ID)
+
+	for i := range accountFreeFailures + 1 {
+		options, err := s.BeginLogin(nil)
+
+		if err != nil {
+			t.Fatalf("BeginLogin() error = %v", err)
+		}
+
+		assertion, err := a.Login(challengeOf(t, options))
+
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+
+		assertion.Response.Signature = webauthn.EncodeID([]byte("forged signature"))
+
+		_, err = s.FinishLogin(mustMarshal(t, dto.WebAuthnLoginDTO{Credential: assertion}), Client{IP: "192.0.2.1"}, e.signer)
+
+		if !errors.Is(err, ErrWebAuthnLogin) {
+			t.Fatalf("forged assertion %d: FinishLogin() error = %v, want %v", i, err, ErrWebAuthnLogin)
+		}
+	}
+
+	if _, err := s.FinishLogin(beginLogin(t, s, a), Client{IP: "192.0.2.1"}, e.signer); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("FinishLogin() after forged assertions error = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestWebAuthnBeginLoginAllowList(t *testing.T) {
+	e := newTestEnv(t)
+	s := newTestWebAuthnSvc(e)
+	u := e.createUser(t, "passkey@example.com")
+	e.createUser(t, "password@example.com")
+	registerPasskey(t, s, u.ID)
+
+	tests := []struct {
+		username  string
+		wantAllow int
+	}{
+		{username: "", wantAllow: 0},
+		{username: "passkey@example.com", wantAllow: 1},
+		{username: "password@example.com", wantAllow: 0},
+		{username: "nobody@example.com", wantAllow: 0},
+	}
+
+	for _, tt := range tests {
+		options, err := s.BeginLogin(mustMarshal(t, dto.WebAuthnLoginBeginDTO{Username: tt.username}))
+
+		if err != nil {
+			t.Fatalf("BeginLogin(%q) error = %v", tt.username, err)
+		}
+
+		var o webauthn.RequestOptions
+
+		if err := json.Unmarshal(options, &o); err != nil {
+			t.Fatalf("decoding options: %v", err)
+		}
+
+		// users without passkeys look like unknown ones
+		if o.AllowCredentials == nil || len(o.AllowCredentials) != tt.wantAllow {
+			t.Errorf("BeginLogin(%q) allowCredentials = %v, want %d", tt.username, o.AllowCredentials, tt.wantAllow)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package signing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"time"
+)
+
+var (
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrTokenIssuer      = errors.New("token issuer is not accepted")
+	ErrTokenAudience    = errors.New("token audience is not accepted")
+	ErrTokenClaims      = errors.New("token misses a required claim")
+)
+
+// Claims are the claims carried by access tokens. The subject is the user
+// ID and sid the session the token belongs to.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID   string   `json:"sid,omitempty"`
+	Username    string   `json:"username"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// ClaimsValidator checks registered claims, tolerating Leeway of clock
+// skew between the issuer and the verifier. Tokens have to name their
+// subject and carry an ID, which revocation relies on.
+type ClaimsValidator struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+func (v ClaimsValidator) Validate(c *Claims, now time.Time) error {
+	if !c.VerifyExpiresAt(now.Add(-v.Leeway), true) {
+		return ErrTokenExpired
+	}
+
+	if !c.VerifyNotBefore(now.Add(v.Leeway), false) || !c.VerifyIssuedAt(now.Add(v.Leeway), false) {
+		return ErrTokenNotValidYet
+	}
+
+	if v.Issuer != "" && !c.VerifyIssuer(v.Issuer, true) {
+		return ErrTokenIssuer
+	}
+
+	if v.Audience != "" && !c.VerifyAudience(v.Audience, true) {
+		return ErrTokenAudience
+	}
+
+	if c.Subject == "" {
+		return fmt.Errorf("%w: sub", ErrTokenClaims)
+	}
+
+	if c.ID == "" {
+		return fmt.Errorf("%w: jti", ErrTokenClaims)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package signing
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+	"time"
+)
+
+func TestClaimsValidator(t *testing.T) {
+	now := time.Now()
+	v := ClaimsValidator{Issuer: "user-auth", Audience: "user-auth-api", Leeway: 30 * time.Second}
+
+	// valid returns claims that pass, changed by change
+	valid := func(change func(c *Claims)) *Claims {
+		c := &Claims{RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "user-auth",
+			Subject:   "1",
+			Audience:  jwt.ClaimStrings{"user-auth-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        "jti",
+		}}
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		claims  *Claims
+		wantErr error
+	}{
+		{name: "valid", claims: valid(func(c *Claims) {})},
+		{name: "one of several audiences", claims: valid(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other", "user-auth-api"} })},
+		{name: "wrong issuer", claims: valid(func(c *Claims) { c.Issuer = "someone-else" }), wantErr: ErrTokenIssuer},
+		{name: "missing issuer", claims: valid(func(c *Claims) { c.Issuer = "" }), wantErr: ErrTokenIssuer},
+		{name: "wrong audience", claims: valid(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-api"} }), wantErr: ErrTokenAudience},
+		{name: "missing audience", claims: valid(func(c *Claims) { c.Audience = nil }), wantErr: ErrTokenAudience},
+		{name: "expired within the leeway", claims: valid(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-20 * time.Second)) })},
+		{name: "expired past the leeway", claims: valid(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-40 * time.Second)) }), wantErr: ErrTokenExpired},
+		{name: "missing expiry", claims: valid(func(c *Claims) { c.ExpiresAt = nil }), wantErr: ErrTokenExpired},
+		{name: "not before within the leeway", claims: valid(func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(20 * time.Second)) })},
+		{name: "not before past the leeway", claims: valid(func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }), wantErr: ErrTokenNotValidYet},
+		{name: "issued in the future", claims: valid(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) }), wantErr: ErrTokenNotValidYet},
+		{name: "missing subject", claims: valid(func(c *Claims) { c.Subject = "" }), wantErr: ErrTokenClaims},
+		{name: "missing id", claims: valid(func(c *Claims) { c.ID = "" }), wantErr: ErrTokenClaims},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Validate(tt.claims, now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+)
+
+const kidHeader = "kid"
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	ErrKeyMismatch    = errors.New("key type does not match signing algorithm")
+	ErrReadKeyFile    = errors.New("reading key file error")
+	ErrEmptySecret    = errors.New("hmac secret is empty")
+	ErrUnknownKey     = errors.New("unknown signing key")
+)
+
+// Key is a named key able to sign and verify JWTs with a single algorithm.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates a symmetric HS256 key from a shared secret.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	return newHMACKey(id, jwt.SigningMethodHS256, secret)
+}
+
+func newHMACKey(id string, method jwt.SigningMethod, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	return &Key{
+		ID:        id,
+		Method:    method,
+		signKey:   secret,
+		verifyKey: secret,
+	}, nil
+}
+
+// LoadKey reads the key for alg from path: the raw shared secret for HMAC
+// algorithms, a PEM encoded private key otherwise.
+func LoadKey(id, alg, path string) (*Key, error) {
+	if !isHMAC(alg) {
+		return LoadPEMKey(id, alg, path)
+	}
+
+	secret, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadKeyFile, err)
+	}
+
+	return newHMACKey(id, jwt.GetSigningMethod(alg), secret)
+}
+
+// LoadPEMKey reads a PEM encoded private key for an asymmetric algorithm
+// (RS*, PS*, ES* or EdDSA). An empty id is replaced with a fingerprint
+// of the public key.
+func LoadPEMKey(id, alg, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadKeyFile, err)
+	}
+
+	return ParsePEMKey(id, alg, data)
+}
+
+func ParsePEMKey(id, alg string, data []byte) (*Key, error) {
+	method := jwt.GetSigningMethod(alg)
+
+	var (
+		private interface{}
+		public  crypto.PublicKey
+		err     error
+	)
+
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		var k *rsa.PrivateKey
+		k, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err == nil {
+			private, public = k, &k.PublicKey
+		}
+	case *jwt.SigningMethodECDSA:
+		var k *ecdsa.PrivateKey
+		k, err = jwt.ParseECPrivateKeyFromPEM(data)
+		if err == nil {
+			if k.Curve.Params().BitSize != m.CurveBits {
+				return nil, fmt.Errorf("%s: %w", alg, ErrKeyMismatch)
+			}
+			private, public = k, &k.PublicKey
+		}
+	case *jwt.SigningMethodEd25519:
+		var k crypto.PrivateKey
+		k, err = jwt.ParseEdPrivateKeyFromPEM(data)
+		if err == nil {
+			edKey, ok := k.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", alg, ErrKeyMismatch)
+			}
+			private, public = edKey, edKey.Public()
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnsupportedAlg)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", alg, ErrKeyMismatch, err)
+	}
+
+	if id == "" {
+		if id, err = fingerprint(public); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Key{
+		ID:        id,
+		Method:    method,
+		signKey:   private,
+		verifyKey: public,
+	}, nil
+}
+
+// Sign signs the claims and stamps the token with the key id.
+func (k *Key) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.Method, claims)
+
+	if k.ID != "" {
+		token.Header[kidHeader] = k.ID
+	}
+
+	return token.SignedString(k.signKey)
+}
+
+// Keyfunc resolves the verification key for a parsed token, rejecting
+// tokens signed with a different algorithm or announcing another key id.
+func (k *Key) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if kid, ok := token.Header[kidHeader].(string); ok && kid != k.ID {
+		return nil, fmt.Errorf("%s: %w", kid, ErrUnknownKey)
+	}
+
+	return k.verifyKey, nil
+}
+
+// IsSymmetric reports whether the key is a shared secret that must never be published.
+func (k *Key) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+
+	return ok
+}
+
+func isHMAC(alg string) bool {
+	_, ok := jwt.GetSigningMethod(alg).(*jwt.SigningMethodHMAC)
+
+	return ok
+}
+
+func fingerprint(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+
+	return hex.EncodeToString(sum[:8]), nil
+}
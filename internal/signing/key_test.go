@@ -0,0 +1,224 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"testing"
+)
+
+// publicKeyOf builds the public key a consumer of the JWKS would use.
+func publicKeyOf(t *testing.T, jwk JWK) crypto.PublicKey {
+	t.Helper()
+
+	decode := func(field, s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+
+		if err != nil {
+			t.Fatalf("decoding %s: %v", field, err)
+		}
+
+		return b
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode("n", jwk.N)),
+			E: int(new(big.Int).SetBytes(decode("e", jwk.E)).Int64()),
+		}
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[jwk.Crv]
+
+		if !ok {
+			t.Fatalf("unknown curve %q", jwk.Crv)
+		}
+
+		x, y := decode("x", jwk.X), decode("y", jwk.Y)
+
+		// coordinates are padded to the size of the curve
+		if size := (curve.Params().BitSize + 7) / 8; len(x) != size || len(y) != size {
+			t.Fatalf("coordinates are %d and %d bytes, want %d", len(x), len(y), size)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			t.Fatalf("unknown curve %q", jwk.Crv)
+		}
+
+		return ed25519.PublicKey(decode("x", jwk.X))
+	default:
+		t.Fatalf("unknown key type %q", jwk.Kty)
+		return nil
+	}
+}
+
+func TestKeyJWKRoundTrip(t *testing.T) {
+	tests := []struct {
+		alg     string
+		wantKty string
+		wantCrv string
+	}{
+		{alg: "RS256", wantKty: "RSA"},
+		{alg: "PS256", wantKty: "RSA"},
+		{alg: "ES256", wantKty: "EC", wantCrv: "P-256"},
+		{alg: "ES384", wantKty: "EC", wantCrv: "P-384"},
+		{alg: "ES512", wantKty: "EC", wantCrv: "P-521"},
+		{alg: "EdDSA", wantKty: "OKP", wantCrv: "Ed25519"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			data, err := GenerateKeyPEM(tt.alg)
+
+			if err != nil {
+				t.Fatalf("GenerateKeyPEM() error = %v", err)
+			}
+
+			key, err := ParsePEMKey("", tt.alg, data)
+
+			if err != nil {
+				t.Fatalf("ParsePEMKey() error = %v", err)
+			}
+
+			jwk, ok := key.JWK()
+
+			if !ok {
+				t.Fatalf("JWK() of an asymmetric key reports false")
+			}
+
+			if jwk.Kty != tt.wantKty || jwk.Crv != tt.wantCrv || jwk.Alg != tt.alg || jwk.Kid != key.ID || jwk.Use != "sig" {
+				t.Errorf("JWK() = %+v, want kty %s, crv %q, alg %s, kid %s", jwk, tt.wantKty, tt.wantCrv, tt.alg, key.ID)
+			}
+
+			// the ES512 coordinates often have leading zero bytes
+			for range 10 {
+				token, err := key.Sign(jwt.RegisteredClaims{Subject: "1"})
+
+				if err != nil {
+					t.Fatalf("Sign() error = %v", err)
+				}
+
+				parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+					if token.Header[kidHeader] != jwk.Kid || token.Method.Alg() != jwk.Alg {
+						t.Errorf("token header = %v, want kid %s and alg %s", token.Header, jwk.Kid, jwk.Alg)
+					}
+
+					return publicKeyOf(t, jwk), nil
+				})
+
+				if err != nil || !parsed.Valid {
+					t.Fatalf("verifying with the JWK error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHMACKeyIsNotPublished(t *testing.T) {
+	key, err := NewHMACKey("secret", []byte("secret"))
+
+	if err != nil {
+		t.Fatalf("NewHMACKey() error = %v", err)
+	}
+
+	if _, ok := key.JWK(); ok || !key.IsSymmetric() {
+		t.Errorf("JWK() of an HMAC key reports true")
+	}
+}
+
+func TestKeyfuncRejectsOtherAlgorithms(t *testing.T) {
+	es256 := mustGenerateKey(t, "ES256")
+	rs256 := mustGenerateKey(t, "RS256")
+
+	hmac, err := NewHMACKey(es256.ID, []byte("secret"))
+
+	if err != nil {
+		t.Fatalf("NewHMACKey() error = %v", err)
+	}
+
+	ps256 := &Key{ID: rs256.ID, Method: jwt.SigningMethodPS256, signKey: rs256.signKey, verifyKey: rs256.verifyKey}
+
+	tests := []struct {
+		name    string
+		signer  *Key
+		key     *Key
+		wantErr bool
+	}{
+		{name: "same key", signer: es256, key: es256},
+		{name: "hmac token for an ecdsa key", signer: hmac, key: es256, wantErr: true},
+		{name: "pss token for an rsa pkcs1 key", signer: ps256, key: rs256, wantErr: true},
+		{name: "another kid", signer: mustGenerateKey(t, "ES256"), key: es256, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.signer.Sign(jwt.RegisteredClaims{Subject: "1"})
+
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			if _, err := jwt.Parse(token, tt.key.Keyfunc); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePEMKeyRejectsMismatchedKeys(t *testing.T) {
+	p256 := mustGeneratePEM(t, "ES256")
+
+	tests := []struct {
+		name    string
+		alg     string
+		data    []byte
+		wantErr error
+	}{
+		{name: "curve of another algorithm", alg: "ES384", data: p256, wantErr: ErrKeyMismatch},
+		{name: "ecdsa key for rsa", alg: "RS256", data: p256, wantErr: ErrKeyMismatch},
+		{name: "ecdsa key for eddsa", alg: "EdDSA", data: p256, wantErr: ErrKeyMismatch},
+		{name: "hmac algorithm", alg: "HS256", data: p256, wantErr: ErrUnsupportedAlg},
+		{name: "unknown algorithm", alg: "none", data: p256, wantErr: ErrUnsupportedAlg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePEMKey("", tt.alg, tt.data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParsePEMKey() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func mustGeneratePEM(t *testing.T, alg string) []byte {
+	t.Helper()
+
+	data, err := GenerateKeyPEM(alg)
+
+	if err != nil {
+		t.Fatalf("GenerateKeyPEM() error = %v", err)
+	}
+
+	return data
+}
+
+func mustGenerateKey(t *testing.T, alg string) *Key {
+	t.Helper()
+
+	key, err := ParsePEMKey("", alg, mustGeneratePEM(t, alg))
+
+	if err != nil {
+		t.Fatalf("ParsePEMKey() error = %v", err)
+	}
+
+	return key
+}
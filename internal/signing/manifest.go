@@ -0,0 +1,91 @@
+package signing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const manifestFile = "keyring.json"
+
+var ErrReadManifest = errors.New("reading key ring manifest error")
+
+// Manifest describes the keys of a key ring directory.
+type Manifest struct {
+	Keys []ManifestKey `json:"keys"`
+}
+
+// ManifestKey points to a key file in the key ring directory, a PEM
+// private key or the raw secret of an HMAC key. A key signs new tokens
+// from ActivateAt on and is dropped entirely after RetireAt.
+type ManifestKey struct {
+	ID         string     `json:"kid"`
+	Alg        string     `json:"alg"`
+	File       string     `json:"file"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ActivateAt time.Time  `json:"activate_at"`
+	RetireAt   *time.Time `json:"retire_at,omitempty"`
+}
+
+// ReadManifest reads the manifest of dir. A missing manifest yields an empty one.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadManifest, err)
+	}
+
+	var m Manifest
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadManifest, err)
+	}
+
+	return &m, nil
+}
+
+// WriteManifest atomically replaces the manifest of dir, so running
+// instances never observe a partially written file.
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, manifestFile+".*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, manifestFile))
+}
+
+func manifestModTime(dir string) (time.Time, error) {
+	info, err := os.Stat(filepath.Join(dir, manifestFile))
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrReadManifest, err)
+	}
+
+	return info.ModTime(), nil
+}
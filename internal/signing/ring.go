@@ -0,0 +1,205 @@
+package signing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoActiveKey = errors.New("no active signing key")
+
+// Signer signs JWT claims.
+type Signer interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// Verifier resolves the key verifying the signature of a parsed token.
+type Verifier interface {
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+type ringEntry struct {
+	key        *Key
+	activateAt time.Time
+	retireAt   *time.Time
+}
+
+// Ring holds the signing keys known to the service. The active key is the
+// most recently activated one; older keys keep verifying tokens until their
+// retirement date so rotation never invalidates live tokens.
+type Ring struct {
+	dir string
+
+	mu      sync.RWMutex
+	entries []ringEntry
+	modTime time.Time
+}
+
+// NewStaticRing wraps a single key that is always active and never retires.
+func NewStaticRing(key *Key) *Ring {
+	return &Ring{entries: []ringEntry{{key: key}}}
+}
+
+// LoadRing loads the key ring described by the manifest in dir.
+func LoadRing(dir string) (*Ring, error) {
+	r := &Ring{dir: dir}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload re-reads the manifest when it changed on disk and reports whether
+// the ring was updated. Static rings are never reloaded.
+func (r *Ring) Reload() (bool, error) {
+	if r.dir == "" {
+		return false, nil
+	}
+
+	modTime, err := manifestModTime(r.dir)
+
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	m, err := ReadManifest(r.dir)
+
+	if err != nil {
+		return false, err
+	}
+
+	entries := make([]ringEntry, 0, len(m.Keys))
+
+	for _, mk := range m.Keys {
+		key, err := LoadKey(mk.ID, mk.Alg, filepath.Join(r.dir, mk.File))
+
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", mk.ID, err)
+		}
+
+		entries = append(entries, ringEntry{
+			key:        key,
+			activateAt: mk.ActivateAt,
+			retireAt:   mk.RetireAt,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].activateAt.After(entries[j].activateAt)
+	})
+
+	r.mu.Lock()
+	r.entries = entries
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (r *Ring) Active() (*Key, error) {
+	now := time.Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.entries {
+		if !e.activateAt.After(now) && !e.retired(now) {
+			return e.key, nil
+		}
+	}
+
+	return nil, ErrNoActiveKey
+}
+
+func (r *Ring) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.Active()
+
+	if err != nil {
+		return "", err
+	}
+
+	return key.Sign(claims)
+}
+
+// Keyfunc selects the verification key by the token kid header. Tokens
+// without a kid are checked against an imported key without one, or else
+// against the active key.
+func (r *Ring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header[kidHeader].(string)
+
+	if !ok && !r.hasKeyWithoutID() {
+		key, err := r.Active()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return key.Keyfunc(token)
+	}
+
+	now := time.Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.entries {
+		if e.key.ID == kid && !e.retired(now) {
+			return e.key.Keyfunc(token)
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", kid, ErrUnknownKey)
+}
+
+// JWKSet returns the public keys of all keys that are not retired,
+// including the ones waiting for activation.
+func (r *Ring) JWKSet() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.entries {
+		if e.retired(now) {
+			continue
+		}
+		if jwk, ok := e.key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+func (r *Ring) hasKeyWithoutID() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.entries {
+		if e.key.ID == "" && !e.retired(time.Now()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e ringEntry) retired(now time.Time) bool {
+	return e.retireAt != nil && now.After(*e.retireAt)
+}
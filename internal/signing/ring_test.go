@@ -0,0 +1,158 @@
+package signing
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T, id string) *Key {
+	t.Helper()
+
+	key, err := NewHMACKey(id, []byte("secret of "+id))
+
+	if err != nil {
+		t.Fatalf("NewHMACKey() error = %v", err)
+	}
+
+	return key
+}
+
+// newTestRing holds entries sorted by activation as Reload leaves them.
+func newTestRing(entries ...ringEntry) *Ring {
+	return &Ring{entries: entries}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRingActive(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		entries []ringEntry
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "newest activated key",
+			entries: []ringEntry{{key: newTestKey(t, "new"), activateAt: now.Add(-time.Minute)}, {key: newTestKey(t, "old"), activateAt: now.Add(-time.Hour)}},
+			want:    "new",
+		},
+		{
+			name:    "key waiting for activation",
+			entries: []ringEntry{{key: newTestKey(t, "next"), activateAt: now.Add(time.Minute)}, {key: newTestKey(t, "current"), activateAt: now.Add(-time.Hour)}},
+			want:    "current",
+		},
+		{
+			name:    "retired key",
+			entries: []ringEntry{{key: newTestKey(t, "old"), activateAt: now.Add(-time.Hour), retireAt: ptr(now.Add(-time.Minute))}},
+			wantErr: ErrNoActiveKey,
+		},
+		{
+			name:    "no key active yet",
+			entries: []ringEntry{{key: newTestKey(t, "next"), activateAt: now.Add(time.Minute)}},
+			wantErr: ErrNoActiveKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := newTestRing(tt.entries...).Active()
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Active() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && key.ID != tt.want {
+				t.Errorf("Active() = %s, want %s", key.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingKeyfunc(t *testing.T) {
+	now := time.Now()
+
+	r := newTestRing(
+		ringEntry{key: newTestKey(t, "next"), activateAt: now.Add(time.Minute)},
+		ringEntry{key: newTestKey(t, "current"), activateAt: now.Add(-time.Hour)},
+		ringEntry{key: newTestKey(t, "retiring"), activateAt: now.Add(-2 * time.Hour), retireAt: ptr(now.Add(time.Minute))},
+		ringEntry{key: newTestKey(t, "retired"), activateAt: now.Add(-3 * time.Hour), retireAt: ptr(now.Add(-time.Minute))},
+	)
+
+	tests := []struct {
+		name    string
+		key     *Key
+		wantErr error
+	}{
+		{name: "active key", key: newTestKey(t, "current")},
+		{name: "key waiting for activation", key: newTestKey(t, "next")},
+		{name: "key before its retirement", key: newTestKey(t, "retiring")},
+		{name: "retired key", key: newTestKey(t, "retired"), wantErr: ErrUnknownKey},
+		{name: "unknown key", key: newTestKey(t, "unknown"), wantErr: ErrUnknownKey},
+		{name: "known kid signed with another secret", key: &Key{ID: "current", Method: jwt.SigningMethodHS256, signKey: []byte("forged")}, wantErr: jwt.ErrSignatureInvalid},
+		{name: "token without kid checked with the active key", key: &Key{Method: jwt.SigningMethodHS256, signKey: []byte("secret of current")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.key.Sign(jwt.RegisteredClaims{Subject: "1"})
+
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			if _, err := jwt.Parse(token, r.Keyfunc); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRingReloadSkipsUnchangedManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Rotate(dir, "ES256", time.Minute, time.Hour); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	r, err := LoadRing(dir)
+
+	if err != nil {
+		t.Fatalf("LoadRing() error = %v", err)
+	}
+
+	path := filepath.Join(dir, manifestFile)
+	info, err := os.Stat(path)
+
+	if err != nil {
+		t.Fatalf("stat manifest: %v", err)
+	}
+
+	// a manifest that is not read again cannot fail to parse
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("writing manifest: %v", err)
+	}
+
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("restoring mtime: %v", err)
+	}
+
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatalf("Reload() = %v, %v, want false, nil", reloaded, err)
+	}
+
+	if err := os.Chtimes(path, info.ModTime().Add(time.Second), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatalf("touching manifest: %v", err)
+	}
+
+	if _, err := r.Reload(); !errors.Is(err, ErrReadManifest) {
+		t.Errorf("Reload() of a changed manifest error = %v, want %v", err, ErrReadManifest)
+	}
+}
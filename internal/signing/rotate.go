@@ -0,0 +1,221 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const rsaKeyBits = 3072
+
+var ErrRingNotEmpty = errors.New("key ring already has keys")
+
+// GenerateKeyPEM creates a new private key for alg encoded as PKCS #8 PEM.
+func GenerateKeyPEM(alg string) ([]byte, error) {
+	var private interface{}
+	var err error
+
+	switch m := jwt.GetSigningMethod(alg).(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case *jwt.SigningMethodECDSA:
+		var curve elliptic.Curve
+		switch m.CurveBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		private, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case *jwt.SigningMethodEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnsupportedAlg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// generateKeyFile creates a new key for alg and returns its id together
+// with the name and content of its file in the key ring directory: the raw
+// secret of HMAC keys, a PKCS #8 PEM private key otherwise.
+func generateKeyFile(alg string) (string, string, []byte, error) {
+	if !isHMAC(alg) {
+		data, err := GenerateKeyPEM(alg)
+
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		key, err := ParsePEMKey("", alg, data)
+
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		return key.ID, key.ID + ".pem", data, nil
+	}
+
+	// a secret as long as the hash output, and a random id as a digest of
+	// the secret must not be published
+	secret := make([]byte, jwt.GetSigningMethod(alg).(*jwt.SigningMethodHMAC).Hash.Size())
+	id := make([]byte, 8)
+
+	for _, b := range [][]byte{secret, id} {
+		if _, err := rand.Read(b); err != nil {
+			return "", "", nil, err
+		}
+	}
+
+	kid := hex.EncodeToString(id)
+
+	return kid, kid + ".key", secret, nil
+}
+
+// Rotate generates a new key in dir that becomes active after activateAfter,
+// giving every running instance time to reload the ring and trust it first.
+// The first key of an empty ring is active immediately.
+// Keys that are currently in use retire retireAfter past the activation, which
+// should exceed the lifetime of issued access tokens. Already retired keys are
+// removed from the ring.
+func Rotate(dir, alg string, activateAfter, retireAfter time.Duration) (*ManifestKey, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	m, err := ReadManifest(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, file, data, err := generateKeyFile(alg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), data, 0o600); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	activateAt := now
+
+	// the very first key has nothing to overlap with
+	if len(m.Keys) > 0 {
+		activateAt = now.Add(activateAfter)
+	}
+
+	retireAt := activateAt.Add(retireAfter)
+
+	keys := m.Keys[:0]
+
+	for _, k := range m.Keys {
+		if k.RetireAt != nil && now.After(*k.RetireAt) {
+			os.Remove(filepath.Join(dir, k.File))
+			continue
+		}
+		if k.RetireAt == nil || k.RetireAt.After(retireAt) {
+			k.RetireAt = &retireAt
+		}
+		keys = append(keys, k)
+	}
+
+	m.Keys = keys
+
+	mk := ManifestKey{
+		ID:         id,
+		Alg:        alg,
+		File:       file,
+		CreatedAt:  now,
+		ActivateAt: activateAt,
+	}
+
+	m.Keys = append(m.Keys, mk)
+
+	if err := WriteManifest(dir, m); err != nil {
+		return nil, err
+	}
+
+	return &mk, nil
+}
+
+// Import starts an empty key ring in dir with the HMAC secret tokens are
+// signed with so far, under the kid they carry, if any. The secret stays
+// active until the next rotation and verifies its tokens until the
+// rotation retires it, so moving to a key ring keeps live tokens valid.
+func Import(dir, id, alg string, secret []byte) (*ManifestKey, error) {
+	if !isHMAC(alg) {
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnsupportedAlg)
+	}
+
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	m, err := ReadManifest(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Keys) > 0 {
+		return nil, ErrRingNotEmpty
+	}
+
+	file := "imported.key"
+
+	if id != "" {
+		file = id + ".key"
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), secret, 0o600); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	mk := ManifestKey{
+		ID:         id,
+		Alg:        alg,
+		File:       file,
+		CreatedAt:  now,
+		ActivateAt: now,
+	}
+
+	m.Keys = append(m.Keys, mk)
+
+	if err := WriteManifest(dir, m); err != nil {
+		return nil, err
+	}
+
+	return &mk, nil
+}
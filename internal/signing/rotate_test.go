@@ -0,0 +1,134 @@
+package signing
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	const (
+		activateAfter = time.Minute
+		retireAfter   = time.Hour
+	)
+
+	dir := t.TempDir()
+
+	first, err := Rotate(dir, "ES256", activateAfter, retireAfter)
+
+	if err != nil {
+		t.Fatalf("first Rotate() error = %v", err)
+	}
+
+	if first.RetireAt != nil || time.Since(first.ActivateAt) > time.Minute {
+		t.Errorf("first key = %+v, want it active now and never retiring", first)
+	}
+
+	second, err := Rotate(dir, "HS256", activateAfter, retireAfter)
+
+	if err != nil {
+		t.Fatalf("second Rotate() error = %v", err)
+	}
+
+	if d := second.ActivateAt.Sub(first.ActivateAt); d < activateAfter {
+		t.Errorf("second key activates %v after the first, want at least %v", d, activateAfter)
+	}
+
+	m, err := ReadManifest(dir)
+
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+
+	if len(m.Keys) != 2 {
+		t.Fatalf("manifest has %d keys, want 2", len(m.Keys))
+	}
+
+	if want := second.ActivateAt.Add(retireAfter); m.Keys[0].RetireAt == nil || !m.Keys[0].RetireAt.Equal(want) {
+		t.Errorf("first key retires at %v, want %v", m.Keys[0].RetireAt, want)
+	}
+
+	if m.Keys[1].RetireAt != nil {
+		t.Errorf("new key retires at %v, want never", m.Keys[1].RetireAt)
+	}
+
+	r, err := LoadRing(dir)
+
+	if err != nil {
+		t.Fatalf("LoadRing() error = %v", err)
+	}
+
+	// both keys verify, the secret is not published
+	if set := r.JWKSet(); len(set.Keys) != 1 || set.Keys[0].Kid != first.ID {
+		t.Errorf("JWKSet() = %+v, want only %s", set, first.ID)
+	}
+
+	if len(r.entries) != 2 || r.entries[0].key.ID != second.ID || r.entries[1].key.ID != first.ID {
+		t.Errorf("ring holds %+v, want %s then %s", r.entries, second.ID, first.ID)
+	}
+}
+
+func TestImportKeepsTokensValid(t *testing.T) {
+	dir := t.TempDir()
+	secret := []byte("configured secret")
+
+	legacy, err := NewHMACKey("", secret)
+
+	if err != nil {
+		t.Fatalf("NewHMACKey() error = %v", err)
+	}
+
+	token, err := legacy.Sign(jwt.RegisteredClaims{Subject: "1"})
+
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	if _, err := Import(dir, "", "HS256", secret); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if _, err := Import(dir, "", "HS256", secret); !errors.Is(err, ErrRingNotEmpty) {
+		t.Errorf("second Import() error = %v, want %v", err, ErrRingNotEmpty)
+	}
+
+	// the rotated key is active right away
+	if _, err := Rotate(dir, "HS256", 0, time.Hour); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	r, err := LoadRing(dir)
+
+	if err != nil {
+		t.Fatalf("LoadRing() error = %v", err)
+	}
+
+	if active, err := r.Active(); err != nil || active.ID == "" {
+		t.Fatalf("Active() = %v, %v, want the rotated key", active, err)
+	}
+
+	if _, err := jwt.Parse(token, r.Keyfunc); err != nil {
+		t.Errorf("Parse() of a token signed before the import error = %v", err)
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		alg     string
+		secret  []byte
+		wantErr error
+	}{
+		{name: "asymmetric algorithm", alg: "ES256", secret: []byte("secret"), wantErr: ErrUnsupportedAlg},
+		{name: "empty secret", alg: "HS256", wantErr: ErrEmptySecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import(t.TempDir(), "", tt.alg, tt.secret); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Import() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package suggest
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 25
+)
+
+// gramPad marks word boundaries so that grams also carry the position of
+// the letters, which is what makes prefixes rank above infix matches.
+const gramPad = ' '
+
+// Suggestion is a product name offered for a typed prefix.
+type Suggestion struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type entry struct {
+	name  string
+	words [][]rune
+	grams []string
+}
+
+// Index is an in-memory trigram index of product names. It is safe for
+// concurrent use; writers replace single entries, Rebuild swaps all of them.
+type Index struct {
+	mu      sync.RWMutex
+	entries map[uint]*entry
+	grams   map[string]map[uint]struct{}
+}
+
+func NewIndex() *Index {
+	return &Index{
+		entries: map[uint]*entry{},
+		grams:   map[string]map[uint]struct{}{},
+	}
+}
+
+// Rebuild replaces the whole content of the index with names keyed by ID.
+func (idx *Index) Rebuild(names map[uint]string) {
+	entries := make(map[uint]*entry, len(names))
+	grams := map[string]map[uint]struct{}{}
+
+	for id, name := range names {
+		e := newEntry(name)
+		entries[id] = e
+		addGrams(grams, id, e.grams)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = entries
+	idx.grams = grams
+}
+
+// Put adds the product name or replaces the one indexed before.
+func (idx *Index) Put(id uint, name string) {
+	e := newEntry(name)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	idx.entries[id] = e
+	addGrams(idx.grams, id, e.grams)
+}
+
+func (idx *Index) Remove(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.entries)
+}
+
+func (idx *Index) remove(id uint) {
+	e, ok := idx.entries[id]
+
+	if !ok {
+		return
+	}
+
+	for _, g := range e.grams {
+		delete(idx.grams[g], id)
+
+		if len(idx.grams[g]) == 0 {
+			delete(idx.grams, g)
+		}
+	}
+
+	delete(idx.entries, id)
+}
+
+type match struct {
+	Suggestion
+	distance   int
+	similarity float64
+}
+
+// Suggest returns up to limit names starting with prefix or with one of
+// their words starting with it. Candidates sharing a trigram with prefix
+// are accepted with a few typos, more of them the longer the prefix is.
+// Exact prefix matches come first, then fewer typos and more shared trigrams.
+func (idx *Index) Suggest(prefix string, limit int) []Suggestion {
+	query := []rune(strings.Join(normalize(prefix), string(gramPad)))
+
+	if len(query) == 0 || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	queryGrams := prefixGrams(query)
+	maxDistance := allowedTypos(len(query))
+
+	idx.mu.RLock()
+
+	shared := map[uint]int{}
+
+	for _, g := range queryGrams {
+		for id := range idx.grams[g] {
+			shared[id]++
+		}
+	}
+
+	matches := make([]match, 0, len(shared))
+
+	for id, n := range shared {
+		e := idx.entries[id]
+		distance := e.prefixDistance(query)
+
+		if distance > maxDistance {
+			continue
+		}
+
+		matches = append(matches, match{
+			Suggestion: Suggestion{ID: id, Name: e.name},
+			distance:   distance,
+			similarity: float64(n) / float64(len(queryGrams)),
+		})
+	}
+
+	idx.mu.RUnlock()
+
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(a.distance, b.distance),
+			cmp.Compare(b.similarity, a.similarity),
+			cmp.Compare(len(a.Name), len(b.Name)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	suggestions := make([]Suggestion, 0, min(limit, len(matches)))
+
+	for _, m := range matches[:min(limit, len(matches))] {
+		suggestions = append(suggestions, m.Suggestion)
+	}
+
+	return suggestions
+}
+
+func newEntry(name string) *entry {
+	words := normalize(name)
+	e := &entry{name: name, words: make([][]rune, len(words))}
+
+	seen := map[string]struct{}{}
+
+	for i, w := range words {
+		e.words[i] = []rune(w)
+
+		for _, g := range wordGrams(e.words[i]) {
+			if _, ok := seen[g]; !ok {
+				seen[g] = struct{}{}
+				e.grams = append(e.grams, g)
+			}
+		}
+	}
+
+	return e
+}
+
+// prefixDistance is the smallest number of edits turning query into the
+// beginning of the name read from any of its words onwards.
+func (e *entry) prefixDistance(query []rune) int {
+	best := len(query)
+
+	for i := range e.words {
+		var tail []rune
+
+		for j, w := range e.words[i:] {
+			if j > 0 {
+				tail = append(tail, gramPad)
+			}
+			tail = append(tail, w...)
+
+			if len(tail) >= len(query)+best {
+				break
+			}
+		}
+
+		best = min(best, prefixEditDistance(query, tail))
+
+		if best == 0 {
+			break
+		}
+	}
+
+	return best
+}
+
+// prefixEditDistance returns the Levenshtein distance between query and
+// the closest prefix of s.
+func prefixEditDistance(query, s []rune) int {
+	prev := make([]int, len(s)+1)
+	curr := make([]int, len(s)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(query); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(s); j++ {
+			cost := 1
+			if query[i-1] == s[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return slices.Min(prev)
+}
+
+func allowedTypos(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// normalize lower cases s and splits it into words of letters and digits.
+func normalize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordGrams returns the trigrams of a word padded on both sides.
+func wordGrams(w []rune) []string {
+	padded := append([]rune{gramPad, gramPad}, w...)
+
+	return trigrams(append(padded, gramPad))
+}
+
+// prefixGrams returns the trigrams of every word of a query. The last word
+// is not padded at its end as the user may still be typing it.
+func prefixGrams(query []rune) []string {
+	words := strings.Fields(string(query))
+
+	var grams []string
+
+	for i, w := range words {
+		padded := append([]rune{gramPad, gramPad}, []rune(w)...)
+
+		if i < len(words)-1 {
+			padded = append(padded, gramPad)
+		}
+
+		grams = append(grams, trigrams(padded)...)
+	}
+
+	slices.Sort(grams)
+
+	return slices.Compact(grams)
+}
+
+func trigrams(r []rune) []string {
+	grams := make([]string, 0, max(len(r)-2, 0))
+
+	for i := 0; i+3 <= len(r); i++ {
+		grams = append(grams, string(r[i:i+3]))
+	}
+
+	return grams
+}
+
+func addGrams(grams map[string]map[uint]struct{}, id uint, entryGrams []string) {
+	for _, g := range entryGrams {
+		if grams[g] == nil {
+			grams[g] = map[uint]struct{}{}
+		}
+
+		grams[g][id] = struct{}{}
+	}
+}
@@ -0,0 +1,122 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefixEditDistance(t *testing.T) {
+	tests := []struct {
+		query string
+		s     string
+		want  int
+	}{
+		{query: "", s: "abc", want: 0},
+		{query: "abc", s: "", want: 3},
+		{query: "abc", s: "abcdef", want: 0},
+		{query: "abd", s: "abcdef", want: 1},
+		{query: "abxc", s: "abcdef", want: 1},
+		{query: "acb", s: "abc", want: 1},
+		{query: "iphnoe", s: "iphone", want: 2},
+		{query: "xyz", s: "abc", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := prefixEditDistance([]rune(tt.query), []rune(tt.s)); got != tt.want {
+			t.Errorf("prefixEditDistance(%q, %q) = %d, want %d", tt.query, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIndexSuggest(t *testing.T) {
+	idx := NewIndex()
+	idx.Rebuild(map[uint]string{
+		1: "iPhone 15 Pro",
+		2: "iPad Air",
+		3: "Pro Display",
+		4: "Phone case",
+	})
+
+	var (
+		iPhone  = Suggestion{ID: 1, Name: "iPhone 15 Pro"}
+		iPad    = Suggestion{ID: 2, Name: "iPad Air"}
+		display = Suggestion{ID: 3, Name: "Pro Display"}
+		phone   = Suggestion{ID: 4, Name: "Phone case"}
+	)
+
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   []Suggestion
+	}{
+		{name: "prefix", prefix: "ip", limit: DefaultLimit, want: []Suggestion{iPad, iPhone}},
+		{name: "prefix of a later word", prefix: "dis", limit: DefaultLimit, want: []Suggestion{display}},
+		{name: "exact matches before typos", prefix: "pro", limit: DefaultLimit, want: []Suggestion{display, iPhone, phone}},
+		{name: "several words", prefix: "iphone 15 p", limit: DefaultLimit, want: []Suggestion{iPhone}},
+		{name: "case and punctuation", prefix: "PRO-D", limit: DefaultLimit, want: []Suggestion{display}},
+		{name: "typos", prefix: "iphnoe", limit: DefaultLimit, want: []Suggestion{iPhone}},
+		{name: "too many typos", prefix: "ipgggg", limit: DefaultLimit, want: []Suggestion{}},
+		{name: "no typos in short prefixes", prefix: "iq", limit: DefaultLimit, want: []Suggestion{}},
+		{name: "top n", prefix: "p", limit: 2, want: []Suggestion{phone, display}},
+		{name: "zero limit", prefix: "p", limit: 0, want: []Suggestion{}},
+		{name: "empty prefix", prefix: " - ", limit: DefaultLimit, want: []Suggestion{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idx.Suggest(tt.prefix, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest(%q, %d) = %+v, want %+v", tt.prefix, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	idx := NewIndex()
+
+	idx.Put(1, "Apple")
+	idx.Put(1, "Banana")
+
+	if got := idx.Suggest("app", DefaultLimit); len(got) != 0 {
+		t.Errorf("Suggest() of a replaced name = %+v, want none", got)
+	}
+
+	if got, want := idx.Suggest("ban", DefaultLimit), []Suggestion{{ID: 1, Name: "Banana"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest() after Put() = %+v, want %+v", got, want)
+	}
+
+	idx.Put(2, "Apple")
+
+	if idx.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", idx.Len())
+	}
+
+	idx.Remove(1)
+	idx.Remove(3)
+
+	if got := idx.Suggest("ban", DefaultLimit); len(got) != 0 {
+		t.Errorf("Suggest() of a removed name = %+v, want none", got)
+	}
+
+	idx.Rebuild(map[uint]string{3: "Cherry"})
+
+	if idx.Len() != 1 {
+		t.Errorf("Len() after Rebuild() = %d, want 1", idx.Len())
+	}
+
+	if got := idx.Suggest("app", DefaultLimit); len(got) != 0 {
+		t.Errorf("Suggest() of a name dropped by Rebuild() = %+v, want none", got)
+	}
+
+	if got, want := idx.Suggest("che", DefaultLimit), []Suggestion{{ID: 3, Name: "Cherry"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest() after Rebuild() = %+v, want %+v", got, want)
+	}
+
+	idx.Remove(3)
+
+	// removed entries leave no grams behind
+	if len(idx.grams) != 0 {
+		t.Errorf("grams after removing every entry = %v, want none", idx.grams)
+	}
+}
@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors,
+// "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to the last six of eight digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+
+			if err != nil {
+				t.Fatalf("Code() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Code() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeRejectsInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); !errors.Is(err, ErrInvalidSecret) {
+		t.Errorf("Code() error = %v, want %v", err, ErrInvalidSecret)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Counter(now)
+
+	codeAt := func(counter int64) string {
+		code, err := Code(rfcSecret, counter)
+
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+
+		return code
+	}
+
+	tests := []struct {
+		name        string
+		code        string
+		wantCounter int64
+		wantOK      bool
+	}{
+		{name: "current step", code: codeAt(step), wantCounter: step, wantOK: true},
+		{name: "one step late", code: codeAt(step - 1), wantCounter: step - 1, wantOK: true},
+		{name: "one step early", code: codeAt(step + 1), wantCounter: step + 1, wantOK: true},
+		{name: "two steps late", code: codeAt(step - 2)},
+		{name: "two steps early", code: codeAt(step + 2)},
+		{name: "surrounding spaces", code: " " + codeAt(step) + " ", wantCounter: step, wantOK: true},
+		{name: "too short", code: codeAt(step)[1:]},
+		{name: "too long", code: codeAt(step) + "0"},
+		{name: "empty", code: ""},
+		{name: "wrong code", code: "000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Validate(rfcSecret, tt.code, now)
+
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("Validate() = %d, %t, want %d, %t", counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+
+	if key, err := encoding.DecodeString(secret); err != nil || len(key) != secretBytes {
+		t.Errorf("secret %q does not decode to %d bytes: %v", secret, secretBytes, err)
+	}
+
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("Code() with a generated secret error = %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Acme Inc", "user@example.com", rfcSecret))
+
+	if err != nil {
+		t.Fatalf("parsing URI: %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Acme Inc:user@example.com" {
+		t.Errorf("URI = %s, want otpauth://totp/ with the issuer and account label", u)
+	}
+
+	q := u.Query()
+
+	for param, want := range map[string]string{
+		"secret": rfcSecret, "issuer": "Acme Inc", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if got := q.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidCBOR = errors.New("invalid cbor")
+
+// maxCBORDepth bounds nesting so hostile input cannot exhaust the stack.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item of data and returns the bytes that
+// follow it. It supports the subset authenticators emit: integers, byte and
+// text strings, arrays, maps, tags and simple values, all of definite length.
+// Maps decode to map[interface{}]interface{} keyed by int64 or string.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deep", ErrInvalidCBOR)
+	}
+
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidCBOR)
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	if major == 7 {
+		return decodeSimple(data, info)
+	}
+
+	arg, rest, err := decodeArgument(data, info)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return int64(arg), rest, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return -1 - int64(arg), rest, nil
+	case 2, 3:
+		if arg > uint64(len(rest)) {
+			return nil, nil, fmt.Errorf("%w: string exceeds data", ErrInvalidCBOR)
+		}
+		if major == 3 {
+			return string(rest[:arg]), rest[arg:], nil
+		}
+		return append([]byte(nil), rest[:arg]...), rest[arg:], nil
+	case 4:
+		// every item takes at least one byte
+		if arg > uint64(len(rest)) {
+			return nil, nil, fmt.Errorf("%w: array exceeds data", ErrInvalidCBOR)
+		}
+
+		items := make([]interface{}, 0, arg)
+
+		for range arg {
+			var item interface{}
+
+			item, rest, err = decodeItem(rest, depth+1)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, rest, nil
+	case 5:
+		if arg > uint64(len(rest))/2 {
+			return nil, nil, fmt.Errorf("%w: map exceeds data", ErrInvalidCBOR)
+		}
+
+		m := make(map[interface{}]interface{}, arg)
+
+		for range arg {
+			var key, value interface{}
+
+			key, rest, err = decodeItem(rest, depth+1)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key", ErrInvalidCBOR)
+			}
+
+			value, rest, err = decodeItem(rest, depth+1)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			m[key] = value
+		}
+
+		return m, rest, nil
+	case 6:
+		// tags only annotate the following item
+		return decodeItem(rest, depth+1)
+	}
+
+	return nil, nil, fmt.Errorf("%w: unsupported major type %d", ErrInvalidCBOR, major)
+}
+
+func decodeArgument(data []byte, info byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data[1:], nil
+	case info == 24 && len(data) >= 2:
+		return uint64(data[1]), data[2:], nil
+	case info == 25 && len(data) >= 3:
+		return uint64(binary.BigEndian.Uint16(data[1:])), data[3:], nil
+	case info == 26 && len(data) >= 5:
+		return uint64(binary.BigEndian.Uint32(data[1:])), data[5:], nil
+	case info == 27 && len(data) >= 9:
+		return binary.BigEndian.Uint64(data[1:]), data[9:], nil
+	case info == 31:
+		return 0, nil, fmt.Errorf("%w: indefinite length is not supported", ErrInvalidCBOR)
+	}
+
+	return 0, nil, fmt.Errorf("%w: malformed argument", ErrInvalidCBOR)
+}
+
+func decodeSimple(data []byte, info byte) (interface{}, []byte, error) {
+	switch {
+	case info == 20:
+		return false, data[1:], nil
+	case info == 21:
+		return true, data[1:], nil
+	case info == 22 || info == 23:
+		return nil, data[1:], nil
+	case info == 26 && len(data) >= 5:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:]))), data[5:], nil
+	case info == 27 && len(data) >= 9:
+		return math.Float64frombits(binary.BigEndian.Uint64(data[1:])), data[9:], nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: unsupported simple value", ErrInvalidCBOR)
+}
@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{name: "small unsigned", data: []byte{0x17}, want: int64(23)},
+		{name: "one byte unsigned", data: []byte{0x18, 0xff}, want: int64(255)},
+		{name: "two byte unsigned", data: []byte{0x19, 0x01, 0x00}, want: int64(256)},
+		{name: "four byte unsigned", data: []byte{0x1a, 0x00, 0x01, 0x00, 0x00}, want: int64(65536)},
+		{name: "eight byte unsigned", data: []byte{0x1b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, want: int64(1<<63 - 1)},
+		{name: "negative", data: []byte{0x26}, want: int64(-7)},
+		{name: "two byte negative", data: []byte{0x39, 0x01, 0x00}, want: int64(-257)},
+		{name: "byte string", data: []byte{0x43, 0x01, 0x02, 0x03}, want: []byte{1, 2, 3}},
+		{name: "text string", data: []byte{0x63, 'f', 'm', 't'}, want: "fmt"},
+		{name: "empty array", data: []byte{0x80}, want: []interface{}{}},
+		{name: "array", data: []byte{0x82, 0x01, 0x20}, want: []interface{}{int64(1), int64(-1)}},
+		{
+			name: "map with int and text keys",
+			data: []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5},
+			want: map[interface{}]interface{}{int64(1): int64(2), "a": true},
+		},
+		{name: "tag is skipped", data: []byte{0xc2, 0x41, 0x01}, want: []byte{1}},
+		{name: "false", data: []byte{0xf4}, want: false},
+		{name: "null", data: []byte{0xf6}, want: nil},
+		{name: "float32", data: []byte{0xfa, 0x3f, 0xc0, 0x00, 0x00}, want: 1.5},
+		{name: "float64", data: []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, want: 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trailer := []byte{0xde, 0xad}
+
+			got, rest, err := decodeCBOR(append(bytes.Clone(tt.data), trailer...))
+
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+
+			if !bytes.Equal(rest, trailer) {
+				t.Errorf("rest = %x, want %x", rest, trailer)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "indefinite byte string", data: []byte{0x5f, 0x41, 0x01, 0xff}},
+		{name: "indefinite text string", data: []byte{0x7f, 0x61, 'a', 0xff}},
+		{name: "indefinite array", data: []byte{0x9f, 0x01, 0xff}},
+		{name: "indefinite map", data: []byte{0xbf, 0x01, 0x02, 0xff}},
+		{name: "reserved additional info", data: []byte{0x1c}},
+		{name: "unsigned overflows int64", data: []byte{0x1b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "negative overflows int64", data: []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "string longer than data", data: []byte{0x45, 0x01, 0x02}},
+		{name: "huge string length", data: []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "huge array length", data: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "huge map length", data: []byte{0xbb, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "array key", data: []byte{0xa1, 0x80, 0x01}},
+		{name: "byte string key", data: []byte{0xa1, 0x41, 0x01, 0x01}},
+		{name: "unsupported simple value", data: []byte{0xf8, 0x20}},
+		{name: "half float", data: []byte{0xf9, 0x3c, 0x00}},
+		{name: "break outside indefinite item", data: []byte{0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); !errors.Is(err, ErrInvalidCBOR) {
+				t.Errorf("decodeCBOR() error = %v, want %v", err, ErrInvalidCBOR)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORRejectsTruncatedInput(t *testing.T) {
+	// an attestation object shaped map with nested items of every kind
+	data := []byte{
+		0xa3,
+		0x63, 'f', 'm', 't', 0x66, 'p', 'a', 'c', 'k', 'e', 'd',
+		0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa2, 0x63, 'a', 'l', 'g', 0x26, 0x63, 's', 'i', 'g', 0x42, 0x01, 0x02,
+		0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x82, 0x19, 0x01, 0x00, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+	}
+
+	if _, rest, err := decodeCBOR(data); err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR() of the full input: rest = %x, error = %v", rest, err)
+	}
+
+	for n := range len(data) {
+		if _, _, err := decodeCBOR(data[:n]); !errors.Is(err, ErrInvalidCBOR) {
+			t.Errorf("decodeCBOR() of the first %d bytes: error = %v, want %v", n, err, ErrInvalidCBOR)
+		}
+	}
+}
+
+func TestDecodeCBORNestingDepth(t *testing.T) {
+	nested := func(depth int) []byte {
+		return append(bytes.Repeat([]byte{0x81}, depth), 0x00)
+	}
+
+	if _, _, err := decodeCBOR(nested(maxCBORDepth)); err != nil {
+		t.Errorf("decodeCBOR() at the maximum depth: error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "arrays", data: nested(maxCBORDepth + 1)},
+		{name: "maps", data: append(bytes.Repeat([]byte{0xa1, 0x01}, maxCBORDepth+1), 0x00)},
+		{name: "tags", data: append(bytes.Repeat([]byte{0xc6}, maxCBORDepth+1), 0x00)},
+		{name: "deep enough to exhaust the stack", data: nested(1 << 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); !errors.Is(err, ErrInvalidCBOR) {
+				t.Errorf("decodeCBOR() error = %v, want %v", err, ErrInvalidCBOR)
+			}
+		})
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	f.Add([]byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5})
+	f.Add([]byte{0x9f, 0x01, 0xff})
+	f.Add([]byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Add(append(bytes.Repeat([]byte{0x81}, 64), 0x00))
+	f.Add([]byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x40, 0x22, 0x40})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, rest, err := decodeCBOR(data)
+
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCBOR) {
+				t.Fatalf("decodeCBOR() error = %v, want %v", err, ErrInvalidCBOR)
+			}
+			return
+		}
+
+		if len(rest) >= len(data) || !bytes.HasSuffix(data, rest) {
+			t.Fatalf("rest %x is not a proper suffix of %x", rest, data)
+		}
+	})
+}
+
+func FuzzParseAuthenticatorData(f *testing.F) {
+	f.Add(make([]byte, 37))
+	f.Add(append(append(make([]byte, 32), flagUserPresent|flagAttestedCredData, 0, 0, 0, 1), make([]byte, 16)...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		authData, err := parseAuthenticatorData(data)
+
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCredential) {
+				t.Fatalf("parseAuthenticatorData() error = %v, want %v", err, ErrInvalidCredential)
+			}
+			return
+		}
+
+		if authData.flags&flagAttestedCredData != 0 && len(authData.credentialID) == 0 {
+			t.Fatalf("attested credential data without a credential id")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers, see the IANA COSE Algorithms registry.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms are offered to authenticators in order of preference.
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters, RFC 9053.
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported credential public key")
+	ErrSignature      = errors.New("signature verification failed")
+)
+
+// parsePublicKey decodes a COSE_Key into a public key and its algorithm.
+func parsePublicKey(coseKey []byte) (crypto.PublicKey, int, error) {
+	item, _, err := decodeCBOR(coseKey)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m, ok := item.(map[interface{}]interface{})
+
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: not a map", ErrUnsupportedKey)
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 key", ErrUnsupportedKey)
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, fmt.Errorf("%w: point is not on the curve", ErrUnsupportedKey)
+		}
+
+		return key, AlgES256, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid RSA key", ErrUnsupportedKey)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, AlgRS256, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: kty %d alg %d", ErrUnsupportedKey, kty, alg)
+}
+
+// verifySignature checks sig over data with the COSE encoded key.
+func verifySignature(coseKey, data, sig []byte) error {
+	key, _, err := parsePublicKey(coseKey)
+
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	}
+
+	return ErrSignature
+}
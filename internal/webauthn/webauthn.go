@@ -0,0 +1,514 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	challengeBytes     = 32
+	maxCredentialIDLen = 1023
+
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagBackupEligible   = 0x08
+	flagAttestedCredData = 0x40
+)
+
+var (
+	ErrInvalidCredential      = errors.New("invalid webauthn credential")
+	ErrChallengeMismatch      = errors.New("webauthn challenge mismatch")
+	ErrOriginNotAllowed       = errors.New("webauthn origin is not allowed")
+	ErrRPIDMismatch           = errors.New("webauthn relying party id mismatch")
+	ErrUserNotPresent         = errors.New("webauthn user presence is missing")
+	ErrUserNotVerified        = errors.New("webauthn user verification is missing")
+	ErrUnsupportedAttestation = errors.New("unsupported webauthn attestation format")
+	ErrSignCount              = errors.New("webauthn signature counter did not increase, the authenticator may be cloned")
+)
+
+// RelyingParty verifies ceremonies for one relying party ID and the web
+// origins allowed to use it.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	Timeout time.Duration
+	// RequireUserVerification rejects authenticators that only proved user
+	// presence, e.g. security keys without a PIN.
+	RequireUserVerification bool
+}
+
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions,
+// binary fields are base64url encoded.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions.
+// Without AllowCredentials the authenticator offers its discoverable
+// credentials (passkeys).
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationCredential is the JSON form of the PublicKeyCredential
+// returned by navigator.credentials.create().
+type RegistrationCredential struct {
+	ID       string `json:"id" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" validate:"required"`
+		AttestationObject string   `json:"attestationObject" validate:"required"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionCredential is the JSON form of the PublicKeyCredential returned
+// by navigator.credentials.get().
+type AssertionCredential struct {
+	ID       string `json:"id" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData string `json:"authenticatorData" validate:"required"`
+		Signature         string `json:"signature" validate:"required"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a verified newly registered credential.
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Algorithm      int
+	SignCount      uint32
+	Transports     []string
+	BackupEligible bool
+}
+
+// Assertion is the outcome of a verified authentication ceremony.
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// set only when flagAttestedCredData is
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a random base64url encoded challenge.
+func NewChallenge() (string, error) {
+	b := make([]byte, challengeBytes)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// EncodeID returns the base64url form used for credential IDs and user handles.
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// DecodeID accepts base64url with or without padding.
+func DecodeID(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func (rp RelyingParty) CreationOptions(challenge string, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	params := make([]CredentialParameter, len(SupportedAlgorithms))
+
+	for i, alg := range SupportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+
+	return CreationOptions{
+		Challenge:          challenge,
+		RP:                 RPEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: emptyIfNil(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: rp.userVerification(),
+		},
+		Attestation: "none",
+	}
+}
+
+func (rp RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: emptyIfNil(allow),
+		UserVerification: rp.userVerification(),
+	}
+}
+
+// Challenge returns the challenge the credential claims to answer, so the
+// matching ceremony can be looked up before verifying it.
+func (c RegistrationCredential) Challenge() (string, error) {
+	cd, err := parseClientData(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return "", err
+	}
+
+	return cd.Challenge, nil
+}
+
+func (c AssertionCredential) Challenge() (string, error) {
+	cd, err := parseClientData(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return "", err
+	}
+
+	return cd.Challenge, nil
+}
+
+// VerifyRegistration runs the registration ceremony checks of WebAuthn
+// Level 2 section 7.1. Only the "none" attestation format, which the
+// creation options ask for, and packed self attestation are accepted; the
+// authenticator model is not verified.
+func (rp RelyingParty) VerifyRegistration(c RegistrationCredential, challenge string) (*Credential, error) {
+	if c.Type != "public-key" {
+		return nil, fmt.Errorf("%w: type %q", ErrInvalidCredential, c.Type)
+	}
+
+	clientDataJSON, err := DecodeID(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data: %w", ErrInvalidCredential, err)
+	}
+
+	if err := rp.verifyClientData(clientDataJSON, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := DecodeID(c.Response.AttestationObject)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %w", ErrInvalidCredential, err)
+	}
+
+	item, _, err := decodeCBOR(attestationObject)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %w", ErrInvalidCredential, err)
+	}
+
+	att, ok := item.(map[interface{}]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrInvalidCredential)
+	}
+
+	format, _ := att["fmt"].(string)
+	rawAuthData, _ := att["authData"].([]byte)
+	attStmt, _ := att["attStmt"].(map[interface{}]interface{})
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.flags&flagAttestedCredData == 0 {
+		return nil, fmt.Errorf("%w: attested credential data is missing", ErrInvalidCredential)
+	}
+
+	if rawID, err := DecodeID(c.ID); err != nil || !bytes.Equal(rawID, authData.credentialID) {
+		return nil, fmt.Errorf("%w: credential id mismatch", ErrInvalidCredential)
+	}
+
+	_, alg, err := parsePublicKey(authData.publicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	if err := verifyAttestation(format, attStmt, alg, authData, rawAuthData, clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      alg,
+		SignCount:      authData.signCount,
+		Transports:     c.Response.Transports,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion runs the authentication ceremony checks of WebAuthn
+// Level 2 section 7.2 against the stored public key and signature counter.
+func (rp RelyingParty) VerifyAssertion(c AssertionCredential, challenge string, publicKey []byte, signCount uint32) (*Assertion, error) {
+	if c.Type != "public-key" {
+		return nil, fmt.Errorf("%w: type %q", ErrInvalidCredential, c.Type)
+	}
+
+	clientDataJSON, err := DecodeID(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data: %w", ErrInvalidCredential, err)
+	}
+
+	if err := rp.verifyClientData(clientDataJSON, ceremonyGet, challenge); err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := DecodeID(c.Response.AuthenticatorData)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: authenticator data: %w", ErrInvalidCredential, err)
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := DecodeID(c.Response.Signature)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidCredential, err)
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	if err := verifySignature(publicKey, slices.Concat(rawAuthData, clientDataHash[:]), sig); err != nil {
+		return nil, err
+	}
+
+	// authenticators without a counter, like most passkeys, always send 0
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return nil, ErrSignCount
+	}
+
+	return &Assertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var cd clientData
+
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data: %w", ErrInvalidCredential, err)
+	}
+
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q", ErrInvalidCredential, cd.Type)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	if cd.CrossOrigin || !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("%w: %s", ErrOriginNotAllowed, cd.Origin)
+	}
+
+	return nil
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+
+	if subtle.ConstantTimeCompare(authData.rpIDHash, rpIDHash[:]) != 1 {
+		return nil, ErrRPIDMismatch
+	}
+
+	if authData.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+
+	if rp.RequireUserVerification && authData.flags&flagUserVerified == 0 {
+		return nil, ErrUserNotVerified
+	}
+
+	return authData, nil
+}
+
+func (rp RelyingParty) userVerification() string {
+	if rp.RequireUserVerification {
+		return "required"
+	}
+
+	return "preferred"
+}
+
+func verifyAttestation(format string, attStmt map[interface{}]interface{}, alg int, authData *authenticatorData, rawAuthData, clientDataHash []byte) error {
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return fmt.Errorf("%w: none with a statement", ErrUnsupportedAttestation)
+		}
+		return nil
+	case "packed":
+		if _, ok := attStmt["x5c"]; ok {
+			return fmt.Errorf("%w: packed with a certificate chain", ErrUnsupportedAttestation)
+		}
+
+		stmtAlg, _ := attStmt["alg"].(int64)
+		sig, _ := attStmt["sig"].([]byte)
+
+		if int(stmtAlg) != alg {
+			return fmt.Errorf("%w: self attestation algorithm mismatch", ErrUnsupportedAttestation)
+		}
+
+		return verifySignature(authData.publicKey, slices.Concat(rawAuthData, clientDataHash), sig)
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnsupportedAttestation, format)
+}
+
+func parseClientData(encoded string) (*clientData, error) {
+	raw, err := DecodeID(encoded)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data: %w", ErrInvalidCredential, err)
+	}
+
+	var cd clientData
+
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: client data: %w", ErrInvalidCredential, err)
+	}
+
+	return &cd, nil
+}
+
+// parseAuthenticatorData decodes rpIdHash(32) flags(1) signCount(4) and,
+// when flagged, aaguid(16) credentialIdLength(2) credentialId publicKey.
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidCredential)
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if authData.flags&flagAttestedCredData == 0 {
+		return authData, nil
+	}
+
+	rest := raw[37:]
+
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidCredential)
+	}
+
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+
+	if idLen == 0 || idLen > maxCredentialIDLen || idLen > len(rest) {
+		return nil, fmt.Errorf("%w: invalid credential id length", ErrInvalidCredential)
+	}
+
+	authData.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, after, err := decodeCBOR(rest)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %w", ErrInvalidCredential, err)
+	}
+
+	authData.publicKey = rest[:len(rest)-len(after)]
+
+	return authData, nil
+}
+
+func emptyIfNil(d []CredentialDescriptor) []CredentialDescriptor {
+	if d == nil {
+		return []CredentialDescriptor{}
+	}
+
+	return d
+}
@@ -0,0 +1,428 @@
+package webauthn_test
+
+import (
+	"errors"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn/webauthntest"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+var testRP = webauthn.RelyingParty{
+	ID:      testRPID,
+	Name:    "Example",
+	Origins: []string{testOrigin},
+	Timeout: time.Minute,
+}
+
+func newAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	t.Helper()
+
+	a, err := webauthntest.New(testRPID, testOrigin)
+
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+
+	return a
+}
+
+func newChallenge(t *testing.T) string {
+	t.Helper()
+
+	challenge, err := webauthn.NewChallenge()
+
+	if err != nil {
+		t.Fatalf("creating challenge: %v", err)
+	}
+
+	return challenge
+}
+
+// register runs a registration ceremony that must succeed.
+func register(t *testing.T, a *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+
+	challenge := newChallenge(t)
+
+	c, err := a.Register(challenge)
+
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+
+	credential, err := testRP.VerifyRegistration(c, challenge)
+
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+
+	return credential
+}
+
+func TestRegistrationLoginRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		noCounter bool
+	}{
+		{name: "none attestation", format: webauthntest.FormatNone},
+		{name: "packed self attestation", format: webauthntest.FormatPacked},
+		{name: "without signature counter", format: webauthntest.FormatNone, noCounter: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t)
+			a.Format = tt.format
+			a.NoCounter = tt.noCounter
+
+			credential := register(t, a)
+
+			if webauthn.EncodeID(credential.ID) != webauthn.EncodeID(a.CredentialID) {
+				t.Errorf("credential ID = %x, want %x", credential.ID, a.CredentialID)
+			}
+
+			if credential.Algorithm != webauthn.AlgES256 {
+				t.Errorf("algorithm = %d, want %d", credential.Algorithm, webauthn.AlgES256)
+			}
+
+			signCount := credential.SignCount
+
+			for i := range 3 {
+				challenge := newChallenge(t)
+
+				c, err := a.Login(challenge)
+
+				if err != nil {
+					t.Fatalf("login %d: %v", i, err)
+				}
+
+				assertion, err := testRP.VerifyAssertion(c, challenge, credential.PublicKey, signCount)
+
+				if err != nil {
+					t.Fatalf("login %d: VerifyAssertion() error = %v", i, err)
+				}
+
+				if !assertion.UserVerified {
+					t.Errorf("login %d: user verification is missing", i)
+				}
+
+				if assertion.SignCount != a.SignCount {
+					t.Errorf("login %d: sign count = %d, want %d", i, assertion.SignCount, a.SignCount)
+				}
+
+				signCount = assertion.SignCount
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare changes the authenticator before it answers
+		prepare func(a *webauthntest.Authenticator)
+		// tamper changes the response afterwards
+		tamper  func(c *webauthn.RegistrationCredential)
+		rp      webauthn.RelyingParty
+		wantErr error
+	}{
+		{
+			name:    "wrong origin",
+			prepare: func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+			wantErr: webauthn.ErrOriginNotAllowed,
+		},
+		{
+			name:    "wrong rpIdHash",
+			prepare: func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+			wantErr: webauthn.ErrRPIDMismatch,
+		},
+		{
+			name:    "user presence missing",
+			prepare: func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserVerified },
+			wantErr: webauthn.ErrUserNotPresent,
+		},
+		{
+			name:    "user verification missing when required",
+			prepare: func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserPresent },
+			rp:      webauthn.RelyingParty{ID: testRPID, Origins: []string{testOrigin}, RequireUserVerification: true},
+			wantErr: webauthn.ErrUserNotVerified,
+		},
+		{
+			name:    "credential id mismatch",
+			tamper:  func(c *webauthn.RegistrationCredential) { c.ID = webauthn.EncodeID([]byte("another credential")) },
+			wantErr: webauthn.ErrInvalidCredential,
+		},
+		{
+			name:    "bad self attestation signature",
+			prepare: func(a *webauthntest.Authenticator) { a.Format = webauthntest.FormatPacked },
+			tamper: func(c *webauthn.RegistrationCredential) {
+				c.Response.ClientDataJSON = reencode(c.Response.ClientDataJSON)
+			},
+			wantErr: webauthn.ErrSignature,
+		},
+		{
+			name:    "unsupported attestation format",
+			prepare: func(a *webauthntest.Authenticator) { a.Format = "fido-u2f" },
+			wantErr: webauthn.ErrUnsupportedAttestation,
+		},
+		{
+			name: "truncated attestation object",
+			tamper: func(c *webauthn.RegistrationCredential) {
+				c.Response.AttestationObject = c.Response.AttestationObject[:40]
+			},
+			wantErr: webauthn.ErrInvalidCredential,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t)
+			challenge := newChallenge(t)
+
+			if tt.prepare != nil {
+				tt.prepare(a)
+			}
+
+			c, err := a.Register(challenge)
+
+			if err != nil {
+				t.Fatalf("registering: %v", err)
+			}
+
+			if tt.tamper != nil {
+				tt.tamper(&c)
+			}
+
+			rp := testRP
+
+			if tt.rp.ID != "" {
+				rp = tt.rp
+			}
+
+			if _, err := rp.VerifyRegistration(c, challenge); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyRegistration() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejectsOtherChallenge(t *testing.T) {
+	a := newAuthenticator(t)
+
+	c, err := a.Register(newChallenge(t))
+
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+
+	if _, err := testRP.VerifyRegistration(c, newChallenge(t)); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Errorf("VerifyRegistration() error = %v, want %v", err, webauthn.ErrChallengeMismatch)
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(a *webauthntest.Authenticator)
+		tamper  func(c *webauthn.AssertionCredential)
+		// storedCount overrides the sign count stored for the credential
+		storedCount *uint32
+		rp          webauthn.RelyingParty
+		wantErr     error
+	}{
+		{
+			name:    "wrong origin",
+			prepare: func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+			wantErr: webauthn.ErrOriginNotAllowed,
+		},
+		{
+			name:    "wrong rpIdHash",
+			prepare: func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+			wantErr: webauthn.ErrRPIDMismatch,
+		},
+		{
+			name:    "user presence missing",
+			prepare: func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserVerified },
+			wantErr: webauthn.ErrUserNotPresent,
+		},
+		{
+			name:    "user verification missing when required",
+			prepare: func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserPresent },
+			rp:      webauthn.RelyingParty{ID: testRPID, Origins: []string{testOrigin}, RequireUserVerification: true},
+			wantErr: webauthn.ErrUserNotVerified,
+		},
+		{
+			name:        "sign count equal to the stored one",
+			storedCount: ptr(uint32(5)),
+			prepare:     func(a *webauthntest.Authenticator) { a.SignCount = 4 },
+			wantErr:     webauthn.ErrSignCount,
+		},
+		{
+			name:        "sign count lower than the stored one",
+			storedCount: ptr(uint32(10)),
+			prepare:     func(a *webauthntest.Authenticator) { a.SignCount = 2 },
+			wantErr:     webauthn.ErrSignCount,
+		},
+		{
+			name:        "counter dropped to zero",
+			storedCount: ptr(uint32(3)),
+			prepare:     func(a *webauthntest.Authenticator) { a.NoCounter = true },
+			wantErr:     webauthn.ErrSignCount,
+		},
+		{
+			name: "bad signature",
+			tamper: func(c *webauthn.AssertionCredential) {
+				sig, _ := webauthn.DecodeID(c.Response.Signature)
+				sig[len(sig)-1] ^= 0xff
+				c.Response.Signature = webauthn.EncodeID(sig)
+			},
+			wantErr: webauthn.ErrSignature,
+		},
+		{
+			name: "signature by another key",
+			prepare: func(a *webauthntest.Authenticator) {
+				other, _ := webauthntest.New(testRPID, testOrigin)
+				a.Key = other.Key
+			},
+			wantErr: webauthn.ErrSignature,
+		},
+		{
+			name: "client data changed after signing",
+			tamper: func(c *webauthn.AssertionCredential) {
+				c.Response.ClientDataJSON = reencode(c.Response.ClientDataJSON)
+			},
+			wantErr: webauthn.ErrSignature,
+		},
+		{
+			name: "authenticator data changed after signing",
+			tamper: func(c *webauthn.AssertionCredential) {
+				authData, _ := webauthn.DecodeID(c.Response.AuthenticatorData)
+				authData[32] |= webauthntest.FlagUserVerified | 0x02
+				c.Response.AuthenticatorData = webauthn.EncodeID(authData)
+			},
+			wantErr: webauthn.ErrSignature,
+		},
+		{
+			name: "truncated authenticator data",
+			tamper: func(c *webauthn.AssertionCredential) {
+				c.Response.AuthenticatorData = c.Response.AuthenticatorData[:20]
+			},
+			wantErr: webauthn.ErrInvalidCredential,
+		},
+		{
+			name:    "not a public key credential",
+			tamper:  func(c *webauthn.AssertionCredential) { c.Type = "password" },
+			wantErr: webauthn.ErrInvalidCredential,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t)
+			credential := register(t, a)
+			challenge := newChallenge(t)
+
+			if tt.prepare != nil {
+				tt.prepare(a)
+			}
+
+			c, err := a.Login(challenge)
+
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+
+			if tt.tamper != nil {
+				tt.tamper(&c)
+			}
+
+			rp := testRP
+
+			if tt.rp.ID != "" {
+				rp = tt.rp
+			}
+
+			storedCount := credential.SignCount
+
+			if tt.storedCount != nil {
+				storedCount = *tt.storedCount
+			}
+
+			if _, err := rp.VerifyAssertion(c, challenge, credential.PublicKey, storedCount); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejectsReplay(t *testing.T) {
+	a := newAuthenticator(t)
+	credential := register(t, a)
+	challenge := newChallenge(t)
+
+	c, err := a.Login(challenge)
+
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	assertion, err := testRP.VerifyAssertion(c, challenge, credential.PublicKey, credential.SignCount)
+
+	if err != nil {
+		t.Fatalf("VerifyAssertion() error = %v", err)
+	}
+
+	// a captured response does not answer the next ceremony
+	if _, err := testRP.VerifyAssertion(c, newChallenge(t), credential.PublicKey, assertion.SignCount); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Errorf("replay for a new challenge: error = %v, want %v", err, webauthn.ErrChallengeMismatch)
+	}
+
+	// nor the same one once the counter moved on
+	if _, err := testRP.VerifyAssertion(c, challenge, credential.PublicKey, assertion.SignCount); !errors.Is(err, webauthn.ErrSignCount) {
+		t.Errorf("replay for the same challenge: error = %v, want %v", err, webauthn.ErrSignCount)
+	}
+}
+
+func TestVerifyAssertionRejectsRegistrationResponse(t *testing.T) {
+	a := newAuthenticator(t)
+	credential := register(t, a)
+	challenge := newChallenge(t)
+
+	registration, err := a.Register(challenge)
+
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+
+	c, err := a.Login(challenge)
+
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// client data of a create ceremony must not sign anyone in
+	c.Response.ClientDataJSON = registration.Response.ClientDataJSON
+
+	if _, err := testRP.VerifyAssertion(c, challenge, credential.PublicKey, credential.SignCount); !errors.Is(err, webauthn.ErrInvalidCredential) {
+		t.Errorf("VerifyAssertion() error = %v, want %v", err, webauthn.ErrInvalidCredential)
+	}
+}
+
+// reencode returns client data that still passes the checks but hashes
+// differently, as if changed after the authenticator signed it.
+func reencode(encoded string) string {
+	raw, _ := webauthn.DecodeID(encoded)
+
+	return webauthn.EncodeID(append(raw, ' '))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
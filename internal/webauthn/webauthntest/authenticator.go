@@ -0,0 +1,177 @@
+// Package webauthntest provides a software authenticator for tests. It
+// creates ES256 credentials and answers ceremonies the way a browser and
+// security key would, and its fields can be changed to produce responses
+// the relying party must reject.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/SomchaiSPB/user-auth/internal/webauthn"
+	"slices"
+)
+
+// Authenticator data flags.
+const (
+	FlagUserPresent      = 0x01
+	FlagUserVerified     = 0x04
+	FlagAttestedCredData = 0x40
+)
+
+// Attestation formats Register can produce.
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// Authenticator holds one ES256 credential.
+type Authenticator struct {
+	// RPID is hashed into the authenticator data.
+	RPID string
+	// Origin is reported in the client data.
+	Origin       string
+	Key          *ecdsa.PrivateKey
+	CredentialID []byte
+	// UserHandle is returned with assertions, as by discoverable credentials.
+	UserHandle []byte
+	// Flags are set in the authenticator data, FlagAttestedCredData is added
+	// when registering.
+	Flags byte
+	// SignCount is incremented before every assertion unless NoCounter is
+	// set, in which case 0 is reported like most passkeys do.
+	SignCount uint32
+	NoCounter bool
+	// Format is the attestation format of Register.
+	Format string
+}
+
+// New returns an authenticator with a fresh key that proves user presence
+// and verification.
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, 16)
+
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Key:          key,
+		CredentialID: credentialID,
+		Flags:        FlagUserPresent | FlagUserVerified,
+		Format:       FormatNone,
+	}, nil
+}
+
+// Register answers a registration ceremony for challenge.
+func (a *Authenticator) Register(challenge string) (webauthn.RegistrationCredential, error) {
+	var c webauthn.RegistrationCredential
+
+	clientDataJSON, err := a.clientData("webauthn.create", challenge)
+
+	if err != nil {
+		return c, err
+	}
+
+	authData := a.authenticatorData(a.Flags|FlagAttestedCredData, a.SignCount)
+	authData = binary.BigEndian.AppendUint16(append(authData, make([]byte, 16)...), uint16(len(a.CredentialID)))
+	authData = append(append(authData, a.CredentialID...), a.PublicKey()...)
+
+	attStmt := cborMap{}
+
+	if a.Format == FormatPacked {
+		sig, err := a.sign(authData, clientDataJSON)
+
+		if err != nil {
+			return c, err
+		}
+
+		attStmt = cborMap{{"alg", webauthn.AlgES256}, {"sig", sig}}
+	}
+
+	attestationObject := encodeCBOR(cborMap{{"fmt", a.Format}, {"attStmt", attStmt}, {"authData", authData}})
+
+	c.ID = webauthn.EncodeID(a.CredentialID)
+	c.Type = "public-key"
+	c.Response.ClientDataJSON = webauthn.EncodeID(clientDataJSON)
+	c.Response.AttestationObject = webauthn.EncodeID(attestationObject)
+	c.Response.Transports = []string{"internal"}
+
+	return c, nil
+}
+
+// Login answers an authentication ceremony for challenge.
+func (a *Authenticator) Login(challenge string) (webauthn.AssertionCredential, error) {
+	var c webauthn.AssertionCredential
+
+	clientDataJSON, err := a.clientData("webauthn.get", challenge)
+
+	if err != nil {
+		return c, err
+	}
+
+	if !a.NoCounter {
+		a.SignCount++
+	}
+
+	authData := a.authenticatorData(a.Flags, a.SignCount)
+
+	sig, err := a.sign(authData, clientDataJSON)
+
+	if err != nil {
+		return c, err
+	}
+
+	c.ID = webauthn.EncodeID(a.CredentialID)
+	c.Type = "public-key"
+	c.Response.ClientDataJSON = webauthn.EncodeID(clientDataJSON)
+	c.Response.AuthenticatorData = webauthn.EncodeID(authData)
+	c.Response.Signature = webauthn.EncodeID(sig)
+	c.Response.UserHandle = webauthn.EncodeID(a.UserHandle)
+
+	return c, nil
+}
+
+// PublicKey returns the COSE_Key of the credential.
+func (a *Authenticator) PublicKey() []byte {
+	return encodeCBOR(cborMap{
+		{1, 2},                 // kty: EC2
+		{3, webauthn.AlgES256}, // alg
+		{-1, 1},                // crv: P-256
+		{-2, a.Key.X.FillBytes(make([]byte, 32))},
+		{-3, a.Key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authenticatorData(flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+
+	return binary.BigEndian.AppendUint32(append(rpIDHash[:], flags), signCount)
+}
+
+func (a *Authenticator) sign(authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(slices.Concat(authData, clientDataHash[:]))
+
+	return ecdsa.SignASN1(rand.Reader, a.Key, digest[:])
+}
@@ -0,0 +1,58 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// cborMap keeps the order of its entries, so encodings are stable.
+type cborMap []cborEntry
+
+type cborEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// encodeCBOR encodes the definite length subset of CBOR authenticators
+// emit: integers, byte and text strings and maps.
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+
+		for _, e := range v {
+			out = append(out, encodeCBOR(e.key)...)
+			out = append(out, encodeCBOR(e.value)...)
+		}
+
+		return out
+	}
+
+	panic(fmt.Sprintf("webauthntest: cannot encode %T", v))
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	major <<= 5
+
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	}
+
+	return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+}